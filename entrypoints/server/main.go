@@ -2,60 +2,78 @@ package main
 
 import (
 	"context"
-	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
+	_ "time/tzdata" // the runtime image has no zoneinfo, users pick their time zones
 
+	"github.com/scriptdealer/to-do-go/internal/config"
+	"github.com/scriptdealer/to-do-go/internal/notify"
 	"github.com/scriptdealer/to-do-go/internal/services"
 	"github.com/scriptdealer/to-do-go/internal/storage"
 	"github.com/scriptdealer/to-do-go/internal/transport/rest"
 )
 
-type Configuration struct {
-	ServerIP   string
-	ServerPort string
-}
-
-func getConfig() *Configuration {
-	cfg := Configuration{
-		ServerIP:   "0.0.0.0",
-		ServerPort: "8080",
-	}
-	ip, found := os.LookupEnv("TODO_IP")
-	if found {
-		cfg.ServerIP = ip
-	}
-	port, found := os.LookupEnv("TODO_PORT")
-	if found {
-		cfg.ServerPort = port
-	}
-	return &cfg
-}
-
 func main() {
-	//InitConfig
-	config := getConfig()
-
 	//InitLogger
 	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
 
+	//InitConfig
+	cfg, args, err := config.Load(os.Args[1:])
+	if err != nil {
+		logger.Error("Configuration", slog.String("reason", err.Error()))
+		os.Exit(2)
+	}
+	logger.Info("Configuration loaded", slog.Any("config", cfg))
+
+	if len(args) > 0 && args[0] == "migrate" {
+		os.Exit(migrate(logger, cfg.StorageDSN(), args[1:]))
+	}
+
 	//Injections
-	// db := storage.NewMemoryStorage()
-	db, err := storage.NewPostgresStore(logger)
+	db, err := storage.Open(cfg.StorageDSN(), logger)
+	if err != nil {
+		logger.Error("Storage", slog.String("reason", err.Error()))
+		os.Exit(1)
+	}
+	if err := db.Init(); err != nil {
+		logger.Error("Storage init", slog.String("reason", err.Error()))
+		os.Exit(1)
+	}
+	tokens, err := services.NewTokenSettings(cfg.Auth)
+	if err != nil {
+		logger.Error("Token settings", slog.String("reason", err.Error()))
+		os.Exit(1)
+	}
+	notifier, err := notify.New(cfg.Reminders, logger)
 	if err != nil {
+		logger.Error("Notifier", slog.String("reason", err.Error()))
 		os.Exit(1)
 	}
+	schedule, stopScheduler := context.WithCancel(context.Background())
+	defer stopScheduler()
+	if cfg.Reminders.Interval > 0 {
+		go services.NewScheduler(db, notifier, cfg.Reminders.Interval, logger).Run(schedule)
+	}
+	if cfg.Archive.AfterDays > 0 || cfg.Trash.RetentionDays > 0 {
+		archiveAfter := time.Duration(cfg.Archive.AfterDays) * 24 * time.Hour
+		retention := time.Duration(cfg.Trash.RetentionDays) * 24 * time.Hour
+		go services.NewJanitor(db, archiveAfter, retention, logger).Run(schedule)
+	}
 	services := services.NewComposite(
-		db, logger,
+		cfg, db, logger,
 		services.NewToDoService(db, logger),
+		services.NewTagService(db, logger),
+		services.NewUserService(db, tokens, logger),
+		services.NewReminderService(db, logger),
+		services.NewIdempotencyService(db, cfg.Server.IdempotencyTTL, logger),
 	)
 	api := rest.Init(services)
 	server := &http.Server{
-		Addr:           fmt.Sprintf("%s:%s", config.ServerIP, config.ServerPort),
+		Addr:           cfg.Address(),
 		Handler:        api.Router,
 		ReadTimeout:    14 * time.Second,
 		WriteTimeout:   14 * time.Second,
@@ -67,6 +85,7 @@ func main() {
 	go func() {
 		s := <-services.Interruption
 		logger.Info("Interrupted", slog.Any("signal", s))
+		stopScheduler()
 
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
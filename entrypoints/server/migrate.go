@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/scriptdealer/to-do-go/internal/storage"
+)
+
+const migrateUsage = "usage: server migrate up | down [steps] | status"
+
+type migratable interface {
+	Migrator() (*storage.Migrator, error)
+}
+
+// migrate implements the "server migrate" subcommand and returns the process exit code.
+func migrate(logger *slog.Logger, dsn string, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	db, err := storage.Open(dsn, logger)
+	if err != nil {
+		logger.Error("Storage", slog.String("reason", err.Error()))
+		return 1
+	}
+	defer db.Close()
+
+	sqlDB, ok := db.(migratable)
+	if !ok {
+		logger.Error("Storage has no schema to migrate")
+		return 1
+	}
+
+	migrator, err := sqlDB.Migrator()
+	if err != nil {
+		logger.Error("Migrations", slog.String("reason", err.Error()))
+		return 1
+	}
+
+	ctx := context.Background()
+	switch args[0] {
+	case "up":
+		count, err := migrator.Up(ctx)
+		if err != nil {
+			logger.Error("Migrate up", slog.String("reason", err.Error()))
+			return 1
+		}
+		fmt.Printf("applied %d migration(s)\n", count)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				fmt.Fprintln(os.Stderr, migrateUsage)
+				return 2
+			}
+		}
+		count, err := migrator.Down(ctx, steps)
+		if err != nil {
+			logger.Error("Migrate down", slog.String("reason", err.Error()))
+			return 1
+		}
+		fmt.Printf("reverted %d migration(s)\n", count)
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			logger.Error("Migrate status", slog.String("reason", err.Error()))
+			return 1
+		}
+		out := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(out, "VERSION\tNAME\tAPPLIED")
+		for _, status := range statuses {
+			applied := "pending"
+			if status.AppliedAt != nil {
+				applied = status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(out, "%04d\t%s\t%s\n", status.Version, status.Name, applied)
+		}
+		out.Flush()
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	return 0
+}
@@ -0,0 +1,431 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const redacted = "[REDACTED]"
+
+// Secret is a string that never shows up in logs or printed configuration.
+type Secret string
+
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return redacted
+}
+
+func (s Secret) LogValue() slog.Value {
+	return slog.StringValue(s.String())
+}
+
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(s.String())), nil
+}
+
+type Configuration struct {
+	Server    ServerConfig    `yaml:"server"`
+	Storage   StorageConfig   `yaml:"storage"`
+	Auth      AuthConfig      `yaml:"auth"`
+	Reminders RemindersConfig `yaml:"reminders"`
+	Archive   ArchiveConfig   `yaml:"archive"`
+	Trash     TrashConfig     `yaml:"trash"`
+}
+
+type ServerConfig struct {
+	IP   string `yaml:"ip"`
+	Port string `yaml:"port"`
+	// RequireIfMatch refuses updates and deletions of items that name no version to overwrite.
+	RequireIfMatch bool `yaml:"require_if_match"`
+	// IdempotencyTTL is how long the responses to requests with an Idempotency-Key are replayed to their retries.
+	IdempotencyTTL time.Duration `yaml:"idempotency_ttl"`
+}
+
+type StorageConfig struct {
+	// DSN selects the backend by its scheme; when empty, Postgres is reached with the settings below.
+	DSN      string         `yaml:"dsn"`
+	Postgres PostgresConfig `yaml:"postgres"`
+}
+
+type PostgresConfig struct {
+	Host     string `yaml:"host"`
+	Port     string `yaml:"port"`
+	DBName   string `yaml:"dbname"`
+	UserName string `yaml:"user"`
+	Password Secret `yaml:"password"`
+}
+
+type AuthConfig struct {
+	Algorithm      string        `yaml:"algorithm"`
+	Secret         Secret        `yaml:"secret"`
+	SecretFile     string        `yaml:"secret_file"`
+	PrivateKeyFile string        `yaml:"private_key_file"`
+	AccessTTL      time.Duration `yaml:"access_ttl"`
+	RefreshTTL     time.Duration `yaml:"refresh_ttl"`
+}
+
+// RemindersConfig sets how often due reminders are looked up and how they are delivered.
+type RemindersConfig struct {
+	// Interval between the lookups, zero turns the scheduler off.
+	Interval time.Duration `yaml:"interval"`
+	// Notifier is log, webhook or smtp.
+	Notifier string        `yaml:"notifier"`
+	Webhook  WebhookConfig `yaml:"webhook"`
+	SMTP     SMTPConfig    `yaml:"smtp"`
+}
+
+type WebhookConfig struct {
+	URL string `yaml:"url"`
+	// Secret signs the payloads with HMAC-SHA256, the signature goes in the X-Signature header.
+	Secret Secret `yaml:"secret"`
+}
+
+// SMTPConfig reaches a mail server; without a username it is used without authentication.
+type SMTPConfig struct {
+	Host     string `yaml:"host"`
+	Port     string `yaml:"port"`
+	Username string `yaml:"username"`
+	Password Secret `yaml:"password"`
+	From     string `yaml:"from"`
+}
+
+// ArchiveConfig sets how long done items stay in the active listings.
+type ArchiveConfig struct {
+	// AfterDays is counted from the completion, zero leaves the done items where they are.
+	AfterDays int `yaml:"after_days"`
+}
+
+// TrashConfig sets how long deleted items stay in the trash before they are purged for good.
+type TrashConfig struct {
+	// RetentionDays is counted from the deletion, zero keeps the items until they are purged by hand.
+	RetentionDays int `yaml:"retention_days"`
+}
+
+func Default() *Configuration {
+	return &Configuration{
+		Server: ServerConfig{
+			IP:             "0.0.0.0",
+			Port:           "8080",
+			IdempotencyTTL: 24 * time.Hour,
+		},
+		Storage: StorageConfig{
+			Postgres: PostgresConfig{
+				Host:     "db",
+				Port:     "5432",
+				DBName:   "todo_demo",
+				UserName: "pguser",
+				Password: "pgpassword",
+			},
+		},
+		Auth: AuthConfig{
+			Algorithm:  "HS256",
+			AccessTTL:  15 * time.Minute,
+			RefreshTTL: 30 * 24 * time.Hour,
+		},
+		Reminders: RemindersConfig{
+			Interval: 30 * time.Second,
+			Notifier: "log",
+			SMTP: SMTPConfig{
+				Port: "587",
+			},
+		},
+	}
+}
+
+// Load builds the configuration out of, in increasing precedence, the defaults,
+// a YAML file (-config flag or TODO_CONFIG), environment variables and command-line flags.
+// It returns the arguments left after the flags, e.g. a subcommand.
+func Load(args []string) (*Configuration, []string, error) {
+	cfg := Default()
+
+	flags := flag.NewFlagSet("server", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	path := flags.String("config", os.Getenv("TODO_CONFIG"), "path to a YAML configuration file")
+	overrides := cfg.bindFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	if *path != "" {
+		if err := cfg.readFile(*path); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if err := cfg.readEnv(); err != nil {
+		return nil, nil, err
+	}
+
+	// flags were parsed before the file was read, so their values are applied last
+	flags.Visit(func(f *flag.Flag) {
+		if apply, found := overrides[f.Name]; found {
+			apply()
+		}
+	})
+
+	return cfg, flags.Args(), cfg.Validate()
+}
+
+func (cfg *Configuration) readFile(path string) error {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config file: %w", err)
+	}
+
+	if err := yaml.Unmarshal(raw, cfg); err != nil {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+func (cfg *Configuration) readEnv() error {
+	text := map[string]*string{
+		"TODO_IP":              &cfg.Server.IP,
+		"TODO_PORT":            &cfg.Server.Port,
+		"TODO_STORAGE":         &cfg.Storage.DSN,
+		"DB_HOST":              &cfg.Storage.Postgres.Host,
+		"DB_PORT":              &cfg.Storage.Postgres.Port,
+		"DB_NAME":              &cfg.Storage.Postgres.DBName,
+		"DB_USER":              &cfg.Storage.Postgres.UserName,
+		"JWT_ALGORITHM":        &cfg.Auth.Algorithm,
+		"JWT_SECRET_FILE":      &cfg.Auth.SecretFile,
+		"JWT_PRIVATE_KEY_FILE": &cfg.Auth.PrivateKeyFile,
+		"REMINDER_NOTIFIER":    &cfg.Reminders.Notifier,
+		"WEBHOOK_URL":          &cfg.Reminders.Webhook.URL,
+		"SMTP_HOST":            &cfg.Reminders.SMTP.Host,
+		"SMTP_PORT":            &cfg.Reminders.SMTP.Port,
+		"SMTP_USER":            &cfg.Reminders.SMTP.Username,
+		"SMTP_FROM":            &cfg.Reminders.SMTP.From,
+	}
+	for name, target := range text {
+		if value, found := os.LookupEnv(name); found {
+			*target = value
+		}
+	}
+
+	secrets := map[string]*Secret{
+		"DB_PASS":        &cfg.Storage.Postgres.Password,
+		"JWT_SECRET":     &cfg.Auth.Secret,
+		"WEBHOOK_SECRET": &cfg.Reminders.Webhook.Secret,
+		"SMTP_PASS":      &cfg.Reminders.SMTP.Password,
+	}
+	for name, target := range secrets {
+		if value, found := os.LookupEnv(name); found {
+			*target = Secret(value)
+		}
+	}
+
+	durations := map[string]*time.Duration{
+		"JWT_ACCESS_TTL":    &cfg.Auth.AccessTTL,
+		"JWT_REFRESH_TTL":   &cfg.Auth.RefreshTTL,
+		"REMINDER_INTERVAL": &cfg.Reminders.Interval,
+		"IDEMPOTENCY_TTL":   &cfg.Server.IdempotencyTTL,
+	}
+	for name, target := range durations {
+		if value, found := os.LookupEnv(name); found {
+			parsed, err := time.ParseDuration(value)
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			*target = parsed
+		}
+	}
+
+	booleans := map[string]*bool{
+		"REQUIRE_IF_MATCH": &cfg.Server.RequireIfMatch,
+	}
+	for name, target := range booleans {
+		if value, found := os.LookupEnv(name); found {
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			*target = parsed
+		}
+	}
+
+	integers := map[string]*int{
+		"ARCHIVE_AFTER_DAYS":   &cfg.Archive.AfterDays,
+		"TRASH_RETENTION_DAYS": &cfg.Trash.RetentionDays,
+	}
+	for name, target := range integers {
+		if value, found := os.LookupEnv(name); found {
+			parsed, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			*target = parsed
+		}
+	}
+
+	return nil
+}
+
+// bindFlags declares the command-line flags and returns how to apply each of them.
+func (cfg *Configuration) bindFlags(flags *flag.FlagSet) map[string]func() {
+	ip := flags.String("ip", "", "address to listen on")
+	port := flags.String("port", "", "port to listen on")
+	dsn := flags.String("storage", "", "storage DSN: memory://, postgres://... or sqlite://...")
+	algorithm := flags.String("jwt-algorithm", "", "access token signature: HS256 or RS256")
+	accessTTL := flags.Duration("jwt-access-ttl", 0, "access token lifetime")
+	notifier := flags.String("notifier", "", "reminder delivery: log, webhook or smtp")
+
+	return map[string]func(){
+		"ip":             func() { cfg.Server.IP = *ip },
+		"port":           func() { cfg.Server.Port = *port },
+		"storage":        func() { cfg.Storage.DSN = *dsn },
+		"jwt-algorithm":  func() { cfg.Auth.Algorithm = *algorithm },
+		"jwt-access-ttl": func() { cfg.Auth.AccessTTL = *accessTTL },
+		"notifier":       func() { cfg.Reminders.Notifier = *notifier },
+	}
+}
+
+// Validate reports every problem at once, so a broken deployment is fixed in one go.
+func (cfg *Configuration) Validate() error {
+	var problems []error
+
+	if net.ParseIP(cfg.Server.IP) == nil {
+		problems = append(problems, fmt.Errorf("server.ip %q is not an IP address", cfg.Server.IP))
+	}
+
+	if port, err := strconv.Atoi(cfg.Server.Port); err != nil || port < 1 || port > 65535 {
+		problems = append(problems, fmt.Errorf("server.port %q is not a port number", cfg.Server.Port))
+	}
+
+	if cfg.Server.IdempotencyTTL <= 0 {
+		problems = append(problems, errors.New("server.idempotency_ttl must be positive"))
+	}
+
+	if cfg.Storage.DSN != "" {
+		if dsn, err := url.Parse(cfg.Storage.DSN); err != nil || dsn.Scheme == "" {
+			problems = append(problems, errors.New("storage.dsn must be a URL like memory://, postgres://... or sqlite://..."))
+		}
+	}
+
+	if cfg.Auth.Algorithm != "HS256" && cfg.Auth.Algorithm != "RS256" {
+		problems = append(problems, fmt.Errorf("auth.algorithm %q must be HS256 or RS256", cfg.Auth.Algorithm))
+	}
+
+	if cfg.Auth.AccessTTL <= 0 || cfg.Auth.RefreshTTL <= 0 {
+		problems = append(problems, errors.New("auth token lifetimes must be positive"))
+	}
+
+	problems = append(problems, cfg.Reminders.validate()...)
+
+	if cfg.Archive.AfterDays < 0 {
+		problems = append(problems, errors.New("archive.after_days must not be negative"))
+	}
+
+	if cfg.Trash.RetentionDays < 0 {
+		problems = append(problems, errors.New("trash.retention_days must not be negative"))
+	}
+
+	return errors.Join(problems...)
+}
+
+func (rc *RemindersConfig) validate() []error {
+	var problems []error
+
+	if rc.Interval < 0 {
+		problems = append(problems, errors.New("reminders.interval must not be negative"))
+	}
+
+	switch rc.Notifier {
+	case "log":
+	case "webhook":
+		if target, err := url.Parse(rc.Webhook.URL); err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+			problems = append(problems, errors.New("reminders.webhook.url must be an http or https URL"))
+		}
+	case "smtp":
+		if rc.SMTP.Host == "" || rc.SMTP.From == "" {
+			problems = append(problems, errors.New("reminders.smtp.host and reminders.smtp.from are required"))
+		}
+		if port, err := strconv.Atoi(rc.SMTP.Port); err != nil || port < 1 || port > 65535 {
+			problems = append(problems, fmt.Errorf("reminders.smtp.port %q is not a port number", rc.SMTP.Port))
+		}
+	default:
+		problems = append(problems, fmt.Errorf("reminders.notifier %q must be log, webhook or smtp", rc.Notifier))
+	}
+
+	return problems
+}
+
+// StorageDSN is the configured DSN or, without one, the URL of the configured Postgres.
+func (cfg *Configuration) StorageDSN() string {
+	if cfg.Storage.DSN != "" {
+		return cfg.Storage.DSN
+	}
+
+	pg := cfg.Storage.Postgres
+	dsn := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(pg.UserName, string(pg.Password)),
+		Host:     net.JoinHostPort(pg.Host, pg.Port),
+		Path:     pg.DBName,
+		RawQuery: "sslmode=disable",
+	}
+
+	return dsn.String()
+}
+
+func (cfg *Configuration) Address() string {
+	return net.JoinHostPort(cfg.Server.IP, cfg.Server.Port)
+}
+
+// LogValue keeps passwords and keys out of the logs.
+func (cfg *Configuration) LogValue() slog.Value {
+	dsn := cfg.StorageDSN()
+	if parsed, err := url.Parse(dsn); err == nil {
+		dsn = parsed.Redacted()
+	} else {
+		dsn = redacted
+	}
+	// webhook URLs often carry a token in the path or the query, only the host is logged
+	webhook := ""
+	if parsed, err := url.Parse(cfg.Reminders.Webhook.URL); err == nil && parsed.Host != "" {
+		webhook = parsed.Scheme + "://" + parsed.Host
+	}
+
+	return slog.GroupValue(
+		slog.String("address", cfg.Address()),
+		slog.Bool("require_if_match", cfg.Server.RequireIfMatch),
+		slog.Duration("idempotency_ttl", cfg.Server.IdempotencyTTL),
+		slog.String("storage", dsn),
+		slog.Group("auth",
+			slog.String("algorithm", cfg.Auth.Algorithm),
+			slog.Any("secret", cfg.Auth.Secret),
+			slog.String("secret_file", cfg.Auth.SecretFile),
+			slog.String("private_key_file", cfg.Auth.PrivateKeyFile),
+			slog.Duration("access_ttl", cfg.Auth.AccessTTL),
+			slog.Duration("refresh_ttl", cfg.Auth.RefreshTTL),
+		),
+		slog.Group("reminders",
+			slog.Duration("interval", cfg.Reminders.Interval),
+			slog.String("notifier", cfg.Reminders.Notifier),
+			slog.String("webhook_url", webhook),
+			slog.Any("webhook_secret", cfg.Reminders.Webhook.Secret),
+			slog.String("smtp", net.JoinHostPort(cfg.Reminders.SMTP.Host, cfg.Reminders.SMTP.Port)),
+			slog.String("smtp_username", cfg.Reminders.SMTP.Username),
+			slog.Any("smtp_password", cfg.Reminders.SMTP.Password),
+		),
+		slog.Group("archive",
+			slog.Int("after_days", cfg.Archive.AfterDays),
+		),
+		slog.Group("trash",
+			slog.Int("retention_days", cfg.Trash.RetentionDays),
+		),
+	)
+}
@@ -0,0 +1,61 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/scriptdealer/to-do-go/internal/config"
+	"github.com/scriptdealer/to-do-go/known"
+)
+
+// Notifier delivers reminders; an error makes the scheduler try the delivery again later.
+type Notifier interface {
+	Notify(ctx context.Context, notice Notice) error
+}
+
+// Notice is a reminder going off, along with its item and the user it is for.
+type Notice struct {
+	Reminder *known.Reminder
+	Item     *known.TodoItem
+	User     *known.User
+}
+
+// New builds the notifier named in the configuration.
+func New(cfg config.RemindersConfig, logger *slog.Logger) (Notifier, error) {
+	switch cfg.Notifier {
+	case "log":
+		return &LogNotifier{Log: logger}, nil
+	case "webhook":
+		return NewWebhookNotifier(cfg.Webhook.URL, []byte(cfg.Webhook.Secret)), nil
+	case "smtp":
+		return NewSMTPNotifier(cfg.SMTP), nil
+	default:
+		return nil, fmt.Errorf("unknown notifier %q", cfg.Notifier)
+	}
+}
+
+// LogNotifier writes reminders to the log, which is enough for development.
+type LogNotifier struct {
+	Log *slog.Logger
+}
+
+func (ln *LogNotifier) Notify(ctx context.Context, notice Notice) error {
+	ln.Log.Info("Reminder",
+		slog.Int("reminder", notice.Reminder.ID),
+		slog.Int("todo", notice.Item.ID),
+		slog.String("title", notice.Item.Title),
+		slog.String("username", notice.User.Username))
+
+	return nil
+}
+
+// subject is the one line summary of a notice, shared by the notifiers.
+func (n Notice) subject() string {
+	if n.Item.Due != nil {
+		return fmt.Sprintf("Reminder: %s, due %s", n.Item.Title, n.Item.Due.In(n.User.Location()).Format(time.RFC1123))
+	}
+
+	return "Reminder: " + n.Item.Title
+}
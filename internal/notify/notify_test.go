@@ -0,0 +1,166 @@
+package notify_test
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/scriptdealer/to-do-go/internal/notify"
+	"github.com/scriptdealer/to-do-go/known"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func notice(email string) notify.Notice {
+	due := time.Date(2024, 5, 10, 16, 0, 0, 0, time.UTC)
+	return notify.Notice{
+		Reminder: &known.Reminder{ID: 7, TodoID: 3},
+		Item:     &known.TodoItem{ID: 3, Title: "Pay rent\r\nBcc: everyone@example.com", Description: "before noon\n.\nreally", Due: &due},
+		User:     &known.User{ID: 2, Username: "jane", TimeZone: "Europe/Berlin", Email: email},
+	}
+}
+
+// fakeSMTP is a mail server speaking just enough SMTP for net/smtp, without STARTTLS.
+type fakeSMTP struct {
+	listener net.Listener
+	mails    chan mail
+}
+
+type mail struct {
+	from, to, data string
+}
+
+func startSMTP(t *testing.T) *fakeSMTP {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	server := &fakeSMTP{listener: listener, mails: make(chan mail, 1)}
+	go server.serve()
+
+	return server
+}
+
+func (fs *fakeSMTP) port() string {
+	_, port, _ := net.SplitHostPort(fs.listener.Addr().String())
+	return port
+}
+
+func (fs *fakeSMTP) serve() {
+	conn, err := fs.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	reply := func(line string) { _, _ = io.WriteString(conn, line+"\r\n") }
+	reply("220 fake ESMTP")
+
+	var received mail
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		command := strings.TrimRight(line, "\r\n")
+		switch verb := strings.ToUpper(strings.SplitN(command, " ", 2)[0]); verb {
+		case "EHLO":
+			reply("250 fake")
+		case "MAIL":
+			received.from = strings.TrimPrefix(command, "MAIL FROM:")
+			reply("250 OK")
+		case "RCPT":
+			received.to = strings.TrimPrefix(command, "RCPT TO:")
+			reply("250 OK")
+		case "DATA":
+			reply("354 go ahead")
+			var data strings.Builder
+			for {
+				line, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				data.WriteString(line)
+			}
+			received.data = data.String()
+			reply("250 queued")
+		case "QUIT":
+			reply("221 bye")
+			fs.mails <- received
+			return
+		default:
+			reply("502 not implemented")
+		}
+	}
+}
+
+func TestSMTPNotifier(t *testing.T) {
+	server := startSMTP(t)
+	notifier := &notify.SMTPNotifier{Host: "127.0.0.1", Port: server.port(), From: "todo@example.com"}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, notifier.Notify(ctx, notice("jane@example.com")))
+
+	received := <-server.mails
+	assert.Equal(t, "<todo@example.com>", received.from)
+	assert.Equal(t, "<jane@example.com>", received.to)
+
+	headers, body, found := strings.Cut(received.data, "\r\n\r\n")
+	require.True(t, found)
+	assert.Contains(t, headers, "To: jane@example.com\r\n")
+	assert.NotContains(t, headers, "\r\nBcc:", "line breaks of the title stay out of the headers")
+	assert.Contains(t, headers, "Subject: =?utf-8?q?")
+	assert.Equal(t, "Pay rent\r\nBcc: everyone@example.com\r\n\r\nbefore noon\r\n..\r\nreally\r\n", body, "the lone dot is stuffed")
+}
+
+func TestSMTPNotifier_NoEmail(t *testing.T) {
+	notifier := &notify.SMTPNotifier{Host: "127.0.0.1", Port: "1", From: "todo@example.com"}
+	assert.Error(t, notifier.Notify(context.Background(), notice("")))
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	var payload notify.WebhookPayload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		mac := hmac.New(sha256.New, []byte("hook-secret"))
+		mac.Write(body)
+		assert.Equal(t, "sha256="+hex.EncodeToString(mac.Sum(nil)), r.Header.Get("X-Signature"))
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.NoError(t, json.Unmarshal(body, &payload))
+	}))
+	defer server.Close()
+
+	notifier := notify.NewWebhookNotifier(server.URL, []byte("hook-secret"))
+	require.NoError(t, notifier.Notify(context.Background(), notice("")))
+	assert.Equal(t, 7, payload.Reminder.ID)
+	assert.Equal(t, 3, payload.Item.ID)
+	assert.Equal(t, "jane", payload.Username)
+	assert.Contains(t, payload.Subject, "due Fri, 10 May 2024 18:00:00 CEST", "due dates are in the time zone of the user")
+}
+
+func TestWebhookNotifier_Failure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	err := notify.NewWebhookNotifier(server.URL, nil).Notify(context.Background(), notice(""))
+	assert.EqualError(t, err, "webhook answered 502 Bad Gateway")
+}
@@ -0,0 +1,124 @@
+package notify
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/scriptdealer/to-do-go/internal/config"
+)
+
+const smtpTimeout = 30 * time.Second
+
+var errNoEmail = errors.New("the user has no email address")
+
+// SMTPNotifier mails reminders to the users, upgrading the connection with STARTTLS
+// whenever the server offers it.
+type SMTPNotifier struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+	// TLS configures STARTTLS, its zero value verifies the certificate against Host.
+	TLS *tls.Config
+}
+
+func NewSMTPNotifier(cfg config.SMTPConfig) *SMTPNotifier {
+	return &SMTPNotifier{
+		Host:     cfg.Host,
+		Port:     cfg.Port,
+		Username: cfg.Username,
+		Password: string(cfg.Password),
+		From:     cfg.From,
+	}
+}
+
+func (sn *SMTPNotifier) Notify(ctx context.Context, notice Notice) error {
+	if notice.User.Email == "" {
+		return errNoEmail
+	}
+
+	dialer := net.Dialer{Timeout: smtpTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(sn.Host, sn.Port))
+	if err != nil {
+		return err
+	}
+	deadline, found := ctx.Deadline()
+	if !found {
+		deadline = time.Now().Add(smtpTimeout)
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		conn.Close()
+		return err
+	}
+
+	client, err := smtp.NewClient(conn, sn.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if upgrade, _ := client.Extension("STARTTLS"); upgrade {
+		config := sn.TLS
+		if config == nil {
+			config = &tls.Config{ServerName: sn.Host}
+		}
+		if err := client.StartTLS(config); err != nil {
+			return err
+		}
+	}
+	if sn.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", sn.Username, sn.Password, sn.Host)); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(sn.From); err != nil {
+		return err
+	}
+	if err := client.Rcpt(notice.User.Email); err != nil {
+		return err
+	}
+	data, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := data.Write(sn.message(notice)); err != nil {
+		return err
+	}
+	if err := data.Close(); err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
+
+// message writes the mail of a notice; the Q-encoding of the subject keeps
+// line breaks of item titles out of the headers.
+func (sn *SMTPNotifier) message(notice Notice) []byte {
+	var mail strings.Builder
+	fmt.Fprintf(&mail, "From: %s\r\n", sn.From)
+	fmt.Fprintf(&mail, "To: %s\r\n", notice.User.Email)
+	fmt.Fprintf(&mail, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", notice.subject()))
+	fmt.Fprintf(&mail, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	mail.WriteString("MIME-Version: 1.0\r\n")
+	mail.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	mail.WriteString("\r\n")
+
+	body := notice.Item.Title
+	if notice.Item.Description != "" {
+		body += "\n\n" + notice.Item.Description
+	}
+	mail.WriteString(strings.ReplaceAll(strings.ReplaceAll(body, "\r\n", "\n"), "\n", "\r\n"))
+	mail.WriteString("\r\n")
+
+	return []byte(mail.String())
+}
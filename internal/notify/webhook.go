@@ -0,0 +1,76 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/scriptdealer/to-do-go/known"
+)
+
+const webhookTimeout = 10 * time.Second
+
+// WebhookNotifier posts reminders as JSON to a URL, expecting a 2xx answer.
+// With a secret, the body is signed with HMAC-SHA256 in the X-Signature header as sha256=<hex>.
+type WebhookNotifier struct {
+	URL    string
+	Secret []byte
+	Client *http.Client
+}
+
+func NewWebhookNotifier(url string, secret []byte) *WebhookNotifier {
+	return &WebhookNotifier{URL: url, Secret: secret, Client: &http.Client{Timeout: webhookTimeout}}
+}
+
+// WebhookPayload is the body posted for a reminder.
+type WebhookPayload struct {
+	Reminder *known.Reminder `json:"reminder"`
+	Item     *known.TodoItem `json:"todo"`
+	UserID   int             `json:"user_id"`
+	Username string          `json:"username"`
+	Subject  string          `json:"subject"`
+}
+
+func (wn *WebhookNotifier) Notify(ctx context.Context, notice Notice) error {
+	body, err := json.Marshal(WebhookPayload{
+		Reminder: notice.Reminder,
+		Item:     notice.Item,
+		UserID:   notice.User.ID,
+		Username: notice.User.Username,
+		Subject:  notice.subject(),
+	})
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, wn.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	if len(wn.Secret) > 0 {
+		mac := hmac.New(sha256.New, wn.Secret)
+		mac.Write(body)
+		request.Header.Set("X-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	response, err := wn.Client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	_, _ = io.Copy(io.Discard, response.Body)
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("webhook answered %s", response.Status)
+	}
+
+	return nil
+}
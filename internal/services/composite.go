@@ -4,23 +4,32 @@ import (
 	"log/slog"
 	"os"
 
+	"github.com/scriptdealer/to-do-go/internal/config"
 	"github.com/scriptdealer/to-do-go/internal/storage"
 )
 
 type Composition struct {
-	// Config     *Configuration
+	Config       *config.Configuration
 	DB           storage.ToDoStore
 	Interruption chan os.Signal
 	Log          *slog.Logger
 	ToDos        TodoLogic
-	// Users        *userService
+	Tags         TagLogic
+	Users        UserLogic
+	Reminders    ReminderLogic
+	Idempotency  IdempotencyLogic
 }
 
-func NewComposite(db storage.ToDoStore, logger *slog.Logger, todos TodoLogic) *Composition {
+func NewComposite(cfg *config.Configuration, db storage.ToDoStore, logger *slog.Logger, todos TodoLogic, tags TagLogic, users UserLogic, reminders ReminderLogic, idempotency IdempotencyLogic) *Composition {
 	return &Composition{
+		Config:       cfg,
 		DB:           db,
 		Log:          logger,
 		ToDos:        todos,
+		Tags:         tags,
+		Users:        users,
+		Reminders:    reminders,
+		Idempotency:  idempotency,
 		Interruption: make(chan os.Signal, 1),
 	}
 }
@@ -0,0 +1,106 @@
+package services
+
+import (
+	"container/heap"
+	"context"
+
+	"github.com/scriptdealer/to-do-go/known"
+)
+
+func (tds *TodoService) AddBlocker(owner, id, blocker int) error {
+	if id == blocker {
+		return errBlockItself
+	}
+
+	return tds.store.AddBlocker(owner, id, blocker)
+}
+
+func (tds *TodoService) RemoveBlocker(owner, id, blocker int) error {
+	return tds.store.RemoveBlocker(owner, id, blocker)
+}
+
+func (tds *TodoService) GetBlockers(owner, id int) ([]*known.TodoItem, error) {
+	return tds.store.GetBlockers(owner, id)
+}
+
+// Next sorts the undone items topologically with Kahn's algorithm: an item is ready
+// once all of its blockers are placed, and the ready item of the highest priority,
+// then the earliest in the manual order, goes first. Items of archived lists are left out,
+// along with the edges they take part in.
+func (tds *TodoService) Next(ctx context.Context, owner int) ([]*known.TodoItem, error) {
+	done := false
+	items, err := tds.store.GetAll(ctx, owner, known.TodoQuery{Done: &done, SortBy: known.SortByPosition})
+	if err != nil {
+		return nil, err
+	}
+
+	edges, err := tds.store.GetDependencies(owner)
+	if err != nil {
+		return nil, err
+	}
+
+	order := make(map[int]int, len(items))
+	for i, item := range items {
+		order[item.ID] = i
+	}
+
+	blocked := make(map[int]int, len(items))
+	unblocks := make(map[int][]int, len(items))
+	for _, edge := range edges {
+		_, listed := order[edge.ItemID]
+		_, blockerListed := order[edge.BlockerID]
+		if listed && blockerListed {
+			blocked[edge.ItemID]++
+			unblocks[edge.BlockerID] = append(unblocks[edge.BlockerID], edge.ItemID)
+		}
+	}
+
+	ready := &readyQueue{items: items, order: order}
+	for _, item := range items {
+		if blocked[item.ID] == 0 {
+			heap.Push(ready, item.ID)
+		}
+	}
+
+	sorted := make([]*known.TodoItem, 0, len(items))
+	for ready.Len() > 0 {
+		id := heap.Pop(ready).(int)
+		sorted = append(sorted, items[order[id]])
+		for _, next := range unblocks[id] {
+			blocked[next]--
+			if blocked[next] == 0 {
+				heap.Push(ready, next)
+			}
+		}
+	}
+
+	return sorted, nil
+}
+
+// readyQueue is a heap of item IDs, the most urgent on top; order indexes items,
+// which come in the manual order.
+type readyQueue struct {
+	ids   []int
+	items []*known.TodoItem
+	order map[int]int
+}
+
+func (q *readyQueue) Len() int { return len(q.ids) }
+
+func (q *readyQueue) Less(i, j int) bool {
+	a, b := q.order[q.ids[i]], q.order[q.ids[j]]
+	if q.items[a].Priority != q.items[b].Priority {
+		return q.items[a].Priority > q.items[b].Priority
+	}
+	return a < b
+}
+
+func (q *readyQueue) Swap(i, j int) { q.ids[i], q.ids[j] = q.ids[j], q.ids[i] }
+
+func (q *readyQueue) Push(x any) { q.ids = append(q.ids, x.(int)) }
+
+func (q *readyQueue) Pop() any {
+	last := q.ids[len(q.ids)-1]
+	q.ids = q.ids[:len(q.ids)-1]
+	return last
+}
@@ -0,0 +1,85 @@
+package services
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/scriptdealer/to-do-go/internal/storage"
+	"github.com/scriptdealer/to-do-go/known"
+)
+
+// maxIdempotencyKey is the length of the longest idempotency key kept.
+const maxIdempotencyKey = 255
+
+var (
+	ErrInvalidIdempotencyKey = known.NewError(known.ErrValidation, "idempotency keys are 1 to 255 printable ASCII characters")
+	ErrIdempotencyKeyReused  = known.NewError(known.ErrValidation, "the idempotency key was used for a different request, send a new key")
+	ErrIdempotencyKeyBusy    = known.NewError(known.ErrConflict, "the request with the idempotency key is still being processed, retry it later")
+)
+
+// IdempotencyLogic keeps the responses to the requests a user, whose ID is passed as owner,
+// makes with idempotency keys, so retries of a request are answered the same as the request.
+type IdempotencyLogic interface {
+	// Begin claims the key for the request with the given fingerprint and returns the request;
+	// once the key was used for that request, the request is returned along with its response.
+	Begin(owner int, key, fingerprint string) (*known.IdempotentRequest, error)
+	// Finish keeps the response to a request with a claimed key until the key expires.
+	Finish(request *known.IdempotentRequest) error
+	// Abandon releases a claimed key, when the response is not worth replaying.
+	Abandon(owner int, key string) error
+}
+
+// IdempotencyService keeps the keys and the responses for TTL from the first request.
+type IdempotencyService struct {
+	store storage.IdempotencyStore
+	Log   *slog.Logger
+	TTL   time.Duration
+	Now   func() time.Time
+}
+
+func NewIdempotencyService(db storage.IdempotencyStore, ttl time.Duration, logger *slog.Logger) *IdempotencyService {
+	return &IdempotencyService{store: db, Log: logger, TTL: ttl, Now: time.Now}
+}
+
+func (is *IdempotencyService) Begin(owner int, key, fingerprint string) (*known.IdempotentRequest, error) {
+	if !validIdempotencyKey(key) {
+		return nil, ErrInvalidIdempotencyKey
+	}
+
+	now := is.Now().UTC()
+	request := known.IdempotentRequest{OwnerID: owner, Key: key, Fingerprint: fingerprint, ExpiresAt: now.Add(is.TTL)}
+	kept, err := is.store.ClaimIdempotencyKey(&request, now)
+	if err != nil || kept == nil {
+		return &request, err
+	}
+
+	switch {
+	case kept.Fingerprint != fingerprint:
+		return nil, ErrIdempotencyKeyReused
+	case kept.Status == 0:
+		return nil, ErrIdempotencyKeyBusy
+	}
+
+	return kept, nil
+}
+
+func (is *IdempotencyService) Finish(request *known.IdempotentRequest) error {
+	return is.store.SaveIdempotentResponse(request)
+}
+
+func (is *IdempotencyService) Abandon(owner int, key string) error {
+	return is.store.ReleaseIdempotencyKey(owner, key)
+}
+
+func validIdempotencyKey(key string) bool {
+	if key == "" || len(key) > maxIdempotencyKey {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] < ' ' || key[i] > '~' {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,59 @@
+package services_test
+
+import (
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/scriptdealer/to-do-go/internal/services"
+	"github.com/scriptdealer/to-do-go/internal/storage"
+	"github.com/scriptdealer/to-do-go/known"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdempotency_Begin(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	keys := services.NewIdempotencyService(storage.NewMemoryStorage(logger), time.Hour, logger)
+	now := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	keys.Now = func() time.Time { return now }
+
+	for _, key := range []string{"", strings.Repeat("k", 256), "new\nline", "café"} {
+		_, err := keys.Begin(1, key, "post")
+		assert.ErrorIs(t, err, services.ErrInvalidIdempotencyKey, "key %q", key)
+	}
+
+	request, err := keys.Begin(1, "k1", "post")
+	require.NoError(t, err)
+	assert.Zero(t, request.Status)
+	assert.Equal(t, now.Add(time.Hour), request.ExpiresAt)
+
+	_, err = keys.Begin(1, "k1", "post")
+	assert.ErrorIs(t, err, services.ErrIdempotencyKeyBusy)
+	assert.ErrorIs(t, err, known.ErrConflict)
+
+	request.Status = 201
+	request.Body = []byte("created")
+	require.NoError(t, keys.Finish(request))
+	replayed, err := keys.Begin(1, "k1", "post")
+	require.NoError(t, err)
+	assert.Equal(t, 201, replayed.Status)
+	assert.Equal(t, []byte("created"), replayed.Body)
+
+	_, err = keys.Begin(1, "k1", "patch")
+	assert.ErrorIs(t, err, services.ErrIdempotencyKeyReused)
+
+	request, err = keys.Begin(2, "k1", "patch")
+	require.NoError(t, err)
+	assert.Zero(t, request.Status, "keys of other users are unrelated")
+	require.NoError(t, keys.Abandon(2, "k1"))
+	_, err = keys.Begin(2, "k1", "patch")
+	assert.NoError(t, err, "an abandoned key is claimed anew")
+
+	now = now.Add(time.Hour)
+	request, err = keys.Begin(1, "k1", "patch")
+	require.NoError(t, err)
+	assert.Zero(t, request.Status, "an expired key is claimed anew")
+}
@@ -0,0 +1,75 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/scriptdealer/to-do-go/internal/storage"
+)
+
+// JanitorInterval is how often the done and trashed items are looked through.
+const JanitorInterval = time.Hour
+
+// Janitor archives the items done longer than ArchiveAfter ago and empties the trash
+// of the items deleted longer than Retention ago; a zero period skips its part.
+// Both are idempotent, so several servers sharing the storage can run it side by side.
+type Janitor struct {
+	store        storage.ToDoStore
+	Log          *slog.Logger
+	ArchiveAfter time.Duration
+	Retention    time.Duration
+	Now          func() time.Time
+}
+
+func NewJanitor(db storage.ToDoStore, archiveAfter, retention time.Duration, logger *slog.Logger) *Janitor {
+	return &Janitor{store: db, Log: logger, ArchiveAfter: archiveAfter, Retention: retention, Now: time.Now}
+}
+
+// Run ticks every JanitorInterval until ctx is done.
+func (jn *Janitor) Run(ctx context.Context) {
+	jn.Log.Info("Janitor started", slog.Duration("archive_after", jn.ArchiveAfter), slog.Duration("retention", jn.Retention))
+	ticker := time.NewTicker(JanitorInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := jn.Tick(); err != nil {
+			jn.Log.Error("Janitor", slog.String("reason", err.Error()))
+		}
+
+		select {
+		case <-ctx.Done():
+			jn.Log.Info("Janitor stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Tick archives the items done before the archiving period and purges the items
+// trashed before the retention period.
+func (jn *Janitor) Tick() error {
+	now := jn.Now().UTC()
+
+	if jn.ArchiveAfter > 0 {
+		archived, err := jn.store.ArchiveDone(now.Add(-jn.ArchiveAfter))
+		if err != nil {
+			return err
+		}
+		if archived > 0 {
+			jn.Log.Info("Done items archived", slog.Int("items", archived))
+		}
+	}
+
+	if jn.Retention > 0 {
+		purged, err := jn.store.PurgeTrash(now.Add(-jn.Retention))
+		if err != nil {
+			return err
+		}
+		if purged > 0 {
+			jn.Log.Info("Trash emptied", slog.Int("items", purged))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,52 @@
+package services_test
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/scriptdealer/to-do-go/internal/services"
+	"github.com/scriptdealer/to-do-go/internal/storage"
+	"github.com/scriptdealer/to-do-go/known"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJanitor_Tick(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	db := storage.NewMemoryStorage(logger)
+	janitor := services.NewJanitor(db, 7*24*time.Hour, 30*24*time.Hour, logger)
+	completed := time.Now().UTC()
+
+	parent := known.TodoItem{OwnerID: 1, Title: "garden"}
+	require.NoError(t, db.Create(&parent))
+	require.NoError(t, db.Create(&known.TodoItem{OwnerID: 1, Title: "seeds", ParentID: parent.ID}))
+	require.NoError(t, db.Delete(1, parent.ID, true, 0))
+	done := known.TodoItem{OwnerID: 1, Title: "rent", Done: true, Completed: &completed}
+	require.NoError(t, db.Create(&done))
+
+	require.NoError(t, janitor.Tick())
+	trashed, err := db.GetAll(context.Background(), 1, known.TodoQuery{Trashed: true})
+	require.NoError(t, err)
+	assert.Len(t, trashed, 2, "the items are kept for the retention period")
+	active, err := db.GetAll(context.Background(), 1, known.TodoQuery{})
+	require.NoError(t, err)
+	assert.Len(t, active, 1)
+
+	janitor.Now = func() time.Time { return completed.Add(8 * 24 * time.Hour) }
+	require.NoError(t, janitor.Tick())
+	active, err = db.GetAll(context.Background(), 1, known.TodoQuery{})
+	require.NoError(t, err)
+	assert.Empty(t, active, "the done item is archived")
+	archived, err := db.GetAll(context.Background(), 1, known.TodoQuery{Archived: true})
+	require.NoError(t, err)
+	assert.Len(t, archived, 1)
+
+	janitor.Now = func() time.Time { return completed.Add(31 * 24 * time.Hour) }
+	require.NoError(t, janitor.Tick())
+	trashed, err = db.GetAll(context.Background(), 1, known.TodoQuery{Trashed: true})
+	require.NoError(t, err)
+	assert.Empty(t, trashed)
+}
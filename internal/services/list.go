@@ -0,0 +1,105 @@
+package services
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/scriptdealer/to-do-go/known"
+)
+
+const maxListNameLength = 100
+
+var (
+	errInvalidListName = known.NewError(known.ErrValidation, "list names are 1 to 100 characters long")
+	errListArchived    = known.NewError(known.ErrConflict, "the list is archived, unarchive it to add items")
+	errListUpdate      = known.NewError(known.ErrValidation, "name or archived is required")
+)
+
+func (tds *TodoService) CreateList(owner int, name string) (*known.List, error) {
+	name, err := listName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	list := known.List{OwnerID: owner, Name: name}
+	if err := tds.store.CreateList(&list); err != nil {
+		return nil, err
+	}
+
+	return &list, nil
+}
+
+func (tds *TodoService) GetList(owner, id int) (*known.List, error) {
+	return tds.store.GetList(owner, id)
+}
+
+func (tds *TodoService) GetLists(owner int, archived bool) ([]*known.List, error) {
+	return tds.store.GetLists(owner, archived)
+}
+
+func (tds *TodoService) UpdateList(owner, id int, name string, archived *bool) error {
+	if name == "" && archived == nil {
+		return errListUpdate
+	}
+	if name != "" {
+		var err error
+		if name, err = listName(name); err != nil {
+			return err
+		}
+	}
+	// the list is looked up first, so a missing one changes nothing
+	if _, err := tds.store.GetList(owner, id); err != nil {
+		return err
+	}
+
+	if name != "" {
+		if err := tds.store.RenameList(owner, id, name); err != nil {
+			return err
+		}
+	}
+	if archived != nil {
+		return tds.store.ArchiveList(owner, id, *archived)
+	}
+
+	return nil
+}
+
+func (tds *TodoService) DeleteList(owner, id int) error {
+	return tds.store.DeleteList(owner, id)
+}
+
+func (tds *TodoService) MoveToList(owner, id, list int) error {
+	if err := tds.openList(owner, list); err != nil {
+		return err
+	}
+
+	return tds.store.MoveToList(owner, id, list)
+}
+
+// openList checks that items can go into the list; the inbox, a zero list, is always open.
+func (tds *TodoService) openList(owner, id int) error {
+	if id == 0 {
+		return nil
+	}
+
+	list, err := tds.store.GetList(owner, id)
+	if err != nil {
+		return err
+	}
+
+	if list.Archived {
+		return errListArchived
+	}
+
+	return nil
+}
+
+// listName keeps list names free of surrounding spaces, unlike tags they keep their case.
+func listName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > maxListNameLength {
+		return "", errInvalidListName
+	}
+
+	return name, nil
+}
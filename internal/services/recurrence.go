@@ -0,0 +1,291 @@
+package services
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/scriptdealer/to-do-go/known"
+)
+
+// Recurrence frequencies; the subset of RFC 5545 supported is FREQ, INTERVAL,
+// BYDAY for weekly rules, one BYMONTHDAY for monthly rules and X-FROM=COMPLETION,
+// an extension counting the days of a daily rule from the completion instead of the due date.
+const (
+	freqDaily   = "DAILY"
+	freqWeekly  = "WEEKLY"
+	freqMonthly = "MONTHLY"
+)
+
+const (
+	maxInterval = 1000
+	// maxSteps bounds the search of a monthly rule for a month having its day.
+	maxSteps = 1000
+)
+
+var errRecurrence = known.NewError(known.ErrValidation,
+	"unsupported recurrence, expected FREQ=DAILY, WEEKLY or MONTHLY with an optional INTERVAL, "+
+		"BYDAY for weekly rules, BYMONTHDAY for monthly rules or X-FROM=COMPLETION for daily rules")
+
+var weekdays = []string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+type recurrence struct {
+	freq     string
+	interval int
+	// byDay holds the weekdays of a weekly rule, in the order of an ISO week.
+	byDay []time.Weekday
+	// byMonthDay is the day of a monthly rule, a negative one counts from the end of the month.
+	byMonthDay int
+	// afterCompletion counts a daily rule from the completion.
+	afterCompletion bool
+}
+
+// parseRecurrence reads a rule like FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH, with or without
+// the RRULE: prefix and in any case.
+func parseRecurrence(rule string) (*recurrence, error) {
+	rule = strings.ToUpper(strings.TrimSpace(rule))
+	rule = strings.TrimPrefix(rule, "RRULE:")
+
+	r := recurrence{interval: 1}
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(rule, ";") {
+		name, value, found := strings.Cut(part, "=")
+		if !found || seen[name] {
+			return nil, errRecurrence
+		}
+		seen[name] = true
+
+		switch name {
+		case "FREQ":
+			r.freq = value
+		case "INTERVAL":
+			interval, err := strconv.Atoi(value)
+			if err != nil || interval < 1 || interval > maxInterval {
+				return nil, errRecurrence
+			}
+			r.interval = interval
+		case "BYDAY":
+			for _, day := range strings.Split(value, ",") {
+				weekday := slices.Index(weekdays, day)
+				if weekday < 0 {
+					return nil, errRecurrence
+				}
+				if !slices.Contains(r.byDay, time.Weekday(weekday)) {
+					r.byDay = append(r.byDay, time.Weekday(weekday))
+				}
+			}
+			slices.SortFunc(r.byDay, func(a, b time.Weekday) int { return isoWeekday(a) - isoWeekday(b) })
+		case "BYMONTHDAY":
+			day, err := strconv.Atoi(value)
+			if err != nil || day == 0 || day < -31 || day > 31 {
+				return nil, errRecurrence
+			}
+			r.byMonthDay = day
+		case "X-FROM":
+			if value != "COMPLETION" {
+				return nil, errRecurrence
+			}
+			r.afterCompletion = true
+		default:
+			return nil, errRecurrence
+		}
+	}
+
+	switch {
+	case r.freq != freqDaily && r.freq != freqWeekly && r.freq != freqMonthly,
+		r.byDay != nil && r.freq != freqWeekly,
+		r.byMonthDay != 0 && r.freq != freqMonthly,
+		r.afterCompletion && r.freq != freqDaily:
+		return nil, errRecurrence
+	}
+
+	return &r, nil
+}
+
+// String writes the rule in its canonical form, leaving out the default interval.
+func (r *recurrence) String() string {
+	parts := []string{"FREQ=" + r.freq}
+	if r.interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.interval))
+	}
+	if len(r.byDay) > 0 {
+		days := make([]string, len(r.byDay))
+		for i, day := range r.byDay {
+			days[i] = weekdays[day]
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if r.byMonthDay != 0 {
+		parts = append(parts, "BYMONTHDAY="+strconv.Itoa(r.byMonthDay))
+	}
+	if r.afterCompletion {
+		parts = append(parts, "X-FROM=COMPLETION")
+	}
+
+	return strings.Join(parts, ";")
+}
+
+// next returns the due date of the occurrence following one due at due, or at
+// the completion without a due date, and completed at done. Occurrences missed
+// by a late completion are skipped, the next one is always after done.
+// It reports false for a monthly rule whose day never comes.
+func (r *recurrence) next(due *time.Time, done time.Time) (time.Time, bool) {
+	done = done.UTC()
+	if due == nil {
+		due = &done
+	}
+	from := due.UTC()
+
+	if r.afterCompletion {
+		day := time.Date(done.Year(), done.Month(), done.Day(), from.Hour(), from.Minute(), from.Second(), 0, time.UTC)
+		return day.AddDate(0, 0, r.interval), true
+	}
+
+	for {
+		var found bool
+		if from, found = r.step(from); !found {
+			return time.Time{}, false
+		}
+		if from.After(done) {
+			return from, true
+		}
+	}
+}
+
+// step returns the first occurrence after t, keeping its time of day.
+func (r *recurrence) step(t time.Time) (time.Time, bool) {
+	switch r.freq {
+	case freqWeekly:
+		if len(r.byDay) == 0 {
+			return t.AddDate(0, 0, 7*r.interval), true
+		}
+		for _, day := range r.byDay {
+			if isoWeekday(day) > isoWeekday(t.Weekday()) {
+				return t.AddDate(0, 0, isoWeekday(day)-isoWeekday(t.Weekday())), true
+			}
+		}
+		// the first day of the week interval weeks later, weeks start on Monday
+		monday := t.AddDate(0, 0, 1-isoWeekday(t.Weekday()))
+		return monday.AddDate(0, 0, 7*r.interval+isoWeekday(r.byDay[0])-1), true
+	case freqMonthly:
+		day := r.byMonthDay
+		if day == 0 {
+			day = t.Day()
+		}
+		year, month := t.Year(), t.Month()
+		for i := 0; i < maxSteps; i++ {
+			if date, found := monthDay(year, month, day); found {
+				candidate := time.Date(year, month, date, t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
+				if candidate.After(t) {
+					return candidate, true
+				}
+			}
+			month += time.Month(r.interval)
+		}
+		return time.Time{}, false
+	default:
+		return t.AddDate(0, 0, r.interval), true
+	}
+}
+
+// monthDay resolves a day of month, negative ones counting from its end;
+// months lacking the day are skipped like RFC 5545 does.
+func monthDay(year int, month time.Month, day int) (int, bool) {
+	length := time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	if day < 0 {
+		day += length + 1
+	}
+
+	return day, day >= 1 && day <= length
+}
+
+// isoWeekday numbers the days from Monday, 1, to Sunday, 7.
+func isoWeekday(day time.Weekday) int {
+	return (int(day)+6)%7 + 1
+}
+
+// recurrenceRule validates a rule and returns it in the canonical form, an empty rule being no recurrence.
+func recurrenceRule(rule string) (string, error) {
+	if strings.TrimSpace(rule) == "" {
+		return "", nil
+	}
+
+	r, err := parseRecurrence(rule)
+	if err != nil {
+		return "", err
+	}
+
+	return r.String(), nil
+}
+
+// repeat creates the occurrence following item, which was completed at done, with the rule of item, and returns it,
+// nil once the series is over; the new occurrence keeps the time between the start and the due date of the completed one.
+func (tds *TodoService) repeat(item *known.TodoItem, done time.Time) (*known.TodoItem, error) {
+	r, err := parseRecurrence(item.Recurrence)
+	if err != nil {
+		return nil, err
+	}
+
+	due, found := r.next(item.Due, done)
+	if !found {
+		return nil, nil
+	}
+	// dates are kept at a second precision, see normalize
+	due = due.Truncate(time.Second)
+
+	next := known.TodoItem{
+		OwnerID:     item.OwnerID,
+		Title:       item.Title,
+		Description: item.Description,
+		Priority:    item.Priority,
+		ListID:      item.ListID,
+		ParentID:    item.ParentID,
+		Created:     done,
+		Due:         &due,
+		Tags:        item.Tags,
+		Recurrence:  item.Recurrence,
+		SeriesID:    item.SeriesID,
+	}
+	if next.SeriesID == 0 {
+		next.SeriesID = item.ID
+	}
+	if item.Start != nil {
+		base := done
+		if item.Due != nil {
+			base = *item.Due
+		}
+		start := due.Add(item.Start.Sub(base)).Truncate(time.Second)
+		next.Start = &start
+	}
+	for _, entry := range item.Checklist {
+		next.Checklist = append(next.Checklist, known.ChecklistEntry{Text: entry.Text})
+	}
+
+	if err := tds.store.Create(&next); err != nil {
+		return nil, err
+	}
+
+	return &next, nil
+}
+
+// Occurrences lists the items of the series the item id belongs to, by due date unless asked otherwise.
+func (tds *TodoService) Occurrences(ctx context.Context, owner, id int, query known.TodoQuery) (*known.TodoPage, error) {
+	item, err := tds.store.GetOne(owner, id)
+	if err != nil {
+		return nil, err
+	}
+
+	query.SeriesID = item.SeriesID
+	if query.SeriesID == 0 {
+		query.SeriesID = item.ID
+	}
+	// completed occurrences get archived, the history of the series keeps them
+	query.AnyArchived = true
+	if query.SortBy == "" {
+		query.SortBy = known.SortByDue
+	}
+
+	return tds.GetAll(ctx, owner, query)
+}
@@ -0,0 +1,165 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/scriptdealer/to-do-go/internal/notify"
+	"github.com/scriptdealer/to-do-go/internal/storage"
+	"github.com/scriptdealer/to-do-go/known"
+)
+
+const (
+	// maxMinutesBefore lets an offset reminder go off up to a year before the due date.
+	maxMinutesBefore = 366 * 24 * 60
+	// MaxDeliveryAttempts is how many times the delivery of a reminder is tried before it is given up.
+	MaxDeliveryAttempts = 5
+	// reminderBatch bounds the reminders sent out on one tick, the rest wait for the next one.
+	reminderBatch = 100
+	// ReminderLease is how long a claimed reminder is left to its server before another one takes it up.
+	ReminderLease = 5 * time.Minute
+)
+
+var errReminder = known.NewError(known.ErrValidation, "a reminder needs either an absolute time at or minutes_before from 0 to 527040")
+
+// ReminderLogic manages the reminders of the items of a user, whose ID is passed as owner.
+type ReminderLogic interface {
+	// Create adds a reminder going off at reminder.At, or reminder.MinutesBefore the due date of the item id.
+	Create(owner, id int, reminder known.Reminder) (*known.Reminder, error)
+	GetAll(owner, id int) ([]*known.Reminder, error)
+	Delete(owner, id, reminder int) error
+}
+
+type ReminderService struct {
+	store storage.ReminderStore
+	Log   *slog.Logger
+}
+
+func NewReminderService(db storage.ReminderStore, logger *slog.Logger) *ReminderService {
+	return &ReminderService{store: db, Log: logger}
+}
+
+func (rs *ReminderService) Create(owner, id int, reminder known.Reminder) (*known.Reminder, error) {
+	if (reminder.At == nil) == (reminder.MinutesBefore == nil) {
+		return nil, errReminder
+	}
+	if reminder.MinutesBefore != nil && (*reminder.MinutesBefore < 0 || *reminder.MinutesBefore > maxMinutesBefore) {
+		return nil, errReminder
+	}
+	if reminder.At != nil {
+		at := reminder.At.UTC().Truncate(time.Second)
+		reminder.At = &at
+	}
+
+	created := known.Reminder{TodoID: id, OwnerID: owner, At: reminder.At, MinutesBefore: reminder.MinutesBefore}
+	if err := rs.store.CreateReminder(&created); err != nil {
+		return nil, err
+	}
+
+	return &created, nil
+}
+
+func (rs *ReminderService) GetAll(owner, id int) ([]*known.Reminder, error) {
+	return rs.store.GetReminders(owner, id)
+}
+
+func (rs *ReminderService) Delete(owner, id, reminder int) error {
+	return rs.store.DeleteReminder(owner, id, reminder)
+}
+
+// Scheduler sends the due reminders out every Interval. Of several servers sharing
+// the storage, only the one holding the lock sends them. A reminder is claimed in the
+// storage for its delivery and only marked as sent once delivered, so it goes out once
+// even across restarts: the claim of a server stopping midway lapses after Lease and
+// the reminder is sent on a later tick. A failed delivery is taken back and tried again.
+type Scheduler struct {
+	store    storage.Backend
+	notifier notify.Notifier
+	Log      *slog.Logger
+	Interval time.Duration
+	Lease    time.Duration
+	Now      func() time.Time
+}
+
+func NewScheduler(db storage.Backend, notifier notify.Notifier, interval time.Duration, logger *slog.Logger) *Scheduler {
+	return &Scheduler{store: db, notifier: notifier, Log: logger, Interval: interval, Lease: ReminderLease, Now: time.Now}
+}
+
+// Run ticks until ctx is done.
+func (sc *Scheduler) Run(ctx context.Context) {
+	sc.Log.Info("Reminder scheduler started", slog.Duration("interval", sc.Interval))
+	ticker := time.NewTicker(sc.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := sc.Tick(ctx); err != nil {
+			sc.Log.Error("Reminder scheduler", slog.String("reason", err.Error()))
+		}
+
+		select {
+		case <-ctx.Done():
+			sc.Log.Info("Reminder scheduler stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Tick sends out the reminders due by now, provided this server leads.
+func (sc *Scheduler) Tick(ctx context.Context) error {
+	release, leading, err := sc.store.Lead(ctx)
+	if err != nil || !leading {
+		return err
+	}
+	defer release()
+
+	now := sc.Now().UTC()
+	lapsed := now.Add(-sc.Lease)
+	reminders, err := sc.store.DueReminders(now, lapsed, MaxDeliveryAttempts, reminderBatch)
+	if err != nil {
+		return err
+	}
+
+	for _, reminder := range reminders {
+		if ctx.Err() != nil {
+			return nil
+		}
+		claimed, err := sc.store.ClaimReminder(reminder.ID, now, lapsed)
+		if err != nil {
+			return err
+		}
+		if !claimed {
+			continue
+		}
+
+		if err := sc.deliver(ctx, reminder); err != nil {
+			sc.Log.Warn("Reminder delivery failed",
+				slog.Int("reminder", reminder.ID),
+				slog.Int("attempt", reminder.Attempts+1),
+				slog.String("reason", err.Error()))
+			if err := sc.store.ReleaseReminder(reminder.ID); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := sc.store.SentReminder(reminder.ID, sc.Now().UTC()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (sc *Scheduler) deliver(ctx context.Context, reminder *known.Reminder) error {
+	item, err := sc.store.GetOne(reminder.OwnerID, reminder.TodoID)
+	if err != nil {
+		return err
+	}
+	user, err := sc.store.GetUser(reminder.OwnerID)
+	if err != nil {
+		return err
+	}
+
+	return sc.notifier.Notify(ctx, notify.Notice{Reminder: reminder, Item: item, User: user})
+}
@@ -0,0 +1,172 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/scriptdealer/to-do-go/internal/notify"
+	"github.com/scriptdealer/to-do-go/internal/services"
+	"github.com/scriptdealer/to-do-go/internal/storage"
+	"github.com/scriptdealer/to-do-go/known"
+	"github.com/stretchr/testify/suite"
+)
+
+// recordingNotifier keeps the notices it is given, failing while failing is set.
+type recordingNotifier struct {
+	notices []notify.Notice
+	failing bool
+}
+
+func (rn *recordingNotifier) Notify(ctx context.Context, notice notify.Notice) error {
+	if rn.failing {
+		return errors.New("mail server down")
+	}
+	rn.notices = append(rn.notices, notice)
+	return nil
+}
+
+type SchedulerSuite struct {
+	suite.Suite
+
+	db        *storage.InMemoryStorage
+	notifier  *recordingNotifier
+	reminders *services.ReminderService
+	scheduler *services.Scheduler
+	now       time.Time
+	owner     int
+}
+
+func TestScheduler(t *testing.T) {
+	suite.Run(t, &SchedulerSuite{})
+}
+
+func (s *SchedulerSuite) SetupTest() {
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	s.db = storage.NewMemoryStorage(logger)
+	s.notifier = &recordingNotifier{}
+	s.reminders = services.NewReminderService(s.db, logger)
+	s.scheduler = services.NewScheduler(s.db, s.notifier, time.Minute, logger)
+	s.now = time.Date(2024, 5, 6, 12, 0, 0, 0, time.UTC)
+	s.scheduler.Now = func() time.Time { return s.now }
+
+	user := known.User{Name: "Jane", Username: "jane", PasswordHash: "x"}
+	s.NoError(s.db.CreateUser(&user))
+	s.owner = user.ID
+}
+
+func (s *SchedulerSuite) item(title string, due *time.Time) int {
+	item := known.TodoItem{OwnerID: s.owner, Title: title, Due: due}
+	s.NoError(s.db.Create(&item))
+	return item.ID
+}
+
+func (s *SchedulerSuite) TestCreate_Validation() {
+	id := s.item("rent", nil)
+	at := s.now
+	minutes := 10
+	tooEarly := 400 * 24 * 60
+
+	for _, reminder := range []known.Reminder{{}, {At: &at, MinutesBefore: &minutes}, {MinutesBefore: &tooEarly}} {
+		_, err := s.reminders.Create(s.owner, id, reminder)
+		s.ErrorIs(err, known.ErrValidation)
+	}
+
+	_, err := s.reminders.Create(s.owner+1, id, known.Reminder{At: &at})
+	s.ErrorIs(err, known.ErrNotFound)
+
+	reminder, err := s.reminders.Create(s.owner, id, known.Reminder{MinutesBefore: &minutes})
+	s.NoError(err)
+	s.Nil(reminder.FireAt, "an offset from an item without a due date never fires")
+}
+
+func (s *SchedulerSuite) TestTick_FiresOnce() {
+	due := s.now.Add(20 * time.Minute)
+	id := s.item("rent", &due)
+	minutes := 30
+	_, err := s.reminders.Create(s.owner, id, known.Reminder{MinutesBefore: &minutes})
+	s.NoError(err)
+	later := s.now.Add(time.Hour)
+	_, err = s.reminders.Create(s.owner, id, known.Reminder{At: &later})
+	s.NoError(err)
+
+	s.NoError(s.scheduler.Tick(context.Background()))
+	s.NoError(s.scheduler.Tick(context.Background()))
+	s.Require().Len(s.notifier.notices, 1)
+	s.Equal("rent", s.notifier.notices[0].Item.Title)
+	s.Equal("jane", s.notifier.notices[0].User.Username)
+
+	s.now = s.now.Add(2 * time.Hour)
+	s.NoError(s.scheduler.Tick(context.Background()))
+	s.Len(s.notifier.notices, 2)
+
+	reminders, err := s.reminders.GetAll(s.owner, id)
+	s.NoError(err)
+	for _, reminder := range reminders {
+		s.NotNil(reminder.SentAt)
+	}
+}
+
+func (s *SchedulerSuite) TestTick_RetriesFailures() {
+	id := s.item("rent", nil)
+	at := s.now.Add(-time.Minute)
+	_, err := s.reminders.Create(s.owner, id, known.Reminder{At: &at})
+	s.NoError(err)
+
+	s.notifier.failing = true
+	s.NoError(s.scheduler.Tick(context.Background()))
+	s.notifier.failing = false
+	s.NoError(s.scheduler.Tick(context.Background()))
+	s.Len(s.notifier.notices, 1, "a failed delivery is tried again")
+
+	s.notifier.notices = nil
+	id = s.item("taxes", nil)
+	_, err = s.reminders.Create(s.owner, id, known.Reminder{At: &at})
+	s.NoError(err)
+	s.notifier.failing = true
+	for i := 0; i < services.MaxDeliveryAttempts+1; i++ {
+		s.NoError(s.scheduler.Tick(context.Background()))
+	}
+	s.notifier.failing = false
+	s.NoError(s.scheduler.Tick(context.Background()))
+	s.Empty(s.notifier.notices, "the delivery is given up after too many attempts")
+}
+
+func (s *SchedulerSuite) TestTick_TakesOverLapsedClaims() {
+	id := s.item("rent", nil)
+	at := s.now.Add(-time.Minute)
+	reminder, err := s.reminders.Create(s.owner, id, known.Reminder{At: &at})
+	s.NoError(err)
+
+	// a server claimed the reminder and stopped before delivering it
+	claimed, err := s.db.ClaimReminder(reminder.ID, s.now, s.now.Add(-services.ReminderLease))
+	s.NoError(err)
+	s.True(claimed)
+	s.NoError(s.scheduler.Tick(context.Background()))
+	s.Empty(s.notifier.notices, "the claim is left to its server for a while")
+	reminders, err := s.reminders.GetAll(s.owner, id)
+	s.NoError(err)
+	s.Nil(reminders[0].SentAt, "a claimed reminder is not sent yet")
+
+	s.now = s.now.Add(services.ReminderLease + time.Minute)
+	s.NoError(s.scheduler.Tick(context.Background()))
+	s.NoError(s.scheduler.Tick(context.Background()))
+	s.Len(s.notifier.notices, 1)
+	reminders, err = s.reminders.GetAll(s.owner, id)
+	s.NoError(err)
+	s.Equal(&s.now, reminders[0].SentAt)
+}
+
+func (s *SchedulerSuite) TestTick_SkipsDoneItems() {
+	id := s.item("rent", nil)
+	at := s.now.Add(-time.Minute)
+	_, err := s.reminders.Create(s.owner, id, known.Reminder{At: &at})
+	s.NoError(err)
+	s.NoError(s.db.Update(&known.TodoItem{ID: id, OwnerID: s.owner, Title: "rent", Done: true}))
+
+	s.NoError(s.scheduler.Tick(context.Background()))
+	s.Empty(s.notifier.notices)
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"log/slog"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/scriptdealer/to-do-go/internal/storage"
+	"github.com/scriptdealer/to-do-go/known"
+)
+
+const maxTagLength = 50
+
+var (
+	errInvalidTag    = known.NewError(known.ErrValidation, "tag names are 1 to 50 characters long")
+	errMergeToItself = known.NewError(known.ErrValidation, "a tag cannot be merged into itself")
+)
+
+// TagLogic manages the tags of a user, whose ID is passed as owner.
+type TagLogic interface {
+	Create(owner int, name string) (*known.Tag, error)
+	GetAll(owner int) ([]*known.Tag, error)
+	Rename(owner, id int, name string) error
+	// Merge relabels the items of the tag from with the tag into and deletes the former.
+	Merge(owner, from, into int) error
+	Delete(owner, id int) error
+}
+
+type TagService struct {
+	store storage.TagStore
+	Log   *slog.Logger
+}
+
+func NewTagService(db storage.TagStore, logger *slog.Logger) *TagService {
+	return &TagService{store: db, Log: logger}
+}
+
+func (ts *TagService) Create(owner int, name string) (*known.Tag, error) {
+	name, err := tagName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	tag := known.Tag{OwnerID: owner, Name: name}
+	if err := ts.store.CreateTag(&tag); err != nil {
+		return nil, err
+	}
+
+	return &tag, nil
+}
+
+func (ts *TagService) GetAll(owner int) ([]*known.Tag, error) {
+	return ts.store.GetTags(owner)
+}
+
+func (ts *TagService) Rename(owner, id int, name string) error {
+	name, err := tagName(name)
+	if err != nil {
+		return err
+	}
+
+	return ts.store.RenameTag(owner, id, name)
+}
+
+func (ts *TagService) Merge(owner, from, into int) error {
+	if from == into {
+		return errMergeToItself
+	}
+
+	return ts.store.MergeTags(owner, from, into)
+}
+
+func (ts *TagService) Delete(owner, id int) error {
+	return ts.store.DeleteTag(owner, id)
+}
+
+// tagName makes tag names case-insensitive and free of surrounding spaces.
+func tagName(name string) (string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" || utf8.RuneCountInString(name) > maxTagLength {
+		return "", errInvalidTag
+	}
+
+	return name, nil
+}
+
+// tagNames normalizes a list of tag names, dropping the repeated ones.
+func tagNames(names []string) ([]string, error) {
+	var result []string
+	seen := map[string]bool{}
+	for _, name := range names {
+		name, err := tagName(name)
+		if err != nil {
+			return nil, err
+		}
+
+		if !seen[name] {
+			seen[name] = true
+			result = append(result, name)
+		}
+	}
+
+	return result, nil
+}
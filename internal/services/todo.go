@@ -5,55 +5,454 @@ package services
 import (
 	"context"
 	"log/slog"
+	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/scriptdealer/to-do-go/internal/storage"
 	"github.com/scriptdealer/to-do-go/known"
 )
 
+// TodoLogic manages todo items on behalf of a user, whose ID is passed as owner.
 type TodoLogic interface {
-	Create(title, description string, done bool) error
-	Get(id int) (*known.TodoItem, error)
-	GetAll(ctx context.Context) ([]*known.TodoItem, error)
-	Update(id int, title, description string, done bool) error
-	Delete(id int) error
+	// Create returns the item as stored, along with its ID, position and version.
+	Create(owner int, item known.TodoItem) (*known.TodoItem, error)
+	Get(owner, id int) (*known.TodoItem, error)
+	GetAll(ctx context.Context, owner int, query known.TodoQuery) (*known.TodoPage, error)
+	// GetDue lists one of the due views, whose days and weeks start in loc.
+	GetDue(ctx context.Context, owner int, view string, loc *time.Location, query known.TodoQuery) (*known.TodoPage, error)
+	// Update refuses to overwrite the item unless item.Version is zero or its current version,
+	// and returns the item as stored.
+	Update(owner, id int, item known.TodoItem, opts UpdateOptions) (*known.TodoItem, error)
+	// Move puts the item id right before or after the anchor item in the manual order.
+	Move(owner, id, anchor int, before bool) error
+	// SetParent makes the item id a subtask of parent, or a top level item for a zero parent.
+	SetParent(owner, id, parent int) error
+	// Delete moves the item to the trash.
+	Delete(owner, id int, opts DeleteOptions) error
+	// GetTrash lists the trashed items, the most recently trashed first unless sorted otherwise.
+	GetTrash(ctx context.Context, owner int, query known.TodoQuery) (*known.TodoPage, error)
+	// Restore takes the item out of the trash along with the subtasks trashed with it.
+	Restore(owner, id int) error
+	// Purge deletes a trashed item for good.
+	Purge(owner, id int) error
+	// GetArchive lists the archived items, the most recently completed first unless sorted otherwise.
+	GetArchive(ctx context.Context, owner int, query known.TodoQuery) (*known.TodoPage, error)
+	// Unarchive brings an archived item back into the active listings.
+	Unarchive(owner, id int) error
+	// History lists the revisions of the item id, trashed or not, the oldest first.
+	History(owner, id int) ([]*known.Revision, error)
+	// Revert sets the fields of the item id back to what they were right after the given revision,
+	// which records a revision of its own.
+	Revert(owner, id, revision int) error
+
+	CreateList(owner int, name string) (*known.List, error)
+	GetList(owner, id int) (*known.List, error)
+	// GetLists returns the owner's lists in the order of creation, the archived ones on request.
+	GetLists(owner int, archived bool) ([]*known.List, error)
+	// UpdateList renames the list unless name is empty, and hides or shows it along with its items
+	// unless archived is nil; both are checked before the list is changed.
+	UpdateList(owner, id int, name string, archived *bool) error
+	DeleteList(owner, id int) error
+	// MoveToList puts the item id into a list, or back into the inbox for a zero list.
+	MoveToList(owner, id, list int) error
+
+	// AddBlocker makes the item id blocked by the item blocker, refusing cycles.
+	AddBlocker(owner, id, blocker int) error
+	RemoveBlocker(owner, id, blocker int) error
+	GetBlockers(owner, id int) ([]*known.TodoItem, error)
+	// Next orders the undone items so that every item comes after its blockers.
+	Next(ctx context.Context, owner int) ([]*known.TodoItem, error)
+	// Occurrences lists the items of the series a recurring item belongs to, the completed ones included.
+	Occurrences(ctx context.Context, owner, id int, query known.TodoQuery) (*known.TodoPage, error)
+}
+
+// UpdateOptions tune how an update treats the items related to the updated one.
+type UpdateOptions struct {
+	// Cascade completes the subtasks and the checklist of an item marked as done.
+	Cascade bool
+	// Force marks an item as done even though some of its blockers are not.
+	Force bool
 }
 
+// DeleteOptions tune how an item is trashed.
+type DeleteOptions struct {
+	// Cascade trashes the subtasks along with the item, which is refused otherwise.
+	Cascade bool
+	// Version, unless zero, must be the current version of the item.
+	Version int
+}
+
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 500
+)
+
+// Due views: undone items past their due time, and items due on the current day or ISO week.
+const (
+	ViewOverdue = "overdue"
+	ViewToday   = "today"
+	ViewWeek    = "week"
+)
+
+var (
+	errUnknownSort   = known.NewError(known.ErrValidation, "unknown sort field, expected position, priority, id, title, created, due or completed")
+	errCursorOrder   = known.NewError(known.ErrValidation, "the cursor belongs to a listing in another order")
+	errMoveToItself  = known.NewError(known.ErrValidation, "an item cannot be moved next to itself")
+	errUnknownView   = known.NewError(known.ErrValidation, "unknown view, expected overdue, today or week")
+	errStartAfterDue = known.NewError(known.ErrValidation, "start date is after the due date")
+	errNestInItself  = known.NewError(known.ErrValidation, "an item cannot be a subtask of itself")
+	errChecklist     = known.NewError(known.ErrValidation, "checklists have at most 100 entries of 1 to 200 characters")
+	errBlockItself   = known.NewError(known.ErrValidation, "an item cannot block itself")
+	errBlocked       = known.NewError(known.ErrConflict, "the item is blocked by undone items, complete them first or force it")
+	errNoRevision    = known.NewError(known.ErrNotFound, "no such revision of the item")
+)
+
+const (
+	maxChecklistEntries = 100
+	maxChecklistText    = 200
+)
+
 type TodoService struct {
 	store storage.ToDoStore
 	Log   *slog.Logger
+	Now   func() time.Time
 }
 
 func NewToDoService(db storage.ToDoStore, logger *slog.Logger) *TodoService {
-	return &TodoService{store: db, Log: logger}
+	return &TodoService{store: db, Log: logger, Now: time.Now}
+}
+
+// Create stores the title, description, state and dates of item as a new item of owner,
+// in the inbox or in the list named by item.ListID.
+func (tds *TodoService) Create(owner int, item known.TodoItem) (*known.TodoItem, error) {
+	if err := normalize(&item); err != nil {
+		return nil, err
+	}
+	if err := tds.openList(owner, item.ListID); err != nil {
+		return nil, err
+	}
+
+	item.ID = 0
+	item.Version = 0
+	item.SeriesID = 0
+	item.OwnerID = owner
+	item.Created = tds.Now().UTC()
+	item.Completed, item.Archived = nil, nil
+	if item.Done {
+		item.Completed = &item.Created
+	}
+	if err := tds.store.Create(&item); err != nil {
+		return nil, err
+	}
+
+	// read back, so the item is the same as listed
+	return tds.store.GetOne(owner, item.ID)
+}
+
+// Update replaces the title, description, state, dates, checklist and recurrence of the item id with those of item.
+// Completing a recurring item creates its next occurrence, which takes the rule over from it.
+func (tds *TodoService) Update(owner, id int, item known.TodoItem, opts UpdateOptions) (*known.TodoItem, error) {
+	if err := normalize(&item); err != nil {
+		return nil, err
+	}
+
+	if item.Done && !opts.Force {
+		blockers, err := tds.store.GetBlockers(owner, id)
+		if err != nil {
+			return nil, err
+		}
+		for _, blocker := range blockers {
+			if !blocker.Done {
+				return nil, errBlocked
+			}
+		}
+	}
+
+	var repeating bool
+	if item.Done && item.Recurrence != "" {
+		current, err := tds.store.GetOne(owner, id)
+		if err != nil {
+			return nil, err
+		}
+		repeating = !current.Done
+		item.SeriesID = current.SeriesID
+		// refused before the next occurrence is created rather than after
+		if repeating && item.Version != 0 && item.Version != current.Version {
+			return nil, storage.ErrStaleVersion
+		}
+		// an update naming no version still has to find the item as read here,
+		// or two concurrent completions would both go on with the series
+		if repeating {
+			item.Version = current.Version
+		}
+	}
+
+	cascade := opts.Cascade && item.Done
+	if cascade {
+		for i := range item.Checklist {
+			item.Checklist[i].Done = true
+		}
+	}
+
+	item.ID = id
+	item.OwnerID = owner
+	// the storage keeps the time of an earlier completion
+	item.Completed, item.Archived = nil, nil
+	now := tds.Now().UTC()
+	if item.Done {
+		item.Completed = &now
+	}
+	var next *known.TodoItem
+	if repeating {
+		// the next occurrence is created first, so the series goes on unless the rule stays with the item
+		var err error
+		if next, err = tds.repeat(&item, now); err != nil {
+			return nil, err
+		}
+		item.Recurrence = ""
+	}
+	if err := tds.store.Update(&item); err != nil {
+		if next != nil {
+			tds.takeBack(owner, next.ID)
+		}
+		return nil, err
+	}
+
+	if cascade {
+		if err := tds.store.CompleteSubtasks(owner, id); err != nil {
+			return nil, err
+		}
+	}
+
+	return tds.store.GetOne(owner, id)
+}
+
+// takeBack purges an item created along with a change that failed.
+func (tds *TodoService) takeBack(owner, id int) {
+	err := tds.store.Delete(owner, id, true, 0)
+	if err == nil {
+		err = tds.store.Purge(owner, id)
+	}
+	if err != nil {
+		tds.Log.Error("failed to take back an occurrence", slog.Int("id", id), slog.String("reason", err.Error()))
+	}
+}
+
+// normalize keeps dates in UTC at a second precision, the same in every backend,
+// tag names and recurrence rules in their canonical form and checklist entries free of surrounding spaces.
+func normalize(item *known.TodoItem) error {
+	tags, err := tagNames(item.Tags)
+	if err != nil {
+		return err
+	}
+	item.Tags = tags
+
+	rule, err := recurrenceRule(item.Recurrence)
+	if err != nil {
+		return err
+	}
+	item.Recurrence = rule
+
+	if len(item.Checklist) > maxChecklistEntries {
+		return errChecklist
+	}
+	var checklist []known.ChecklistEntry
+	for _, entry := range item.Checklist {
+		entry.Text = strings.TrimSpace(entry.Text)
+		if entry.Text == "" || utf8.RuneCountInString(entry.Text) > maxChecklistText {
+			return errChecklist
+		}
+		checklist = append(checklist, entry)
+	}
+	item.Checklist = checklist
+	item.Progress = nil
+
+	for _, date := range []**time.Time{&item.Start, &item.Due} {
+		if *date != nil {
+			normalized := (*date).UTC().Truncate(time.Second)
+			*date = &normalized
+		}
+	}
+
+	if item.Start != nil && item.Due != nil && item.Start.After(*item.Due) {
+		return errStartAfterDue
+	}
+
+	return nil
+}
+
+func (tds *TodoService) Move(owner, id, anchor int, before bool) error {
+	if id == anchor {
+		return errMoveToItself
+	}
+
+	return tds.store.Move(owner, id, anchor, before)
+}
+
+func (tds *TodoService) SetParent(owner, id, parent int) error {
+	if id == parent {
+		return errNestInItself
+	}
+
+	return tds.store.SetParent(owner, id, parent)
+}
+
+func (tds *TodoService) Delete(owner, id int, opts DeleteOptions) error {
+	return tds.store.Delete(owner, id, opts.Cascade, opts.Version)
 }
 
-func (tds *TodoService) Create(title, description string, done bool) error {
-	item := known.TodoItem{
-		Title:       title,
-		Description: description,
-		Done:        done,
+func (tds *TodoService) GetTrash(ctx context.Context, owner int, query known.TodoQuery) (*known.TodoPage, error) {
+	query.Trashed = true
+	if query.SortBy == "" {
+		query.SortBy = known.SortByDeleted
+		query.Desc = true
 	}
-	return tds.store.Create(&item)
+
+	return tds.GetAll(ctx, owner, query)
 }
 
-func (tds *TodoService) Update(id int, title, description string, done bool) error {
-	patch := known.TodoItem{
-		ID:          id,
-		Title:       title,
-		Description: description,
-		Done:        done,
+func (tds *TodoService) GetArchive(ctx context.Context, owner int, query known.TodoQuery) (*known.TodoPage, error) {
+	query.Archived = true
+	if query.SortBy == "" {
+		query.SortBy = known.SortByCompleted
+		query.Desc = true
 	}
-	return tds.store.Update(&patch)
+
+	return tds.GetAll(ctx, owner, query)
 }
 
-func (tds *TodoService) Delete(id int) error {
-	return tds.store.Delete(id)
+func (tds *TodoService) Unarchive(owner, id int) error {
+	return tds.store.Unarchive(owner, id)
 }
 
-func (tds *TodoService) Get(id int) (*known.TodoItem, error) {
-	return tds.store.GetOne(id)
+func (tds *TodoService) History(owner, id int) ([]*known.Revision, error) {
+	return tds.store.GetRevisions(owner, id)
 }
 
-func (tds *TodoService) GetAll(ctx context.Context) ([]*known.TodoItem, error) {
-	return tds.store.GetAll(ctx)
+// Revert undoes the revisions made after the chosen one on a copy of the item and saves it
+// as an update, past undone blockers as the item was in that state before.
+func (tds *TodoService) Revert(owner, id, revision int) error {
+	revisions, err := tds.store.GetRevisions(owner, id)
+	if err != nil {
+		return err
+	}
+	item, err := tds.store.GetOne(owner, id)
+	if err != nil {
+		return err
+	}
+
+	for i, candidate := range revisions {
+		if candidate.ID == revision {
+			if err := known.Rewind(item, revisions[i+1:]); err != nil {
+				return err
+			}
+			_, err := tds.Update(owner, id, *item, UpdateOptions{Force: true})
+			return err
+		}
+	}
+
+	return errNoRevision
+}
+
+func (tds *TodoService) Restore(owner, id int) error {
+	return tds.store.Restore(owner, id)
+}
+
+func (tds *TodoService) Purge(owner, id int) error {
+	return tds.store.Purge(owner, id)
+}
+
+func (tds *TodoService) Get(owner, id int) (*known.TodoItem, error) {
+	return tds.store.GetOne(owner, id)
+}
+
+// GetAll returns one page of the owner's items; the storage is asked for one item
+// more than the page holds to learn whether there is a next page.
+func (tds *TodoService) GetAll(ctx context.Context, owner int, query known.TodoQuery) (*known.TodoPage, error) {
+	switch query.SortBy {
+	case "", known.SortByPosition, known.SortByPriority, known.SortByID, known.SortByTitle, known.SortByCreated, known.SortByDue, known.SortByCompleted:
+	case known.SortByDeleted:
+		if !query.Trashed {
+			return nil, errUnknownSort
+		}
+	default:
+		return nil, errUnknownSort
+	}
+
+	if query.Limit <= 0 {
+		query.Limit = DefaultPageSize
+	}
+	if query.Limit > MaxPageSize {
+		query.Limit = MaxPageSize
+	}
+	if query.SortBy == "" {
+		query.SortBy = known.SortByPosition
+	}
+	if query.After != nil && (query.After.SortBy != query.SortBy || query.After.Desc != query.Desc) {
+		return nil, errCursorOrder
+	}
+	tags, err := tagNames(query.Tags)
+	if err != nil {
+		return nil, err
+	}
+	query.Tags = tags
+	// an unknown list or parent is missing rather than empty
+	if query.ListID != 0 {
+		if _, err := tds.store.GetList(owner, query.ListID); err != nil {
+			return nil, err
+		}
+	}
+	if query.ParentID != 0 && !query.Trashed {
+		if _, err := tds.store.GetOne(owner, query.ParentID); err != nil {
+			return nil, err
+		}
+	}
+
+	probe := query
+	probe.Limit++
+	items, err := tds.store.GetAll(ctx, owner, probe)
+	if err != nil {
+		return nil, err
+	}
+
+	page := known.TodoPage{Items: items, Limit: query.Limit}
+	if len(items) > query.Limit {
+		page.Items = items[:query.Limit]
+		page.Next = known.NewPageCursor(page.Items[query.Limit-1], query.SortBy, query.Desc)
+	}
+
+	return &page, nil
+}
+
+// GetDue narrows query down to a due view, sorting by the due date unless asked otherwise.
+func (tds *TodoService) GetDue(ctx context.Context, owner int, view string, loc *time.Location, query known.TodoQuery) (*known.TodoPage, error) {
+	now := tds.Now().In(loc)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+
+	var from, before time.Time
+	switch view {
+	case ViewOverdue:
+		done := false
+		query.Done = &done
+		before = now
+	case ViewToday:
+		from, before = today, today.AddDate(0, 0, 1)
+	case ViewWeek:
+		// weeks start on Monday
+		from = today.AddDate(0, 0, -(int(today.Weekday())+6)%7)
+		before = from.AddDate(0, 0, 7)
+	default:
+		return nil, errUnknownView
+	}
+
+	if !from.IsZero() {
+		query.DueFrom = &from
+	}
+	query.DueBefore = &before
+	if query.SortBy == "" {
+		query.SortBy = known.SortByDue
+	}
+
+	return tds.GetAll(ctx, owner, query)
 }
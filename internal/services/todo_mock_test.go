@@ -5,13 +5,16 @@
 //
 //	mockgen -destination=todo_mock_test.go -source=todo.go -package=services_test
 //
+
 // Package services_test is a generated GoMock package.
 package services_test
 
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
+	services "github.com/scriptdealer/to-do-go/internal/services"
 	known "github.com/scriptdealer/to-do-go/known"
 	gomock "go.uber.org/mock/gomock"
 )
@@ -39,74 +42,380 @@ func (m *MockTodoLogic) EXPECT() *MockTodoLogicMockRecorder {
 	return m.recorder
 }
 
-// Create mocks base method.
-func (m *MockTodoLogic) Create(title, description string) error {
+// AddBlocker mocks base method.
+func (m *MockTodoLogic) AddBlocker(owner, id, blocker int) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Create", title, description)
+	ret := m.ctrl.Call(m, "AddBlocker", owner, id, blocker)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
+// AddBlocker indicates an expected call of AddBlocker.
+func (mr *MockTodoLogicMockRecorder) AddBlocker(owner, id, blocker any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddBlocker", reflect.TypeOf((*MockTodoLogic)(nil).AddBlocker), owner, id, blocker)
+}
+
+// Create mocks base method.
+func (m *MockTodoLogic) Create(owner int, item known.TodoItem) (*known.TodoItem, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Create", owner, item)
+	ret0, _ := ret[0].(*known.TodoItem)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
 // Create indicates an expected call of Create.
-func (mr *MockTodoLogicMockRecorder) Create(title, description any) *gomock.Call {
+func (mr *MockTodoLogicMockRecorder) Create(owner, item any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockTodoLogic)(nil).Create), title, description)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockTodoLogic)(nil).Create), owner, item)
+}
+
+// CreateList mocks base method.
+func (m *MockTodoLogic) CreateList(owner int, name string) (*known.List, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateList", owner, name)
+	ret0, _ := ret[0].(*known.List)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateList indicates an expected call of CreateList.
+func (mr *MockTodoLogicMockRecorder) CreateList(owner, name any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateList", reflect.TypeOf((*MockTodoLogic)(nil).CreateList), owner, name)
 }
 
 // Delete mocks base method.
-func (m *MockTodoLogic) Delete(id int) error {
+func (m *MockTodoLogic) Delete(owner, id int, opts services.DeleteOptions) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Delete", id)
+	ret := m.ctrl.Call(m, "Delete", owner, id, opts)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Delete indicates an expected call of Delete.
-func (mr *MockTodoLogicMockRecorder) Delete(id any) *gomock.Call {
+func (mr *MockTodoLogicMockRecorder) Delete(owner, id, opts any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockTodoLogic)(nil).Delete), id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockTodoLogic)(nil).Delete), owner, id, opts)
+}
+
+// DeleteList mocks base method.
+func (m *MockTodoLogic) DeleteList(owner, id int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteList", owner, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteList indicates an expected call of DeleteList.
+func (mr *MockTodoLogicMockRecorder) DeleteList(owner, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteList", reflect.TypeOf((*MockTodoLogic)(nil).DeleteList), owner, id)
 }
 
 // Get mocks base method.
-func (m *MockTodoLogic) Get(id int) (*known.TodoItem, error) {
+func (m *MockTodoLogic) Get(owner, id int) (*known.TodoItem, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Get", id)
+	ret := m.ctrl.Call(m, "Get", owner, id)
 	ret0, _ := ret[0].(*known.TodoItem)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Get indicates an expected call of Get.
-func (mr *MockTodoLogicMockRecorder) Get(id any) *gomock.Call {
+func (mr *MockTodoLogicMockRecorder) Get(owner, id any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockTodoLogic)(nil).Get), id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockTodoLogic)(nil).Get), owner, id)
 }
 
 // GetAll mocks base method.
-func (m *MockTodoLogic) GetAll(ctx context.Context) ([]*known.TodoItem, error) {
+func (m *MockTodoLogic) GetAll(ctx context.Context, owner int, query known.TodoQuery) (*known.TodoPage, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetAll", ctx)
-	ret0, _ := ret[0].([]*known.TodoItem)
+	ret := m.ctrl.Call(m, "GetAll", ctx, owner, query)
+	ret0, _ := ret[0].(*known.TodoPage)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetAll indicates an expected call of GetAll.
-func (mr *MockTodoLogicMockRecorder) GetAll(ctx any) *gomock.Call {
+func (mr *MockTodoLogicMockRecorder) GetAll(ctx, owner, query any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAll", reflect.TypeOf((*MockTodoLogic)(nil).GetAll), ctx)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAll", reflect.TypeOf((*MockTodoLogic)(nil).GetAll), ctx, owner, query)
 }
 
-// Update mocks base method.
-func (m *MockTodoLogic) Update(id int, title, description string, done bool) error {
+// GetArchive mocks base method.
+func (m *MockTodoLogic) GetArchive(ctx context.Context, owner int, query known.TodoQuery) (*known.TodoPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetArchive", ctx, owner, query)
+	ret0, _ := ret[0].(*known.TodoPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetArchive indicates an expected call of GetArchive.
+func (mr *MockTodoLogicMockRecorder) GetArchive(ctx, owner, query any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetArchive", reflect.TypeOf((*MockTodoLogic)(nil).GetArchive), ctx, owner, query)
+}
+
+// GetBlockers mocks base method.
+func (m *MockTodoLogic) GetBlockers(owner, id int) ([]*known.TodoItem, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBlockers", owner, id)
+	ret0, _ := ret[0].([]*known.TodoItem)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetBlockers indicates an expected call of GetBlockers.
+func (mr *MockTodoLogicMockRecorder) GetBlockers(owner, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBlockers", reflect.TypeOf((*MockTodoLogic)(nil).GetBlockers), owner, id)
+}
+
+// GetDue mocks base method.
+func (m *MockTodoLogic) GetDue(ctx context.Context, owner int, view string, loc *time.Location, query known.TodoQuery) (*known.TodoPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDue", ctx, owner, view, loc, query)
+	ret0, _ := ret[0].(*known.TodoPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDue indicates an expected call of GetDue.
+func (mr *MockTodoLogicMockRecorder) GetDue(ctx, owner, view, loc, query any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDue", reflect.TypeOf((*MockTodoLogic)(nil).GetDue), ctx, owner, view, loc, query)
+}
+
+// GetList mocks base method.
+func (m *MockTodoLogic) GetList(owner, id int) (*known.List, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetList", owner, id)
+	ret0, _ := ret[0].(*known.List)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetList indicates an expected call of GetList.
+func (mr *MockTodoLogicMockRecorder) GetList(owner, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetList", reflect.TypeOf((*MockTodoLogic)(nil).GetList), owner, id)
+}
+
+// GetLists mocks base method.
+func (m *MockTodoLogic) GetLists(owner int, archived bool) ([]*known.List, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLists", owner, archived)
+	ret0, _ := ret[0].([]*known.List)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLists indicates an expected call of GetLists.
+func (mr *MockTodoLogicMockRecorder) GetLists(owner, archived any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLists", reflect.TypeOf((*MockTodoLogic)(nil).GetLists), owner, archived)
+}
+
+// GetTrash mocks base method.
+func (m *MockTodoLogic) GetTrash(ctx context.Context, owner int, query known.TodoQuery) (*known.TodoPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTrash", ctx, owner, query)
+	ret0, _ := ret[0].(*known.TodoPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTrash indicates an expected call of GetTrash.
+func (mr *MockTodoLogicMockRecorder) GetTrash(ctx, owner, query any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTrash", reflect.TypeOf((*MockTodoLogic)(nil).GetTrash), ctx, owner, query)
+}
+
+// History mocks base method.
+func (m *MockTodoLogic) History(owner, id int) ([]*known.Revision, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "History", owner, id)
+	ret0, _ := ret[0].([]*known.Revision)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// History indicates an expected call of History.
+func (mr *MockTodoLogicMockRecorder) History(owner, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "History", reflect.TypeOf((*MockTodoLogic)(nil).History), owner, id)
+}
+
+// Move mocks base method.
+func (m *MockTodoLogic) Move(owner, id, anchor int, before bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Move", owner, id, anchor, before)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Move indicates an expected call of Move.
+func (mr *MockTodoLogicMockRecorder) Move(owner, id, anchor, before any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Move", reflect.TypeOf((*MockTodoLogic)(nil).Move), owner, id, anchor, before)
+}
+
+// MoveToList mocks base method.
+func (m *MockTodoLogic) MoveToList(owner, id, list int) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Update", id, title, description, done)
+	ret := m.ctrl.Call(m, "MoveToList", owner, id, list)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
+// MoveToList indicates an expected call of MoveToList.
+func (mr *MockTodoLogicMockRecorder) MoveToList(owner, id, list any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MoveToList", reflect.TypeOf((*MockTodoLogic)(nil).MoveToList), owner, id, list)
+}
+
+// Next mocks base method.
+func (m *MockTodoLogic) Next(ctx context.Context, owner int) ([]*known.TodoItem, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Next", ctx, owner)
+	ret0, _ := ret[0].([]*known.TodoItem)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Next indicates an expected call of Next.
+func (mr *MockTodoLogicMockRecorder) Next(ctx, owner any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Next", reflect.TypeOf((*MockTodoLogic)(nil).Next), ctx, owner)
+}
+
+// Occurrences mocks base method.
+func (m *MockTodoLogic) Occurrences(ctx context.Context, owner, id int, query known.TodoQuery) (*known.TodoPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Occurrences", ctx, owner, id, query)
+	ret0, _ := ret[0].(*known.TodoPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Occurrences indicates an expected call of Occurrences.
+func (mr *MockTodoLogicMockRecorder) Occurrences(ctx, owner, id, query any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Occurrences", reflect.TypeOf((*MockTodoLogic)(nil).Occurrences), ctx, owner, id, query)
+}
+
+// Purge mocks base method.
+func (m *MockTodoLogic) Purge(owner, id int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Purge", owner, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Purge indicates an expected call of Purge.
+func (mr *MockTodoLogicMockRecorder) Purge(owner, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Purge", reflect.TypeOf((*MockTodoLogic)(nil).Purge), owner, id)
+}
+
+// RemoveBlocker mocks base method.
+func (m *MockTodoLogic) RemoveBlocker(owner, id, blocker int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveBlocker", owner, id, blocker)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RemoveBlocker indicates an expected call of RemoveBlocker.
+func (mr *MockTodoLogicMockRecorder) RemoveBlocker(owner, id, blocker any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveBlocker", reflect.TypeOf((*MockTodoLogic)(nil).RemoveBlocker), owner, id, blocker)
+}
+
+// Restore mocks base method.
+func (m *MockTodoLogic) Restore(owner, id int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Restore", owner, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Restore indicates an expected call of Restore.
+func (mr *MockTodoLogicMockRecorder) Restore(owner, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Restore", reflect.TypeOf((*MockTodoLogic)(nil).Restore), owner, id)
+}
+
+// Revert mocks base method.
+func (m *MockTodoLogic) Revert(owner, id, revision int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Revert", owner, id, revision)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Revert indicates an expected call of Revert.
+func (mr *MockTodoLogicMockRecorder) Revert(owner, id, revision any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Revert", reflect.TypeOf((*MockTodoLogic)(nil).Revert), owner, id, revision)
+}
+
+// SetParent mocks base method.
+func (m *MockTodoLogic) SetParent(owner, id, parent int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetParent", owner, id, parent)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetParent indicates an expected call of SetParent.
+func (mr *MockTodoLogicMockRecorder) SetParent(owner, id, parent any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetParent", reflect.TypeOf((*MockTodoLogic)(nil).SetParent), owner, id, parent)
+}
+
+// Unarchive mocks base method.
+func (m *MockTodoLogic) Unarchive(owner, id int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Unarchive", owner, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Unarchive indicates an expected call of Unarchive.
+func (mr *MockTodoLogicMockRecorder) Unarchive(owner, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Unarchive", reflect.TypeOf((*MockTodoLogic)(nil).Unarchive), owner, id)
+}
+
+// Update mocks base method.
+func (m *MockTodoLogic) Update(owner, id int, item known.TodoItem, opts services.UpdateOptions) (*known.TodoItem, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Update", owner, id, item, opts)
+	ret0, _ := ret[0].(*known.TodoItem)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
 // Update indicates an expected call of Update.
-func (mr *MockTodoLogicMockRecorder) Update(id, title, description, done any) *gomock.Call {
+func (mr *MockTodoLogicMockRecorder) Update(owner, id, item, opts any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockTodoLogic)(nil).Update), owner, id, item, opts)
+}
+
+// UpdateList mocks base method.
+func (m *MockTodoLogic) UpdateList(owner, id int, name string, archived *bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateList", owner, id, name, archived)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateList indicates an expected call of UpdateList.
+func (mr *MockTodoLogicMockRecorder) UpdateList(owner, id, name, archived any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockTodoLogic)(nil).Update), id, title, description, done)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateList", reflect.TypeOf((*MockTodoLogic)(nil).UpdateList), owner, id, name, archived)
 }
@@ -3,18 +3,25 @@ package services_test
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
 	"log/slog"
 	"os"
 	"regexp"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/scriptdealer/to-do-go/internal/services"
 	"github.com/scriptdealer/to-do-go/internal/storage"
 	"github.com/scriptdealer/to-do-go/known"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 )
 
+var created = time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+
 type TodoServiceSuite struct {
 	suite.Suite
 
@@ -40,109 +47,513 @@ func (s *TodoServiceSuite) SetupTest() {
 		&storage.PostgresStorage{Log: s.logger, DB: s.db},
 		s.logger,
 	)
+	s.todo.Now = func() time.Time { return created }
+}
+
+// expectReadBack expects an item of owner 5 without tags or subtasks to be read, its ID first of values.
+func (s *TodoServiceSuite) expectReadBack(values ...driver.Value) {
+	s.mockedDB.ExpectQuery(regexp.QuoteMeta(
+		`select id, title, description, done, owner_id, created_at, start_at, due_at, priority, position, list_id, parent_id, checklist, recurrence, series_id, completed_at, archived_at, deleted_at, version from todos where id = $1 and owner_id = $2 and deleted_at is null`,
+	)).WithArgs(values[0], 5).WillReturnRows(
+		sqlmock.NewRows([]string{"id", "title", "description", "done", "owner_id", "created_at", "start_at", "due_at", "priority", "position", "list_id", "parent_id", "checklist", "recurrence", "series_id", "completed_at", "archived_at", "deleted_at", "version"}).
+			AddRow(values...),
+	)
+	s.mockedDB.ExpectQuery(regexp.QuoteMeta(
+		`select tt.todo_id, t.name from todo_tags tt join tags t on t.id = tt.tag_id where tt.todo_id in ($1) order by t.name`,
+	)).WithArgs(values[0]).WillReturnRows(sqlmock.NewRows([]string{"todo_id", "name"}))
+	s.mockedDB.ExpectQuery(regexp.QuoteMeta(
+		`select parent_id, count(*), count(case when done then 1 end) from todos where deleted_at is null and parent_id in ($1) group by parent_id`,
+	)).WithArgs(values[0]).WillReturnRows(sqlmock.NewRows([]string{"parent_id", "count", "count"}))
 }
 
 func (s *TodoServiceSuite) TestCreate_Ok() {
-	s.mockedDB.ExpectExec(regexp.QuoteMeta(`insert into todos (title, description, done) values ($1, $2, $3)`)).
-		WithArgs("1st", "My first", true).WillReturnResult(sqlmock.NewResult(1, 1))
-	err := s.todo.Create("1st", "My first", true)
+	s.mockedDB.ExpectBegin()
+	s.mockedDB.ExpectQuery(regexp.QuoteMeta(`insert into todos (title, description, done, owner_id, created_at, start_at, due_at, priority, position, list_id, parent_id, checklist, recurrence, series_id, completed_at) `+
+		`values ($1, $2, $3, $4, $5, $6, $7, $8, (select coalesce(max(position), 0) + 1 from todos where owner_id = $4), `+
+		`(select id from lists where id = $9 and owner_id = $4), $10, $11, $12, $13, $14) returning id, position`)).
+		WithArgs("1st", "My first", true, 5, created, nil, nil, known.PriorityHigh, 0, nil, nil, nil, nil, created).WillReturnRows(sqlmock.NewRows([]string{"id", "position"}).AddRow(1, 1))
+	s.mockedDB.ExpectExec(regexp.QuoteMeta(`insert into tags (owner_id, name) values ($1, $2) on conflict (owner_id, name) do nothing`)).
+		WithArgs(5, "work").WillReturnResult(sqlmock.NewResult(1, 1))
+	s.mockedDB.ExpectExec(regexp.QuoteMeta(`insert into todo_tags (todo_id, tag_id) select $1, id from tags where owner_id = $2 and name = $3`)).
+		WithArgs(1, 5, "work").WillReturnResult(sqlmock.NewResult(1, 1))
+	s.mockedDB.ExpectExec(regexp.QuoteMeta(`insert into item_revisions (todo_id, actor_id, action, created_at, changes) values ($1, $2, $3, $4, $5)`)).
+		WithArgs(1, 5, known.RevisionCreate, sqlmock.AnyArg(), `[{"field":"title","to":"1st"},{"field":"description","to":"My first"},{"field":"done","to":true},{"field":"priority","to":"high"},`+
+			`{"field":"start_at","to":null},{"field":"due_at","to":null},{"field":"recurrence","to":""},{"field":"tags","to":["work"]},{"field":"checklist","to":null}]`).WillReturnResult(sqlmock.NewResult(1, 1))
+	s.mockedDB.ExpectCommit()
+	s.expectReadBack(1, "1st", "My first", true, 5, created, nil, nil, 3, 1, nil, nil, nil, nil, nil, created, nil, nil, 1)
+	item, err := s.todo.Create(5, known.TodoItem{Title: "1st", Description: "My first", Done: true, Priority: known.PriorityHigh, Tags: []string{" Work", "work"}})
 	s.NoError(err)
+	s.Equal(1, item.ID)
+	s.Equal(1, item.Version)
+	s.Equal(&created, item.Completed)
+	s.NoError(s.mockedDB.ExpectationsWereMet())
 }
 
 func (s *TodoServiceSuite) TestCreate_DbFailure() {
-	s.mockedDB.ExpectExec(regexp.QuoteMeta(`insert into todos (title, description, done) values ($1, $2, $3)`)).
-		WithArgs("1st", "My first", true).WillReturnError(sql.ErrConnDone)
+	s.mockedDB.ExpectBegin()
+	s.mockedDB.ExpectQuery(regexp.QuoteMeta(`insert into todos (title, description, done, owner_id, created_at, start_at, due_at, priority, position, list_id, parent_id, checklist, recurrence, series_id, completed_at) `+
+		`values ($1, $2, $3, $4, $5, $6, $7, $8, (select coalesce(max(position), 0) + 1 from todos where owner_id = $4), `+
+		`(select id from lists where id = $9 and owner_id = $4), $10, $11, $12, $13, $14) returning id, position`)).
+		WithArgs("1st", "My first", true, 5, created, nil, nil, known.PriorityHigh, 0, nil, nil, nil, nil, created).WillReturnError(sql.ErrConnDone)
+	s.mockedDB.ExpectRollback()
 
-	err := s.todo.Create("1st", "My first", true)
+	_, err := s.todo.Create(5, known.TodoItem{Title: "1st", Description: "My first", Done: true, Priority: known.PriorityHigh})
 	s.EqualError(err, "sql: connection is already closed")
 }
 
+func (s *TodoServiceSuite) TestCreate_ArchivedList() {
+	s.mockedDB.ExpectQuery(regexp.QuoteMeta(
+		`select l.id, l.owner_id, l.name, l.archived, count(case when not t.done then 1 end), count(case when t.done then 1 end) `+
+			`from lists l left join todos t on t.list_id = l.id and t.deleted_at is null where l.owner_id = $1 and l.id = $2 group by l.id, l.owner_id, l.name, l.archived order by l.id`,
+	)).WithArgs(5, 2).WillReturnRows(sqlmock.NewRows([]string{"id", "owner_id", "name", "archived", "open", "done"}).AddRow(2, 5, "Garden", true, 1, 3))
+
+	_, err := s.todo.Create(5, known.TodoItem{Title: "1st", Description: "My first", ListID: 2})
+	s.ErrorIs(err, known.ErrConflict)
+}
+
 func (s *TodoServiceSuite) TestGetOne_Ok() {
-	mockedRows := sqlmock.NewRows([]string{"id", "title", "description", "done"}).AddRow(1, "1st", "My first", false)
+	mockedRows := sqlmock.NewRows([]string{"id", "title", "description", "done", "owner_id", "created_at", "start_at", "due_at", "priority", "position", "list_id", "parent_id", "checklist", "recurrence", "series_id", "completed_at", "archived_at", "deleted_at", "version"}).AddRow(1, "1st", "My first", false, 5, created, nil, nil, 3, 1, 2, nil, `[{"text":"gloves","done":true}]`, nil, nil, nil, nil, nil, 1)
 	s.mockedDB.ExpectQuery(regexp.QuoteMeta(
-		`select * from todos where id = $1`,
-	)).WithArgs(1).WillReturnRows(mockedRows)
+		`select id, title, description, done, owner_id, created_at, start_at, due_at, priority, position, list_id, parent_id, checklist, recurrence, series_id, completed_at, archived_at, deleted_at, version from todos where id = $1 and owner_id = $2 and deleted_at is null`,
+	)).WithArgs(1, 5).WillReturnRows(mockedRows)
+	s.mockedDB.ExpectQuery(regexp.QuoteMeta(
+		`select tt.todo_id, t.name from todo_tags tt join tags t on t.id = tt.tag_id where tt.todo_id in ($1) order by t.name`,
+	)).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"todo_id", "name"}).AddRow(1, "home").AddRow(1, "work"))
+	s.mockedDB.ExpectQuery(regexp.QuoteMeta(
+		`select parent_id, count(*), count(case when done then 1 end) from todos where deleted_at is null and parent_id in ($1) group by parent_id`,
+	)).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"parent_id", "count", "count"}).AddRow(1, 3, 1))
 
-	got, err := s.todo.Get(1)
+	got, err := s.todo.Get(5, 1)
 	s.NoError(err)
 	s.Equal(&known.TodoItem{
 		ID:          1,
+		OwnerID:     5,
 		Title:       "1st",
 		Description: "My first",
 		Done:        false,
+		Priority:    known.PriorityHigh,
+		ListID:      2,
+		Position:    1,
+		Created:     created,
+		Version:     1,
+		Tags:        []string{"home", "work"},
+		Checklist:   []known.ChecklistEntry{{Text: "gloves", Done: true}},
+		Progress:    &known.Progress{Done: 2, Total: 4},
 	}, got)
 }
 
 func (s *TodoServiceSuite) TestGetOne_NoRow() {
-	emptyRows := sqlmock.NewRows([]string{"id", "title", "description", "done"})
+	emptyRows := sqlmock.NewRows([]string{"id", "title", "description", "done", "owner_id", "created_at", "start_at", "due_at", "priority", "position", "list_id", "parent_id", "checklist", "recurrence", "series_id", "completed_at", "archived_at", "deleted_at", "version"})
 	s.mockedDB.ExpectQuery(regexp.QuoteMeta(
-		`select * from todos where id = $1`,
-	)).WithArgs(2).WillReturnRows(emptyRows)
+		`select id, title, description, done, owner_id, created_at, start_at, due_at, priority, position, list_id, parent_id, checklist, recurrence, series_id, completed_at, archived_at, deleted_at, version from todos where id = $1 and owner_id = $2 and deleted_at is null`,
+	)).WithArgs(2, 5).WillReturnRows(emptyRows)
 
-	got, err := s.todo.Get(2)
+	got, err := s.todo.Get(5, 2)
 	s.EqualError(err, "no such item in storage")
 	s.Nil(got)
 }
 
 func (s *TodoServiceSuite) TestGetOne_DbFailure() {
 	s.mockedDB.ExpectQuery(regexp.QuoteMeta(
-		`select * from todos where id = $1`,
-	)).WithArgs(2).WillReturnError(sql.ErrConnDone)
+		`select id, title, description, done, owner_id, created_at, start_at, due_at, priority, position, list_id, parent_id, checklist, recurrence, series_id, completed_at, archived_at, deleted_at, version from todos where id = $1 and owner_id = $2 and deleted_at is null`,
+	)).WithArgs(2, 5).WillReturnError(sql.ErrConnDone)
 
-	got, err := s.todo.Get(2)
+	got, err := s.todo.Get(5, 2)
 	s.EqualError(err, "sql: connection is already closed")
 	s.Nil(got)
 }
 
 func (s *TodoServiceSuite) TestGetAll_Ok() {
-	mockedRows := sqlmock.NewRows([]string{"id", "title", "description", "done"}).
-		AddRow(1, "1st", "My first", true).
-		AddRow(2, "2nd", "My second", false)
-	s.mockedDB.ExpectQuery(regexp.QuoteMeta(`select * from todos`)).WillReturnRows(mockedRows)
+	mockedRows := sqlmock.NewRows([]string{"id", "title", "description", "done", "owner_id", "created_at", "start_at", "due_at", "priority", "position", "list_id", "parent_id", "checklist", "recurrence", "series_id", "completed_at", "archived_at", "deleted_at", "version"}).
+		AddRow(1, "1st", "My first", true, 5, created, nil, nil, 0, 1, nil, nil, nil, nil, nil, nil, nil, nil, 1).
+		AddRow(2, "2nd", "My second", false, 5, created, nil, nil, 0, 2, nil, nil, nil, nil, nil, nil, nil, nil, 1)
+	s.mockedDB.ExpectQuery(regexp.QuoteMeta(`select id, title, description, done, owner_id, created_at, start_at, due_at, priority, position, list_id, parent_id, checklist, recurrence, series_id, completed_at, archived_at, deleted_at, version from todos where owner_id = $1 and deleted_at is null and archived_at is null and (list_id is null or list_id not in (select id from lists where owner_id = $2 and archived)) order by position asc, id asc limit $3`)).
+		WithArgs(5, 5, services.DefaultPageSize+1).WillReturnRows(mockedRows)
+	s.mockedDB.ExpectQuery(regexp.QuoteMeta(
+		`select tt.todo_id, t.name from todo_tags tt join tags t on t.id = tt.tag_id where tt.todo_id in ($1, $2) order by t.name`,
+	)).WithArgs(1, 2).WillReturnRows(sqlmock.NewRows([]string{"todo_id", "name"}))
+	s.mockedDB.ExpectQuery(regexp.QuoteMeta(
+		`select parent_id, count(*), count(case when done then 1 end) from todos where deleted_at is null and parent_id in ($1, $2) group by parent_id`,
+	)).WithArgs(1, 2).WillReturnRows(sqlmock.NewRows([]string{"parent_id", "count", "count"}))
 
-	got, err := s.todo.GetAll(context.Background())
+	got, err := s.todo.GetAll(context.Background(), 5, known.TodoQuery{})
 	s.NoError(err)
 	s.Equal(&known.TodoItem{
 		ID:          1,
+		OwnerID:     5,
 		Title:       "1st",
 		Description: "My first",
 		Done:        true,
-	}, got[0])
+		Position:    1,
+		Created:     created,
+		Version:     1,
+	}, got.Items[0])
 	s.Equal(&known.TodoItem{
 		ID:          2,
+		OwnerID:     5,
 		Title:       "2nd",
 		Description: "My second",
 		Done:        false,
-	}, got[1])
+		Position:    2,
+		Created:     created,
+		Version:     1,
+	}, got.Items[1])
 }
 
 func (s *TodoServiceSuite) TestGetAll_CtxErr() {
-	mockedRows := sqlmock.NewRows([]string{"id", "title", "description", "done"}).AddRow(1, "1st", "My first", true)
-	s.mockedDB.ExpectQuery(regexp.QuoteMeta(`select * from todos`)).WillReturnRows(mockedRows)
+	mockedRows := sqlmock.NewRows([]string{"id", "title", "description", "done", "owner_id", "created_at", "start_at", "due_at", "priority", "position", "list_id", "parent_id", "checklist", "recurrence", "series_id", "completed_at", "archived_at", "deleted_at", "version"}).AddRow(1, "1st", "My first", true, 5, created, nil, nil, 0, 1, nil, nil, nil, nil, nil, nil, nil, nil, 1)
+	s.mockedDB.ExpectQuery(regexp.QuoteMeta(`select id, title, description, done, owner_id, created_at, start_at, due_at, priority, position, list_id, parent_id, checklist, recurrence, series_id, completed_at, archived_at, deleted_at, version from todos where owner_id = $1 and deleted_at is null and archived_at is null and (list_id is null or list_id not in (select id from lists where owner_id = $2 and archived)) order by position asc, id asc limit $3`)).
+		WithArgs(5, 5, services.DefaultPageSize+1).WillReturnRows(mockedRows)
 	ctx, cancel := context.WithCancel(context.Background())
 	cancel()
-	_, err := s.todo.GetAll(ctx)
+	_, err := s.todo.GetAll(ctx, 5, known.TodoQuery{})
 	s.EqualError(err, context.Canceled.Error())
 
 }
 
 func (s *TodoServiceSuite) TestGetAll_ScanErr() {
-	mockedRows := sqlmock.NewRows([]string{"id", "title", "description", "done"}).AddRow(1, nil, nil, true)
-	s.mockedDB.ExpectQuery(regexp.QuoteMeta(`select * from todos`)).WillReturnRows(mockedRows)
-	_, err := s.todo.GetAll(context.Background())
+	mockedRows := sqlmock.NewRows([]string{"id", "title", "description", "done", "owner_id", "created_at", "start_at", "due_at", "priority", "position", "list_id", "parent_id", "checklist", "recurrence", "series_id", "completed_at", "archived_at", "deleted_at", "version"}).AddRow(1, nil, nil, true, 5, created, nil, nil, 0, 1, nil, nil, nil, nil, nil, nil, nil, nil, 1)
+	s.mockedDB.ExpectQuery(regexp.QuoteMeta(`select id, title, description, done, owner_id, created_at, start_at, due_at, priority, position, list_id, parent_id, checklist, recurrence, series_id, completed_at, archived_at, deleted_at, version from todos where owner_id = $1 and deleted_at is null and archived_at is null and (list_id is null or list_id not in (select id from lists where owner_id = $2 and archived)) order by position asc, id asc limit $3`)).
+		WithArgs(5, 5, services.DefaultPageSize+1).WillReturnRows(mockedRows)
+	_, err := s.todo.GetAll(context.Background(), 5, known.TodoQuery{})
 	s.EqualError(err, `sql: Scan error on column index 1, name "title": converting NULL to string is unsupported`)
 }
 
 func (s *TodoServiceSuite) TestUpdate_Ok() {
-	s.mockedDB.ExpectExec(regexp.QuoteMeta(`update todos set title = $1, description = $2, done = $3 where id = $4`)).
-		WithArgs("1st", "My first", true, 1).WillReturnResult(sqlmock.NewResult(1, 1))
-	err := s.todo.Update(1, "1st", "My first", true)
+	due := time.Date(2024, 5, 10, 18, 0, 0, 0, time.FixedZone("CEST", 2*60*60))
+	s.mockedDB.ExpectBegin()
+	s.mockedDB.ExpectQuery(regexp.QuoteMeta(
+		`select id, title, description, done, owner_id, created_at, start_at, due_at, priority, position, list_id, parent_id, checklist, recurrence, series_id, completed_at, archived_at, deleted_at, version from todos where id = $1 and owner_id = $2 and deleted_at is null`,
+	)).WithArgs(1, 5).WillReturnRows(
+		sqlmock.NewRows([]string{"id", "title", "description", "done", "owner_id", "created_at", "start_at", "due_at", "priority", "position", "list_id", "parent_id", "checklist", "recurrence", "series_id", "completed_at", "archived_at", "deleted_at", "version"}).
+			AddRow(1, "1st", "My first", false, 5, created, nil, nil, 0, 1, nil, nil, `[{"text":"seeds","done":false}]`, nil, nil, nil, nil, nil, 1),
+	)
+	s.mockedDB.ExpectQuery(regexp.QuoteMeta(`select t.name from todo_tags tt join tags t on t.id = tt.tag_id where tt.todo_id = $1 order by t.name`)).
+		WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("work"))
+	s.mockedDB.ExpectQuery(regexp.QuoteMeta(`update todos set title = $1, description = $2, done = $3, start_at = $4, due_at = $5, priority = $6, checklist = $7, recurrence = $8, `+
+		`completed_at = case when $3 then coalesce(completed_at, $11) end, archived_at = case when $3 then archived_at end, version = version + 1 `+
+		`where id = $9 and owner_id = $10 and deleted_at is null and ($12 = 0 or version = $12) returning version`)).
+		WithArgs("1st", "My first", true, nil, due.UTC(), known.PriorityNone, `[{"text":"seeds","done":true}]`, nil, 1, 5, created, 0).WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(2))
+	s.mockedDB.ExpectQuery(regexp.QuoteMeta(`select id, minutes_before from reminders where todo_id = $1 and minutes_before is not null and sent_at is null`)).
+		WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id", "minutes_before"}))
+	s.mockedDB.ExpectExec(regexp.QuoteMeta(`delete from todo_tags where todo_id = $1`)).
+		WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 2))
+	s.mockedDB.ExpectExec(regexp.QuoteMeta(`insert into item_revisions (todo_id, actor_id, action, created_at, changes) values ($1, $2, $3, $4, $5)`)).
+		WithArgs(1, 5, known.RevisionUpdate, sqlmock.AnyArg(), `[{"field":"done","from":false,"to":true},{"field":"due_at","from":null,"to":"2024-05-10T16:00:00Z"},`+
+			`{"field":"tags","from":["work"],"to":null},{"field":"checklist","from":[{"text":"seeds","done":false}],"to":[{"text":"seeds","done":true}]}]`).WillReturnResult(sqlmock.NewResult(1, 1))
+	s.mockedDB.ExpectCommit()
+	s.mockedDB.ExpectBegin()
+	s.mockedDB.ExpectQuery(regexp.QuoteMeta(
+		`select id, title, description, done, owner_id, created_at, start_at, due_at, priority, position, list_id, parent_id, checklist, recurrence, series_id, completed_at, archived_at, deleted_at, version from todos where not done and id <> $1 and id in (with recursive subtree(id, depth) as (select id, 1 from todos where id = $1 and owner_id = $2 and deleted_at is null `+
+			`union all select t.id, s.depth + 1 from todos t join subtree s on t.parent_id = s.id where t.deleted_at is null) select id from subtree)`,
+	)).WithArgs(1, 5).WillReturnRows(
+		sqlmock.NewRows([]string{"id", "title", "description", "done", "owner_id", "created_at", "start_at", "due_at", "priority", "position", "list_id", "parent_id", "checklist", "recurrence", "series_id", "completed_at", "archived_at", "deleted_at", "version"}).
+			AddRow(2, "dig", "", false, 5, created, nil, nil, 0, 2, nil, 1, nil, nil, nil, nil, nil, nil, 1),
+	)
+	s.mockedDB.ExpectExec(regexp.QuoteMeta(
+		`update todos set done = $3, completed_at = coalesce(completed_at, (select completed_at from todos where id = $1)), version = case when done then version else version + 1 end where id <> $1 and id in (with recursive subtree(id, depth) as (select id, 1 from todos where id = $1 and owner_id = $2 and deleted_at is null `+
+			`union all select t.id, s.depth + 1 from todos t join subtree s on t.parent_id = s.id where t.deleted_at is null) select id from subtree)`,
+	)).WithArgs(1, 5, true).WillReturnResult(sqlmock.NewResult(0, 3))
+	s.mockedDB.ExpectExec(regexp.QuoteMeta(`insert into item_revisions (todo_id, actor_id, action, created_at, changes) values ($1, $2, $3, $4, $5)`)).
+		WithArgs(2, 5, known.RevisionUpdate, sqlmock.AnyArg(), `[{"field":"done","from":false,"to":true}]`).WillReturnResult(sqlmock.NewResult(1, 1))
+	s.mockedDB.ExpectCommit()
+	s.expectReadBack(1, "1st", "My first", true, 5, created, nil, due, 0, 1, nil, nil, `[{"text":"seeds","done":true}]`, nil, nil, created, nil, nil, 2)
+	checklist := []known.ChecklistEntry{{Text: " seeds "}}
+	item, err := s.todo.Update(5, 1, known.TodoItem{Title: "1st", Description: "My first", Done: true, Due: &due, Checklist: checklist}, services.UpdateOptions{Cascade: true, Force: true})
+	s.NoError(err)
+	s.Equal(2, item.Version, "the item is returned as updated")
+	s.Equal(" seeds ", checklist[0].Text, "the caller's checklist is left alone")
+	s.NoError(s.mockedDB.ExpectationsWereMet())
+}
+
+func (s *TodoServiceSuite) TestUpdate_StaleVersion() {
+	s.mockedDB.ExpectBegin()
+	s.mockedDB.ExpectQuery(regexp.QuoteMeta(
+		`select id, title, description, done, owner_id, created_at, start_at, due_at, priority, position, list_id, parent_id, checklist, recurrence, series_id, completed_at, archived_at, deleted_at, version from todos where id = $1 and owner_id = $2 and deleted_at is null`,
+	)).WithArgs(1, 5).WillReturnRows(
+		sqlmock.NewRows([]string{"id", "title", "description", "done", "owner_id", "created_at", "start_at", "due_at", "priority", "position", "list_id", "parent_id", "checklist", "recurrence", "series_id", "completed_at", "archived_at", "deleted_at", "version"}).
+			AddRow(1, "1st", "My first", false, 5, created, nil, nil, 0, 1, nil, nil, nil, nil, nil, nil, nil, nil, 3),
+	)
+	s.mockedDB.ExpectQuery(regexp.QuoteMeta(`select t.name from todo_tags tt join tags t on t.id = tt.tag_id where tt.todo_id = $1 order by t.name`)).
+		WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"name"}))
+	s.mockedDB.ExpectRollback()
+
+	_, err := s.todo.Update(5, 1, known.TodoItem{Title: "1st", Description: "My first", Version: 2}, services.UpdateOptions{Force: true})
+	s.ErrorIs(err, known.ErrPrecondition)
+	s.NoError(s.mockedDB.ExpectationsWereMet())
+}
+
+func (s *TodoServiceSuite) TestUpdate_StartAfterDue() {
+	due := created.Add(time.Hour)
+	start := due.Add(time.Minute)
+	_, err := s.todo.Update(5, 1, known.TodoItem{Title: "1st", Description: "My first", Start: &start, Due: &due}, services.UpdateOptions{})
+	s.ErrorIs(err, known.ErrValidation)
+}
+
+func (s *TodoServiceSuite) TestUpdate_Blocked() {
+	s.mockedDB.ExpectQuery(regexp.QuoteMeta(`select count(*) from todos where id = $1 and owner_id = $2 and deleted_at is null`)).
+		WithArgs(1, 5).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	s.mockedDB.ExpectQuery(regexp.QuoteMeta(
+		`select id, title, description, done, owner_id, created_at, start_at, due_at, priority, position, list_id, parent_id, checklist, recurrence, series_id, completed_at, archived_at, deleted_at, version from todos `+
+			`where owner_id = $1 and deleted_at is null and id in (select blocker_id from todo_blockers where todo_id = $2) order by position, id`,
+	)).WithArgs(5, 1).WillReturnRows(
+		sqlmock.NewRows([]string{"id", "title", "description", "done", "owner_id", "created_at", "start_at", "due_at", "priority", "position", "list_id", "parent_id", "checklist", "recurrence", "series_id", "completed_at", "archived_at", "deleted_at", "version"}).
+			AddRow(2, "2nd", "First things first", false, 5, created, nil, nil, 0, 2, nil, nil, nil, nil, nil, nil, nil, nil, 1),
+	)
+	s.mockedDB.ExpectQuery(regexp.QuoteMeta(
+		`select tt.todo_id, t.name from todo_tags tt join tags t on t.id = tt.tag_id where tt.todo_id in ($1) order by t.name`,
+	)).WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"todo_id", "name"}))
+	s.mockedDB.ExpectQuery(regexp.QuoteMeta(
+		`select parent_id, count(*), count(case when done then 1 end) from todos where deleted_at is null and parent_id in ($1) group by parent_id`,
+	)).WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"parent_id", "count", "count"}))
+
+	_, err := s.todo.Update(5, 1, known.TodoItem{Title: "1st", Description: "My first", Done: true}, services.UpdateOptions{})
+	s.ErrorIs(err, known.ErrConflict)
+}
+
+func (s *TodoServiceSuite) TestUpdate_Recurring() {
+	due := time.Date(2024, 5, 6, 18, 0, 0, 0, time.UTC)
+	start := due.Add(-time.Hour)
+	s.mockedDB.ExpectQuery(regexp.QuoteMeta(
+		`select id, title, description, done, owner_id, created_at, start_at, due_at, priority, position, list_id, parent_id, checklist, recurrence, series_id, completed_at, archived_at, deleted_at, version from todos where id = $1 and owner_id = $2 and deleted_at is null`,
+	)).WithArgs(1, 5).WillReturnRows(
+		sqlmock.NewRows([]string{"id", "title", "description", "done", "owner_id", "created_at", "start_at", "due_at", "priority", "position", "list_id", "parent_id", "checklist", "recurrence", "series_id", "completed_at", "archived_at", "deleted_at", "version"}).
+			AddRow(1, "water", "the plants", false, 5, created, start, due, 0, 1, nil, nil, nil, "FREQ=WEEKLY;BYDAY=MO,TH", nil, nil, nil, nil, 1),
+	)
+	s.mockedDB.ExpectQuery(regexp.QuoteMeta(
+		`select tt.todo_id, t.name from todo_tags tt join tags t on t.id = tt.tag_id where tt.todo_id in ($1) order by t.name`,
+	)).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"todo_id", "name"}))
+	s.mockedDB.ExpectQuery(regexp.QuoteMeta(
+		`select parent_id, count(*), count(case when done then 1 end) from todos where deleted_at is null and parent_id in ($1) group by parent_id`,
+	)).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"parent_id", "count", "count"}))
+	// Monday is done, Thursday is next
+	next := time.Date(2024, 5, 9, 18, 0, 0, 0, time.UTC)
+	s.mockedDB.ExpectBegin()
+	s.mockedDB.ExpectQuery(regexp.QuoteMeta(`insert into todos (title, description, done, owner_id, created_at, start_at, due_at, priority, position, list_id, parent_id, checklist, recurrence, series_id, completed_at) `+
+		`values ($1, $2, $3, $4, $5, $6, $7, $8, (select coalesce(max(position), 0) + 1 from todos where owner_id = $4), `+
+		`(select id from lists where id = $9 and owner_id = $4), $10, $11, $12, $13, $14) returning id, position`)).
+		WithArgs("water", "the plants", false, 5, created, next.Add(-time.Hour), next, known.PriorityNone, 0, nil, nil, "FREQ=WEEKLY;BYDAY=MO,TH", 1, nil).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "position"}).AddRow(2, 2))
+	s.mockedDB.ExpectExec(regexp.QuoteMeta(`insert into item_revisions (todo_id, actor_id, action, created_at, changes) values ($1, $2, $3, $4, $5)`)).
+		WithArgs(2, 5, known.RevisionCreate, sqlmock.AnyArg(), sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+	s.mockedDB.ExpectCommit()
+	s.mockedDB.ExpectBegin()
+	s.mockedDB.ExpectQuery(regexp.QuoteMeta(
+		`select id, title, description, done, owner_id, created_at, start_at, due_at, priority, position, list_id, parent_id, checklist, recurrence, series_id, completed_at, archived_at, deleted_at, version from todos where id = $1 and owner_id = $2 and deleted_at is null`,
+	)).WithArgs(1, 5).WillReturnRows(
+		sqlmock.NewRows([]string{"id", "title", "description", "done", "owner_id", "created_at", "start_at", "due_at", "priority", "position", "list_id", "parent_id", "checklist", "recurrence", "series_id", "completed_at", "archived_at", "deleted_at", "version"}).
+			AddRow(1, "water", "the plants", false, 5, created, start, due, 0, 1, nil, nil, nil, "FREQ=WEEKLY;BYDAY=MO,TH", nil, nil, nil, nil, 1),
+	)
+	s.mockedDB.ExpectQuery(regexp.QuoteMeta(`select t.name from todo_tags tt join tags t on t.id = tt.tag_id where tt.todo_id = $1 order by t.name`)).
+		WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"name"}))
+	s.mockedDB.ExpectQuery(regexp.QuoteMeta(`update todos set title = $1, description = $2, done = $3, start_at = $4, due_at = $5, priority = $6, checklist = $7, recurrence = $8, `+
+		`completed_at = case when $3 then coalesce(completed_at, $11) end, archived_at = case when $3 then archived_at end, version = version + 1 `+
+		`where id = $9 and owner_id = $10 and deleted_at is null and ($12 = 0 or version = $12) returning version`)).
+		WithArgs("water", "the plants", true, start, due, known.PriorityNone, nil, nil, 1, 5, created, 1).WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(2))
+	s.mockedDB.ExpectQuery(regexp.QuoteMeta(`select id, minutes_before from reminders where todo_id = $1 and minutes_before is not null and sent_at is null`)).
+		WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id", "minutes_before"}))
+	s.mockedDB.ExpectExec(regexp.QuoteMeta(`delete from todo_tags where todo_id = $1`)).
+		WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 0))
+	s.mockedDB.ExpectExec(regexp.QuoteMeta(`insert into item_revisions (todo_id, actor_id, action, created_at, changes) values ($1, $2, $3, $4, $5)`)).
+		WithArgs(1, 5, known.RevisionUpdate, sqlmock.AnyArg(), `[{"field":"done","from":false,"to":true},{"field":"recurrence","from":"FREQ=WEEKLY;BYDAY=MO,TH","to":""}]`).WillReturnResult(sqlmock.NewResult(1, 1))
+	s.mockedDB.ExpectCommit()
+	s.expectReadBack(1, "water", "the plants", true, 5, created, start, due, 0, 1, nil, nil, nil, nil, nil, created, nil, nil, 2)
+
+	item := known.TodoItem{Title: "water", Description: "the plants", Done: true, Start: &start, Due: &due, Recurrence: "rrule:freq=weekly;byday=th,mo"}
+	_, err := s.todo.Update(5, 1, item, services.UpdateOptions{Force: true})
 	s.NoError(err)
+	s.NoError(s.mockedDB.ExpectationsWereMet())
+}
+
+func (s *TodoServiceSuite) TestUpdate_RecurringKeepsRule() {
+	due := time.Date(2024, 5, 6, 18, 0, 0, 0, time.UTC)
+	start := due.Add(-time.Hour)
+	s.mockedDB.ExpectQuery(regexp.QuoteMeta(
+		`select id, title, description, done, owner_id, created_at, start_at, due_at, priority, position, list_id, parent_id, checklist, recurrence, series_id, completed_at, archived_at, deleted_at, version from todos where id = $1 and owner_id = $2 and deleted_at is null`,
+	)).WithArgs(1, 5).WillReturnRows(
+		sqlmock.NewRows([]string{"id", "title", "description", "done", "owner_id", "created_at", "start_at", "due_at", "priority", "position", "list_id", "parent_id", "checklist", "recurrence", "series_id", "completed_at", "archived_at", "deleted_at", "version"}).
+			AddRow(1, "water", "the plants", false, 5, created, start, due, 0, 1, nil, nil, nil, "FREQ=WEEKLY;BYDAY=MO,TH", nil, nil, nil, nil, 1),
+	)
+	s.mockedDB.ExpectQuery(regexp.QuoteMeta(
+		`select tt.todo_id, t.name from todo_tags tt join tags t on t.id = tt.tag_id where tt.todo_id in ($1) order by t.name`,
+	)).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"todo_id", "name"}))
+	s.mockedDB.ExpectQuery(regexp.QuoteMeta(
+		`select parent_id, count(*), count(case when done then 1 end) from todos where deleted_at is null and parent_id in ($1) group by parent_id`,
+	)).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"parent_id", "count", "count"}))
+	// Monday is done, Thursday is next
+	next := time.Date(2024, 5, 9, 18, 0, 0, 0, time.UTC)
+	s.mockedDB.ExpectBegin()
+	s.mockedDB.ExpectQuery(regexp.QuoteMeta(`insert into todos (title, description, done, owner_id, created_at, start_at, due_at, priority, position, list_id, parent_id, checklist, recurrence, series_id, completed_at) `+
+		`values ($1, $2, $3, $4, $5, $6, $7, $8, (select coalesce(max(position), 0) + 1 from todos where owner_id = $4), `+
+		`(select id from lists where id = $9 and owner_id = $4), $10, $11, $12, $13, $14) returning id, position`)).
+		WithArgs("water", "the plants", false, 5, created, next.Add(-time.Hour), next, known.PriorityNone, 0, nil, nil, "FREQ=WEEKLY;BYDAY=MO,TH", 1, nil).
+		WillReturnError(sql.ErrConnDone)
+	s.mockedDB.ExpectRollback()
+
+	item := known.TodoItem{Title: "water", Description: "the plants", Done: true, Start: &start, Due: &due, Recurrence: "rrule:freq=weekly;byday=th,mo"}
+	_, err := s.todo.Update(5, 1, item, services.UpdateOptions{Force: true})
+	s.Error(err)
+	s.NoError(s.mockedDB.ExpectationsWereMet(), "the item is not completed without its next occurrence")
+}
+
+func (s *TodoServiceSuite) TestCreate_InvalidRecurrence() {
+	_, err := s.todo.Create(5, known.TodoItem{Title: "water", Description: "the plants", Recurrence: "FREQ=HOURLY"})
+	s.ErrorIs(err, known.ErrValidation)
+}
+
+func (s *TodoServiceSuite) TestMove_NextToItself() {
+	err := s.todo.Move(5, 1, 1, true)
+	s.ErrorIs(err, known.ErrValidation)
 }
 
 func (s *TodoServiceSuite) TestDelete_Ok() {
-	s.mockedDB.ExpectExec(regexp.QuoteMeta(`delete from todos where id = $1`)).
-		WithArgs(1).WillReturnResult(sqlmock.NewResult(1, 1))
-	err := s.todo.Delete(1)
+	s.mockedDB.ExpectBegin()
+	s.mockedDB.ExpectExec(regexp.QuoteMeta(
+		`update todos set deleted_at = $3 where deleted_at is null and id in (with recursive subtree(id, depth) as (select id, 1 from todos where id = $1 and owner_id = $2 and deleted_at is null `+
+			`union all select t.id, s.depth + 1 from todos t join subtree s on t.parent_id = s.id where t.deleted_at is null) select id from subtree)`,
+	)).
+		WithArgs(1, 5, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 2))
+	s.mockedDB.ExpectQuery(regexp.QuoteMeta(
+		`select id from todos where deleted_at = $2 and id in (with recursive subtree(id) as (select id from todos where id = $1 and deleted_at is not null `+
+			`union all select t.id from todos t join subtree s on t.parent_id = s.id) select id from subtree)`,
+	)).WithArgs(1, sqlmock.AnyArg()).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2))
+	s.mockedDB.ExpectExec(regexp.QuoteMeta(`insert into item_revisions (todo_id, actor_id, action, created_at, changes) values ($1, $2, $3, $4, $5)`)).
+		WithArgs(1, 5, known.RevisionDelete, sqlmock.AnyArg(), nil).WillReturnResult(sqlmock.NewResult(1, 1))
+	s.mockedDB.ExpectExec(regexp.QuoteMeta(`insert into item_revisions (todo_id, actor_id, action, created_at, changes) values ($1, $2, $3, $4, $5)`)).
+		WithArgs(2, 5, known.RevisionDelete, sqlmock.AnyArg(), nil).WillReturnResult(sqlmock.NewResult(1, 1))
+	s.mockedDB.ExpectCommit()
+	err := s.todo.Delete(5, 1, services.DeleteOptions{Cascade: true})
 	s.NoError(err)
 }
+
+func (s *TodoServiceSuite) TestDelete_HasSubtasks() {
+	s.mockedDB.ExpectBegin()
+	s.mockedDB.ExpectQuery(regexp.QuoteMeta(`select count(*) from todos where parent_id = $1 and owner_id = $2 and deleted_at is null`)).
+		WithArgs(1, 5).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	s.mockedDB.ExpectRollback()
+	err := s.todo.Delete(5, 1, services.DeleteOptions{})
+	s.ErrorIs(err, known.ErrConflict)
+}
+
+func (s *TodoServiceSuite) TestDelete_ForeignItem() {
+	s.mockedDB.ExpectBegin()
+	s.mockedDB.ExpectQuery(regexp.QuoteMeta(`select count(*) from todos where parent_id = $1 and owner_id = $2 and deleted_at is null`)).
+		WithArgs(1, 6).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	s.mockedDB.ExpectExec(regexp.QuoteMeta(
+		`update todos set deleted_at = $3 where deleted_at is null and id in (with recursive subtree(id, depth) as (select id, 1 from todos where id = $1 and owner_id = $2 and deleted_at is null `+
+			`union all select t.id, s.depth + 1 from todos t join subtree s on t.parent_id = s.id where t.deleted_at is null) select id from subtree)`,
+	)).
+		WithArgs(1, 6, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 0))
+	s.mockedDB.ExpectRollback()
+	err := s.todo.Delete(6, 1, services.DeleteOptions{})
+	s.EqualError(err, "no such item in storage")
+}
+
+func (s *TodoServiceSuite) TestSetParent_Itself() {
+	err := s.todo.SetParent(5, 1, 1)
+	s.ErrorIs(err, known.ErrValidation)
+}
+
+func (s *TodoServiceSuite) TestGetAll_Filtered() {
+	mockedRows := sqlmock.NewRows([]string{"id", "title", "description", "done", "owner_id", "created_at", "start_at", "due_at", "priority", "position", "list_id", "parent_id", "checklist", "recurrence", "series_id", "completed_at", "archived_at", "deleted_at", "version"}).
+		AddRow(3, "3rd", "Buy 100% juice", true, 5, created, nil, nil, 0, 3, nil, nil, nil, nil, nil, nil, nil, nil, 1).
+		AddRow(4, "4th", "More 100% juice", true, 5, created, nil, nil, 0, 4, nil, nil, nil, nil, nil, nil, nil, nil, 1)
+	s.mockedDB.ExpectQuery(regexp.QuoteMeta(
+		`select id, title, description, done, owner_id, created_at, start_at, due_at, priority, position, list_id, parent_id, checklist, recurrence, series_id, completed_at, archived_at, deleted_at, version from todos where owner_id = $1 and deleted_at is null and archived_at is null and `+
+			`(list_id is null or list_id not in (select id from lists where owner_id = $2 and archived)) and done = $3 and `+
+			`(title ilike $4 escape '\' or description ilike $4 escape '\') and (title < $5 or title = $5 and id < $6) order by title desc, id desc limit $7`,
+	)).WithArgs(5, 5, true, `%100\%%`, "4th", 9, 2).WillReturnRows(mockedRows)
+	s.mockedDB.ExpectQuery(regexp.QuoteMeta(
+		`select tt.todo_id, t.name from todo_tags tt join tags t on t.id = tt.tag_id where tt.todo_id in ($1, $2) order by t.name`,
+	)).WithArgs(3, 4).WillReturnRows(sqlmock.NewRows([]string{"todo_id", "name"}))
+	s.mockedDB.ExpectQuery(regexp.QuoteMeta(
+		`select parent_id, count(*), count(case when done then 1 end) from todos where deleted_at is null and parent_id in ($1, $2) group by parent_id`,
+	)).WithArgs(3, 4).WillReturnRows(sqlmock.NewRows([]string{"parent_id", "count", "count"}))
+
+	done := true
+	got, err := s.todo.GetAll(context.Background(), 5, known.TodoQuery{
+		Limit: 1, SortBy: known.SortByTitle, Desc: true, Done: &done, Text: "100%",
+		After: &known.PageCursor{SortBy: known.SortByTitle, Desc: true, Key: "4th", ID: 9},
+	})
+	s.NoError(err)
+	s.Len(got.Items, 1)
+	s.Equal(&known.PageCursor{SortBy: known.SortByTitle, Desc: true, Key: "3rd", ID: 3}, got.Next)
+
+	_, err = s.todo.GetAll(context.Background(), 5, known.TodoQuery{SortBy: known.SortByTitle, After: got.Next})
+	s.ErrorContains(err, "another order")
+}
+
+func (s *TodoServiceSuite) TestGetDue_Views() {
+	// created is Monday 07:08:09 UTC, which is already Monday 15:08:09 in Singapore
+	singapore := time.FixedZone("SGT", 8*60*60)
+	columns := []string{"id", "title", "description", "done", "owner_id", "created_at", "start_at", "due_at", "priority", "position", "list_id", "parent_id", "checklist", "recurrence", "series_id", "completed_at", "archived_at", "deleted_at", "version"}
+	listing := `select id, title, description, done, owner_id, created_at, start_at, due_at, priority, position, list_id, parent_id, checklist, recurrence, series_id, completed_at, archived_at, deleted_at, version from todos where owner_id = $1 and deleted_at is null and archived_at is null and ` +
+		`(list_id is null or list_id not in (select id from lists where owner_id = $2 and archived)) and `
+
+	s.mockedDB.ExpectQuery(regexp.QuoteMeta(listing+`done = $3 and due_at < $4 order by due_at asc nulls last, id asc limit $5`)).
+		WithArgs(5, 5, false, created, services.DefaultPageSize+1).WillReturnRows(sqlmock.NewRows(columns))
+	_, err := s.todo.GetDue(context.Background(), 5, services.ViewOverdue, singapore, known.TodoQuery{})
+	s.NoError(err)
+
+	s.mockedDB.ExpectQuery(regexp.QuoteMeta(listing+`due_at >= $3 and due_at < $4 order by due_at asc nulls last, id asc limit $5`)).
+		WithArgs(5, 5, time.Date(2024, 5, 5, 16, 0, 0, 0, time.UTC), time.Date(2024, 5, 6, 16, 0, 0, 0, time.UTC), services.DefaultPageSize+1).
+		WillReturnRows(sqlmock.NewRows(columns))
+	_, err = s.todo.GetDue(context.Background(), 5, services.ViewToday, singapore, known.TodoQuery{})
+	s.NoError(err)
+
+	s.mockedDB.ExpectQuery(regexp.QuoteMeta(listing+`due_at >= $3 and due_at < $4 order by title asc, id asc limit $5`)).
+		WithArgs(5, 5, time.Date(2024, 5, 6, 0, 0, 0, 0, time.UTC), time.Date(2024, 5, 13, 0, 0, 0, 0, time.UTC), services.DefaultPageSize+1).
+		WillReturnRows(sqlmock.NewRows(columns))
+	_, err = s.todo.GetDue(context.Background(), 5, services.ViewWeek, time.UTC, known.TodoQuery{SortBy: known.SortByTitle})
+	s.NoError(err)
+
+	_, err = s.todo.GetDue(context.Background(), 5, "someday", time.UTC, known.TodoQuery{})
+	s.ErrorIs(err, known.ErrValidation)
+}
+
+// racingStore holds every update back until all the racers have got that far.
+type racingStore struct {
+	*storage.InMemoryStorage
+	racers sync.WaitGroup
+}
+
+func (s *racingStore) Update(item *known.TodoItem) error {
+	s.racers.Done()
+	s.racers.Wait()
+	return s.InMemoryStorage.Update(item)
+}
+
+func TestUpdate_ConcurrentCompletions(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	store := &racingStore{InMemoryStorage: storage.NewMemoryStorage(logger)}
+	todos := services.NewToDoService(store, logger)
+	item, err := todos.Create(1, known.TodoItem{Title: "water", Description: "the plants", Recurrence: "FREQ=DAILY"})
+	require.NoError(t, err)
+
+	// both read the item as not done yet and name no version
+	item.Done = true
+	item.Version = 0
+	errs := make([]error, 2)
+	store.racers.Add(len(errs))
+	var wg sync.WaitGroup
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = todos.Update(1, item.ID, *item, services.UpdateOptions{})
+		}(i)
+	}
+	wg.Wait()
+
+	refused := 0
+	for _, err := range errs {
+		if err != nil {
+			assert.ErrorIs(t, err, storage.ErrStaleVersion)
+			refused++
+		}
+	}
+	assert.Equal(t, 1, refused)
+	series, err := store.GetAll(context.Background(), 1, known.TodoQuery{SeriesID: item.ID})
+	require.NoError(t, err)
+	assert.Len(t, series, 2, "the series goes on once")
+}
@@ -0,0 +1,186 @@
+package services
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/scriptdealer/to-do-go/internal/config"
+	"github.com/scriptdealer/to-do-go/known"
+)
+
+const (
+	AlgorithmHS256 = "HS256"
+	AlgorithmRS256 = "RS256"
+
+	minSecretLength = 32
+	tokenIssuer     = "to-do-go"
+)
+
+var errTokenSettings = errors.New("invalid token settings")
+
+// TokenSettings describe how access tokens are signed and how long issued tokens live.
+// HS256 uses Secret, RS256 uses PrivateKey and verifies with its public half.
+type TokenSettings struct {
+	Algorithm  string
+	Secret     []byte
+	PrivateKey *rsa.PrivateKey
+	AccessTTL  time.Duration
+	RefreshTTL time.Duration
+}
+
+// NewTokenSettings loads the signing keys the configuration points at.
+func NewTokenSettings(cfg config.AuthConfig) (*TokenSettings, error) {
+	settings := TokenSettings{
+		Algorithm:  cfg.Algorithm,
+		Secret:     []byte(cfg.Secret),
+		AccessTTL:  cfg.AccessTTL,
+		RefreshTTL: cfg.RefreshTTL,
+	}
+
+	if cfg.SecretFile != "" {
+		secret, err := os.ReadFile(cfg.SecretFile)
+		if err != nil {
+			return nil, err
+		}
+		settings.Secret = bytes.TrimSpace(secret)
+	}
+
+	if cfg.PrivateKeyFile != "" {
+		raw, err := os.ReadFile(cfg.PrivateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		key, err := ParseRSAPrivateKey(raw)
+		if err != nil {
+			return nil, err
+		}
+		settings.PrivateKey = key
+	}
+
+	return &settings, settings.Validate()
+}
+
+// ParseRSAPrivateKey accepts PEM encoded PKCS#1 or PKCS#8 keys.
+func ParseRSAPrivateKey(raw []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return nil, fmt.Errorf("%w: private key is not PEM encoded", errTokenSettings)
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%w: private key is not an RSA key", errTokenSettings)
+	}
+
+	return rsaKey, nil
+}
+
+func (ts *TokenSettings) Validate() error {
+	switch ts.Algorithm {
+	case AlgorithmHS256:
+		if len(ts.Secret) < minSecretLength {
+			return fmt.Errorf("%w: HS256 needs a secret of at least %d bytes", errTokenSettings, minSecretLength)
+		}
+	case AlgorithmRS256:
+		if ts.PrivateKey == nil {
+			return fmt.Errorf("%w: RS256 needs a private key", errTokenSettings)
+		}
+	default:
+		return fmt.Errorf("%w: unsupported algorithm %q", errTokenSettings, ts.Algorithm)
+	}
+
+	if ts.AccessTTL <= 0 || ts.RefreshTTL <= 0 {
+		return fmt.Errorf("%w: token lifetimes must be positive", errTokenSettings)
+	}
+
+	return nil
+}
+
+type accessClaims struct {
+	Username string `json:"username"`
+	jwt.RegisteredClaims
+}
+
+func (ts *TokenSettings) signAccessToken(user *known.User, id string, now time.Time) (string, error) {
+	claims := accessClaims{
+		Username: user.Username,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    tokenIssuer,
+			Subject:   strconv.Itoa(user.ID),
+			ID:        id,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ts.AccessTTL)),
+		},
+	}
+
+	if ts.Algorithm == AlgorithmRS256 {
+		return jwt.NewWithClaims(jwt.SigningMethodRS256, claims).SignedString(ts.PrivateKey)
+	}
+
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(ts.Secret)
+}
+
+func (ts *TokenSettings) parseAccessToken(token string) (*known.Principal, error) {
+	var claims accessClaims
+	_, err := jwt.ParseWithClaims(token, &claims, func(*jwt.Token) (any, error) {
+		if ts.Algorithm == AlgorithmRS256 {
+			return &ts.PrivateKey.PublicKey, nil
+		}
+		return ts.Secret, nil
+	},
+		jwt.WithValidMethods([]string{ts.Algorithm}),
+		jwt.WithIssuer(tokenIssuer),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	userID, err := strconv.Atoi(claims.Subject)
+	if err != nil || claims.ID == "" {
+		return nil, ErrNotAuthorized
+	}
+
+	return &known.Principal{
+		UserID:    userID,
+		Username:  claims.Username,
+		TokenID:   claims.ID,
+		ExpiresAt: claims.ExpiresAt.Time,
+	}, nil
+}
+
+func randomToken() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// hashToken is what gets persisted for refresh tokens, so a leaked table cannot be replayed.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,68 @@
+package services_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"log/slog"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/scriptdealer/to-do-go/internal/services"
+	"github.com/scriptdealer/to-do-go/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokens_RS256RoundTrip(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	encoded := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	parsed, err := services.ParseRSAPrivateKey(encoded)
+	require.NoError(t, err)
+
+	settings := &services.TokenSettings{
+		Algorithm:  services.AlgorithmRS256,
+		PrivateKey: parsed,
+		AccessTTL:  time.Minute,
+		RefreshTTL: time.Hour,
+	}
+	require.NoError(t, settings.Validate())
+
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	db := storage.NewMemoryStorage(logger)
+	users := services.NewUserService(db, settings, logger)
+
+	_, err = users.Register("Jane", "jane", "s3cret-pass")
+	require.NoError(t, err)
+	tokens, err := users.Login("jane", "s3cret-pass")
+	require.NoError(t, err)
+
+	caller, err := users.Authenticate(tokens.AccessToken)
+	require.NoError(t, err)
+	assert.Equal(t, 1, caller.UserID)
+	assert.Equal(t, "jane", caller.Username)
+
+	hs := services.NewUserService(db, &services.TokenSettings{
+		Algorithm:  services.AlgorithmHS256,
+		Secret:     []byte("some-other-secret-of-enough-size"),
+		AccessTTL:  time.Minute,
+		RefreshTTL: time.Hour,
+	}, logger)
+	_, err = hs.Authenticate(tokens.AccessToken)
+	assert.ErrorIs(t, err, services.ErrNotAuthorized, "an RS256 token must not pass HS256 verification")
+}
+
+func TestTokens_Validate(t *testing.T) {
+	settings := services.TokenSettings{Algorithm: services.AlgorithmHS256, Secret: []byte("short"), AccessTTL: time.Minute, RefreshTTL: time.Hour}
+	assert.Error(t, settings.Validate())
+
+	settings.Algorithm = services.AlgorithmRS256
+	assert.Error(t, settings.Validate())
+
+	settings.Algorithm = "none"
+	assert.Error(t, settings.Validate())
+}
@@ -1,5 +1,244 @@
 package services
 
-//Я так понял, это не нужно
+import (
+	"errors"
+	"log/slog"
+	"net/mail"
+	"strings"
+	"time"
 
-type UserLogic interface{}
+	"github.com/scriptdealer/to-do-go/internal/storage"
+	"github.com/scriptdealer/to-do-go/known"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	minPasswordLength = 8
+	// maxPasswordLength is the most bcrypt hashes, in bytes.
+	maxPasswordLength = 72
+	maxEmailLength    = 254
+)
+
+var (
+	ErrInvalidSignup      = known.NewError(known.ErrValidation, "name, username and a password of 8 to 72 bytes are required")
+	ErrInvalidCredentials = known.NewError(known.ErrUnauthorized, "invalid username or password")
+	ErrNotAuthorized      = known.NewError(known.ErrUnauthorized, "not authorized")
+	ErrUnknownTimeZone    = known.NewError(known.ErrValidation, "unknown time zone, expected an IANA name like Europe/Berlin")
+	ErrInvalidEmail       = known.NewError(known.ErrValidation, "invalid email address, expected one like jane@example.com")
+)
+
+type UserLogic interface {
+	Register(name, username, password string) (*known.User, error)
+	Login(username, password string) (*known.TokenPair, error)
+	Refresh(refreshToken string) (*known.TokenPair, error)
+	Logout(caller *known.Principal, refreshToken string) error
+	Authenticate(accessToken string) (*known.Principal, error)
+	Get(id int) (*known.User, error)
+	// UpdateProfile changes the name, the time zone and the email of the user, empty values keep the current ones.
+	UpdateProfile(id int, name, timeZone, email string) (*known.User, error)
+}
+
+type UserService struct {
+	store  storage.AccountStore
+	tokens *TokenSettings
+	Log    *slog.Logger
+}
+
+func NewUserService(db storage.AccountStore, tokens *TokenSettings, logger *slog.Logger) *UserService {
+	return &UserService{store: db, tokens: tokens, Log: logger}
+}
+
+func (us *UserService) Register(name, username, password string) (*known.User, error) {
+	username = strings.TrimSpace(username)
+	if name == "" || username == "" || len(password) < minPasswordLength || len(password) > maxPasswordLength {
+		return nil, ErrInvalidSignup
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	user := known.User{
+		Name:         name,
+		Username:     username,
+		PasswordHash: string(hash),
+		TimeZone:     "UTC",
+	}
+	if err := us.store.CreateUser(&user); err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// Login checks the credentials and starts a new refresh token family.
+func (us *UserService) Login(username, password string) (*known.TokenPair, error) {
+	user, err := us.store.GetUserByUsername(strings.TrimSpace(username))
+	if errors.Is(err, storage.ErrNoUser) {
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	family, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+
+	return us.issue(user, family)
+}
+
+// Refresh rotates a refresh token: the presented one is revoked and a new pair is issued.
+// Presenting an already rotated token means it leaked, so its whole family is revoked.
+func (us *UserService) Refresh(refreshToken string) (*known.TokenPair, error) {
+	hash := hashToken(refreshToken)
+	stored, err := us.store.GetRefreshToken(hash)
+	if errors.Is(err, storage.ErrNoToken) {
+		return nil, ErrNotAuthorized
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if stored.ExpiresAt.Before(time.Now()) {
+		return nil, ErrNotAuthorized
+	}
+
+	rotated, err := us.store.RevokeRefreshToken(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	if !rotated {
+		us.Log.Warn("refresh token reused, revoking its family", slog.Int("user", stored.UserID))
+		if err := us.store.RevokeTokenFamily(stored.Family); err != nil {
+			return nil, err
+		}
+		return nil, ErrNotAuthorized
+	}
+
+	user, err := us.store.GetUser(stored.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	return us.issue(user, stored.Family)
+}
+
+// Logout revokes the caller's access token and, when given, the refresh token family it belongs to.
+func (us *UserService) Logout(caller *known.Principal, refreshToken string) error {
+	if err := us.store.RevokeAccessToken(caller.TokenID, caller.ExpiresAt); err != nil {
+		return err
+	}
+
+	if refreshToken == "" {
+		return nil
+	}
+
+	stored, err := us.store.GetRefreshToken(hashToken(refreshToken))
+	if errors.Is(err, storage.ErrNoToken) || (err == nil && stored.UserID != caller.UserID) {
+		return ErrNotAuthorized
+	}
+	if err != nil {
+		return err
+	}
+
+	return us.store.RevokeTokenFamily(stored.Family)
+}
+
+// Authenticate verifies an access token and rejects the revoked ones.
+func (us *UserService) Authenticate(accessToken string) (*known.Principal, error) {
+	caller, err := us.tokens.parseAccessToken(accessToken)
+	if err != nil {
+		return nil, ErrNotAuthorized
+	}
+
+	revoked, err := us.store.IsAccessTokenRevoked(caller.TokenID)
+	if err != nil {
+		return nil, err
+	}
+
+	if revoked {
+		return nil, ErrNotAuthorized
+	}
+
+	return caller, nil
+}
+
+func (us *UserService) Get(id int) (*known.User, error) {
+	return us.store.GetUser(id)
+}
+
+func (us *UserService) UpdateProfile(id int, name, timeZone, email string) (*known.User, error) {
+	user, err := us.store.GetUser(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if name != "" {
+		user.Name = name
+	}
+	if timeZone != "" {
+		// "Local" would be the zone of the server, not of the user
+		if _, err := time.LoadLocation(timeZone); err != nil || timeZone == "Local" {
+			return nil, ErrUnknownTimeZone
+		}
+		user.TimeZone = timeZone
+	}
+	if email != "" {
+		// a bare address, display names like "Jane <jane@example.com>" are not kept
+		address, err := mail.ParseAddress(email)
+		if err != nil || address.Address != email || len(email) > maxEmailLength {
+			return nil, ErrInvalidEmail
+		}
+		user.Email = email
+	}
+
+	if err := us.store.UpdateUser(user); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (us *UserService) issue(user *known.User, family string) (*known.TokenPair, error) {
+	now := time.Now()
+
+	tokenID, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+
+	access, err := us.tokens.signAccessToken(user, tokenID, now)
+	if err != nil {
+		return nil, err
+	}
+
+	refresh, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+
+	err = us.store.SaveRefreshToken(&known.RefreshToken{
+		Hash:      hashToken(refresh),
+		UserID:    user.ID,
+		Family:    family,
+		ExpiresAt: now.Add(us.tokens.RefreshTTL),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &known.TokenPair{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(us.tokens.AccessTTL.Seconds()),
+	}, nil
+}
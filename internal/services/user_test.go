@@ -0,0 +1,141 @@
+package services_test
+
+import (
+	"database/sql"
+	"log/slog"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/scriptdealer/to-do-go/internal/services"
+	"github.com/scriptdealer/to-do-go/internal/storage"
+	"github.com/stretchr/testify/suite"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type UserServiceSuite struct {
+	suite.Suite
+
+	logger   *slog.Logger
+	db       *sql.DB
+	mockedDB sqlmock.Sqlmock
+
+	users *services.UserService
+}
+
+func TestUsers(t *testing.T) {
+	suite.Run(t, &UserServiceSuite{})
+}
+
+func (s *UserServiceSuite) SetupTest() {
+	s.logger = slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+	db, mock, err := sqlmock.New()
+	s.NoError(err)
+	s.db, s.mockedDB = db, mock
+
+	s.users = services.NewUserService(
+		&storage.PostgresStorage{Log: s.logger, DB: s.db},
+		&services.TokenSettings{
+			Algorithm:  services.AlgorithmHS256,
+			Secret:     []byte("user-service-secret-of-32-bytes!"),
+			AccessTTL:  time.Minute,
+			RefreshTTL: time.Hour,
+		},
+		s.logger,
+	)
+}
+
+func (s *UserServiceSuite) TestRegister_Ok() {
+	s.mockedDB.ExpectQuery(regexp.QuoteMeta(`insert into users (name, username, password_hash, time_zone) values ($1, $2, $3, $4) returning id`)).
+		WithArgs("Jane", "jane", sqlmock.AnyArg(), "UTC").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+
+	user, err := s.users.Register("Jane", " jane ", "s3cret-pass")
+	s.NoError(err)
+	s.Equal(7, user.ID)
+	s.NoError(bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte("s3cret-pass")))
+}
+
+func (s *UserServiceSuite) TestRegister_Taken() {
+	s.mockedDB.ExpectQuery(regexp.QuoteMeta(`insert into users`)).
+		WithArgs("Jane", "jane", sqlmock.AnyArg(), "UTC").WillReturnError(&pq.Error{Code: "23505"})
+
+	_, err := s.users.Register("Jane", "jane", "s3cret-pass")
+	s.ErrorIs(err, storage.ErrUsernameTaken)
+}
+
+func (s *UserServiceSuite) TestRegister_ShortPassword() {
+	_, err := s.users.Register("Jane", "jane", "short")
+	s.ErrorIs(err, services.ErrInvalidSignup)
+}
+
+func (s *UserServiceSuite) TestRegister_LongPassword() {
+	_, err := s.users.Register("Jane", "jane", strings.Repeat("p", 73))
+	s.ErrorIs(err, services.ErrInvalidSignup, "bcrypt refuses passwords over 72 bytes")
+}
+
+func (s *UserServiceSuite) TestLogin_UnknownUser() {
+	s.mockedDB.ExpectQuery(regexp.QuoteMeta(`select id, name, username, password_hash, time_zone, email from users where username = $1`)).
+		WithArgs("ghost").WillReturnRows(sqlmock.NewRows([]string{"id", "name", "username", "password_hash", "time_zone", "email"}))
+
+	_, err := s.users.Login("ghost", "whatever1")
+	s.ErrorIs(err, services.ErrInvalidCredentials)
+}
+
+func (s *UserServiceSuite) TestLogin_DbFailure() {
+	s.mockedDB.ExpectQuery(regexp.QuoteMeta(`select id, name, username, password_hash, time_zone, email from users where username = $1`)).
+		WithArgs("jane").WillReturnError(sql.ErrConnDone)
+
+	_, err := s.users.Login("jane", "s3cret-pass")
+	s.EqualError(err, "sql: connection is already closed")
+}
+
+func (s *UserServiceSuite) TestAuthenticate_Garbage() {
+	_, err := s.users.Authenticate("not.a.jwt")
+	s.ErrorIs(err, services.ErrNotAuthorized)
+}
+
+func (s *UserServiceSuite) TestAuthenticate_Revoked() {
+	storedHash, err := bcrypt.GenerateFromPassword([]byte("s3cret-pass"), bcrypt.MinCost)
+	s.NoError(err)
+	s.mockedDB.ExpectQuery(regexp.QuoteMeta(`select id, name, username, password_hash, time_zone, email from users where username = $1`)).
+		WithArgs("jane").WillReturnRows(sqlmock.NewRows([]string{"id", "name", "username", "password_hash", "time_zone", "email"}).
+		AddRow(3, "Jane", "jane", string(storedHash), "UTC", nil))
+	s.mockedDB.ExpectExec(regexp.QuoteMeta(`insert into refresh_tokens (token_hash, user_id, family, expires_at) values ($1, $2, $3, $4)`)).
+		WithArgs(sqlmock.AnyArg(), 3, sqlmock.AnyArg(), sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+
+	tokens, err := s.users.Login("jane", "s3cret-pass")
+	s.NoError(err)
+
+	s.mockedDB.ExpectQuery(regexp.QuoteMeta(`select exists(select 1 from revoked_tokens where token_id = $1)`)).
+		WithArgs(sqlmock.AnyArg()).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
+	_, err = s.users.Authenticate(tokens.AccessToken)
+	s.ErrorIs(err, services.ErrNotAuthorized)
+}
+
+func (s *UserServiceSuite) TestUpdateProfile_TimeZone() {
+	s.mockedDB.ExpectQuery(regexp.QuoteMeta(`select id, name, username, password_hash, time_zone, email from users where id = $1`)).
+		WithArgs(3).WillReturnRows(sqlmock.NewRows([]string{"id", "name", "username", "password_hash", "time_zone", "email"}).
+		AddRow(3, "Jane", "jane", "hash", "UTC", nil))
+	s.mockedDB.ExpectExec(regexp.QuoteMeta(`update users set name = $1, time_zone = $2, email = $3 where id = $4`)).
+		WithArgs("Jane", "Asia/Tokyo", nil, 3).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	user, err := s.users.UpdateProfile(3, "", "Asia/Tokyo", "")
+	s.NoError(err)
+	s.Equal("Asia/Tokyo", user.TimeZone)
+	s.Equal("Asia/Tokyo", user.Location().String())
+}
+
+func (s *UserServiceSuite) TestUpdateProfile_UnknownTimeZone() {
+	s.mockedDB.ExpectQuery(regexp.QuoteMeta(`select id, name, username, password_hash, time_zone, email from users where id = $1`)).
+		WithArgs(3).WillReturnRows(sqlmock.NewRows([]string{"id", "name", "username", "password_hash", "time_zone", "email"}).
+		AddRow(3, "Jane", "jane", "hash", "UTC", nil))
+
+	_, err := s.users.UpdateProfile(3, "", "Mars/Olympus_Mons", "")
+	s.ErrorIs(err, services.ErrUnknownTimeZone)
+}
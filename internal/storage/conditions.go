@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// conditions accumulates a where clause of a todo listing along with its numbered arguments.
+// It always starts with the owner, so no listing can leak items of other users.
+type conditions struct {
+	clauses []string
+	args    []any
+}
+
+func newConditions(owner int) *conditions {
+	where := &conditions{}
+	where.add("owner_id = %s", owner)
+
+	return where
+}
+
+// add appends a clause whose %s verbs (or %[1]s for a repeated one) are the placeholders of values.
+func (c *conditions) add(clause string, values ...any) {
+	placeholders := make([]any, len(values))
+	for i, value := range values {
+		placeholders[i] = c.arg(value)
+	}
+
+	c.clauses = append(c.clauses, fmt.Sprintf(clause, placeholders...))
+}
+
+func (c *conditions) arg(value any) string {
+	c.args = append(c.args, value)
+	return "$" + strconv.Itoa(len(c.args))
+}
+
+func (c *conditions) String() string {
+	return strings.Join(c.clauses, " and ")
+}
+
+// likePattern matches text anywhere, taking its own wildcards literally.
+func likePattern(text string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(text)
+	return "%" + escaped + "%"
+}
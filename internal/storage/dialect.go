@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"errors"
+
+	"github.com/lib/pq"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// dialect tells the SQL backends apart where their queries cannot be shared.
+// The zero value is Postgres.
+type dialect int
+
+const (
+	dialectPostgres dialect = iota
+	dialectSQLite
+)
+
+func (d dialect) String() string {
+	if d == dialectSQLite {
+		return "sqlite"
+	}
+
+	return "postgres"
+}
+
+// ilike is the case-insensitive LIKE; SQLite's own LIKE already ignores case.
+func (d dialect) ilike() string {
+	if d == dialectSQLite {
+		return "like"
+	}
+
+	return "ilike"
+}
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == "23505"
+	}
+
+	var liteErr *sqlite.Error
+	if errors.As(err, &liteErr) {
+		return liteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE
+	}
+
+	return false
+}
@@ -0,0 +1,33 @@
+package storage
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpen_Schemes(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+	memory, err := Open("memory://", logger)
+	require.NoError(t, err)
+	assert.IsType(t, &InMemoryStorage{}, memory)
+
+	for _, dsn := range []string{
+		"sqlite://" + filepath.Join(t.TempDir(), "todo.db"),
+		"sqlite::memory:",
+	} {
+		lite, err := Open(dsn, logger)
+		require.NoError(t, err, dsn)
+		assert.IsType(t, &SQLiteStorage{}, lite)
+		assert.NoError(t, lite.Init(), dsn)
+		assert.NoError(t, lite.Close(), dsn)
+	}
+
+	_, err = Open("mongodb://localhost", logger)
+	assert.EqualError(t, err, `unknown storage "mongodb", expected one of: memory, postgres, postgresql, sqlite`)
+}
@@ -2,95 +2,1559 @@ package storage
 
 import (
 	"context"
-	"errors"
 	"log/slog"
+	"maps"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/scriptdealer/to-do-go/known"
 )
 
-var errNoItem = errors.New("no such item in storage")
+var (
+	ErrNoItem        = known.NewError(known.ErrNotFound, "no such item in storage")
+	ErrNoUser        = known.NewError(known.ErrNotFound, "no such user in storage")
+	ErrUsernameTaken = known.NewError(known.ErrConflict, "username is already taken")
+	ErrNoToken       = known.NewError(known.ErrNotFound, "no such token in storage")
+	ErrNoTag         = known.NewError(known.ErrNotFound, "no such tag in storage")
+	ErrTagTaken      = known.NewError(known.ErrConflict, "tag name is already taken, merge the tags instead")
+	ErrNoList        = known.NewError(known.ErrNotFound, "no such list in storage")
+	ErrListTaken     = known.NewError(known.ErrConflict, "list name is already taken")
+	ErrHasSubtasks   = known.NewError(known.ErrConflict, "the item has subtasks, delete them first or along with it")
+	ErrTooDeep       = known.NewError(known.ErrValidation, "subtasks are nested at most 5 levels deep")
+	ErrSubtaskCycle  = known.NewError(known.ErrValidation, "an item cannot become a subtask of its own subtask")
+	ErrSubtaskList   = known.NewError(known.ErrValidation, "subtasks stay in the list of their parent, move the top level item instead")
+	ErrNoBlocker     = known.NewError(known.ErrNotFound, "the item is not blocked by that item")
+	ErrBlockerCycle  = known.NewError(known.ErrConflict, "the dependency would create a cycle")
+	ErrNoReminder    = known.NewError(known.ErrNotFound, "no such reminder in storage")
+	ErrParentTrashed = known.NewError(known.ErrConflict, "the parent of the item is in the trash, restore it first")
+	ErrStaleVersion  = known.NewError(known.ErrPrecondition, "the item has changed since that version, fetch it again")
+)
+
+// MaxDepth is the number of levels an item and its subtasks can span, the item itself included.
+const MaxDepth = 5
 
+// ToDoStore keeps todo items of many users; every method is scoped to an owner,
+// and items of other owners are reported as missing.
 type ToDoStore interface {
-	GetOne(id int) (*known.TodoItem, error)
-	GetAll(ctx context.Context) ([]*known.TodoItem, error)
+	GetOne(owner, id int) (*known.TodoItem, error)
+	GetAll(ctx context.Context, owner int, query known.TodoQuery) ([]*known.TodoItem, error)
+	// Create stores the item and fills in what the storage sets: its ID, its position at the end
+	// of the manual order, its list as a subtask and version 1.
 	Create(item *known.TodoItem) error
+	// Update bumps the version of the item, refusing a non-zero item.Version other than the stored one;
+	// an update changing none of the fields it replaces is not written and keeps the version.
 	Update(item *known.TodoItem) error
-	Delete(id int) error
+	// Move puts the item id right before or after the anchor item in the manual order of owner.
+	Move(owner, id, anchor int, before bool) error
+	// Delete moves the item to the trash, refusing an item with subtasks unless cascade is set,
+	// which trashes them along; trashed items are left out of everything but the trash listing.
+	// A non-zero version must be the current one of the item.
+	Delete(owner, id int, cascade bool, version int) error
+	// Restore takes the item out of the trash along with the subtasks trashed with it.
+	Restore(owner, id int) error
+	// Purge deletes a trashed item for good, along with its subtasks.
+	Purge(owner, id int) error
+	// PurgeTrash deletes for good the items of every owner trashed before the given time,
+	// reporting how many were purged, subtasks not counted.
+	PurgeTrash(before time.Time) (int, error)
+	// ArchiveDone archives the items of every owner done since before the given time,
+	// reporting how many were archived.
+	ArchiveDone(before time.Time) (int, error)
+	// Unarchive brings an archived item back into the active listings, still done.
+	Unarchive(owner, id int) error
+	// SetParent makes the item id a subtask of parent, or a top level item for a zero parent;
+	// the item and its own subtasks follow the parent into its list.
+	SetParent(owner, id, parent int) error
+	// CompleteSubtasks marks every subtask below the item id as done, bumping the versions of those that were not.
+	CompleteSubtasks(owner, id int) error
+	// GetRevisions lists the revisions of the item id, trashed or not, the oldest first. Every change
+	// of the fields Update replaces is recorded as a revision of the owner along with the change itself,
+	// so are deleting and restoring the item, and the revisions go away when the item is purged.
+	GetRevisions(owner, id int) ([]*known.Revision, error)
+
+	CreateList(list *known.List) error
+	// GetList and GetLists count the open and done items of the lists;
+	// GetLists leaves out the archived lists unless asked for them.
+	GetList(owner, id int) (*known.List, error)
+	GetLists(owner int, archived bool) ([]*known.List, error)
+	RenameList(owner, id int, name string) error
+	ArchiveList(owner, id int, archived bool) error
+	// DeleteList moves the items of the list back to the inbox.
+	DeleteList(owner, id int) error
+	// MoveToList puts the item id along with its subtasks into a list, or into the inbox for a zero list.
+	MoveToList(owner, id, list int) error
+
+	// AddBlocker makes the item id blocked by the item blocker, unless the blocker
+	// already depends on it, directly or not; adding an existing edge is a no-op.
+	AddBlocker(owner, id, blocker int) error
+	RemoveBlocker(owner, id, blocker int) error
+	// GetBlockers lists the items directly blocking the item id in the manual order.
+	GetBlockers(owner, id int) ([]*known.TodoItem, error)
+	// GetDependencies lists the edges between the owner's undone items.
+	GetDependencies(owner int) ([]known.Dependency, error)
+}
+
+// TagStore keeps the tags of many users; items refer to them by ID.
+type TagStore interface {
+	CreateTag(tag *known.Tag) error
+	GetTags(owner int) ([]*known.Tag, error)
+	RenameTag(owner, id int, name string) error
+	// MergeTags moves the items of the tag from over to the tag into and drops the former.
+	MergeTags(owner, from, into int) error
+	DeleteTag(owner, id int) error
+}
+
+// ReminderStore keeps the reminders of items along with their delivery state,
+// so each of them fires once, across restarts and several servers alike.
+type ReminderStore interface {
+	// CreateReminder resolves the firing time of the reminder against the due date of its item,
+	// and Update moves it along with the due date until the reminder is sent.
+	CreateReminder(reminder *known.Reminder) error
+	// GetReminders lists the reminders of the item id by their firing time.
+	GetReminders(owner, id int) ([]*known.Reminder, error)
+	DeleteReminder(owner, id, reminder int) error
+	// DueReminders lists up to limit unsent reminders of undone items due by now, the earliest
+	// first, leaving out those whose delivery failed maxAttempts times and those claimed since lapsed.
+	DueReminders(now, lapsed time.Time, maxAttempts, limit int) ([]*known.Reminder, error)
+	// ClaimReminder marks the reminder as being delivered from the given time on, reporting false
+	// when it is sent already or claimed since lapsed; a claim before lapsed is taken over.
+	ClaimReminder(id int, at, lapsed time.Time) (bool, error)
+	// SentReminder ends the claim of a delivered reminder, marking it as sent at the given time.
+	SentReminder(id int, at time.Time) error
+	// ReleaseReminder takes a failed delivery back, counting the attempt.
+	ReleaseReminder(id int) error
+	// Lead takes the lock of the reminder scheduler, reporting false while another server holds it;
+	// the lock is held until release is called.
+	Lead(ctx context.Context) (release func(), acquired bool, err error)
+}
+
+type UserStore interface {
+	CreateUser(user *known.User) error
+	GetUser(id int) (*known.User, error)
+	GetUserByUsername(username string) (*known.User, error)
+	// UpdateUser saves the profile fields of the user, the name and the time zone.
+	UpdateUser(user *known.User) error
+}
+
+// TokenStore keeps refresh tokens for rotation and the IDs of access tokens
+// revoked before their expiry.
+type TokenStore interface {
+	SaveRefreshToken(token *known.RefreshToken) error
+	GetRefreshToken(hash string) (*known.RefreshToken, error)
+	// RevokeRefreshToken reports whether the token was still active, so only one
+	// of several concurrent rotations of the same token succeeds.
+	RevokeRefreshToken(hash string) (bool, error)
+	RevokeTokenFamily(family string) error
+	RevokeAccessToken(id string, expiresAt time.Time) error
+	IsAccessTokenRevoked(id string) (bool, error)
+}
+
+type AccountStore interface {
+	UserStore
+	TokenStore
+}
+
+// IdempotencyStore keeps the requests of many users made with idempotency keys, along with
+// the responses to them, until they expire.
+type IdempotencyStore interface {
+	// ClaimIdempotencyKey stores the request unless the owner made one with the same key that is
+	// still kept at now, which is returned instead; nil means the key is claimed for the request.
+	// Expired requests of every owner are dropped on the way.
+	ClaimIdempotencyKey(request *known.IdempotentRequest, now time.Time) (*known.IdempotentRequest, error)
+	// SaveIdempotentResponse keeps the status, header and body of the request with its claimed key.
+	SaveIdempotentResponse(request *known.IdempotentRequest) error
+	// ReleaseIdempotencyKey drops the request made with the key, so the key can be used again.
+	ReleaseIdempotencyKey(owner int, key string) error
 }
 
 type InMemoryStorage struct {
-	ram          map[int]known.TodoItem
-	ramLock      sync.Mutex
-	currentIndex int
-	logger       *slog.Logger
+	ram map[int]known.TodoItem
+	// trash keeps the deleted items apart, so everything reading ram only sees live ones.
+	trash         map[int]known.TodoItem
+	ramLock       sync.Mutex
+	currentIndex  int
+	tags          map[int]known.Tag
+	tagIndex      int
+	todoTags      map[int]map[int]bool
+	lists         map[int]known.List
+	blockers      map[int]map[int]bool
+	reminders     map[int]known.Reminder
+	reminderIndex int
+	// revisions are kept by item, the oldest first.
+	revisions     map[int][]known.Revision
+	revisionIndex int
+	listIndex     int
+	users         map[int]known.User
+	userIndex     int
+	refresh       map[string]known.RefreshToken
+	revoked       map[string]time.Time
+	idempotent    map[idempotencyKey]known.IdempotentRequest
+	logger        *slog.Logger
+}
+
+func init() {
+	Register("memory", func(_ *url.URL, logger *slog.Logger) (Backend, error) {
+		return NewMemoryStorage(logger), nil
+	})
 }
 
 func NewMemoryStorage(logger *slog.Logger) *InMemoryStorage {
 	logger.Info("In-memory storage selected")
 
 	return &InMemoryStorage{
-		ram:    make(map[int]known.TodoItem),
-		logger: logger,
+		ram:        make(map[int]known.TodoItem),
+		trash:      make(map[int]known.TodoItem),
+		tags:       make(map[int]known.Tag),
+		todoTags:   make(map[int]map[int]bool),
+		lists:      make(map[int]known.List),
+		blockers:   make(map[int]map[int]bool),
+		reminders:  make(map[int]known.Reminder),
+		revisions:  make(map[int][]known.Revision),
+		users:      make(map[int]known.User),
+		refresh:    make(map[string]known.RefreshToken),
+		revoked:    make(map[string]time.Time),
+		idempotent: make(map[idempotencyKey]known.IdempotentRequest),
+		logger:     logger,
 	}
 }
 
-func (tds *InMemoryStorage) GetOne(id int) (*known.TodoItem, error) {
+func (tds *InMemoryStorage) Init() error {
+	return nil
+}
+
+func (tds *InMemoryStorage) Close() error {
+	return nil
+}
+
+func (tds *InMemoryStorage) GetOne(owner, id int) (*known.TodoItem, error) {
 	tds.ramLock.Lock()
 	defer tds.ramLock.Unlock()
 
 	result, found := tds.ram[id]
-	if found {
+	if found && result.OwnerID == owner {
+		result.Tags = tds.tagNames(id)
+		result.Progress = tds.progress(&result)
 		return &result, nil
 	}
 
-	return nil, errNoItem
+	return nil, ErrNoItem
 }
 
-func (tds *InMemoryStorage) GetAll(ctx context.Context) ([]*known.TodoItem, error) {
+func (tds *InMemoryStorage) GetAll(ctx context.Context, owner int, query known.TodoQuery) ([]*known.TodoItem, error) {
 	tds.ramLock.Lock()
 	defer tds.ramLock.Unlock()
 
+	items := tds.ram
+	if query.Trashed {
+		items = tds.trash
+	}
+
 	result := make([]*known.TodoItem, 0)
-	for k := range tds.ram {
-		v := tds.ram[k]
-		result = append(result, &v)
+	for k := range items {
+		v := items[k]
+		if v.OwnerID != owner {
+			continue
+		}
+		if query.ListID != 0 && v.ListID != query.ListID || query.ListID == 0 && !query.Trashed && tds.lists[v.ListID].Archived {
+			continue
+		}
+		if query.ParentID != 0 && v.ParentID != query.ParentID {
+			continue
+		}
+		if query.SeriesID != 0 && v.ID != query.SeriesID && v.SeriesID != query.SeriesID {
+			continue
+		}
+		if !query.Trashed && !query.AnyArchived && (v.Archived != nil) != query.Archived {
+			continue
+		}
+		v.Tags = tds.tagNames(v.ID)
+		v.Progress = tds.progress(&v)
+		if matches(&v, query) {
+			result = append(result, &v)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool { return precedes(result[i], result[j], query) })
+	if query.After != nil {
+		pivot := cursorItem(query.After)
+		result = result[sort.Search(len(result), func(i int) bool { return precedes(pivot, result[i], query) }):]
+	}
+
+	return paginate(result, query), nil
+}
+
+// precedes orders the items of a listing the way query sorts them.
+func precedes(a, b *known.TodoItem, query known.TodoQuery) bool {
+	if query.SortBy == known.SortByDue && (a.Due == nil) != (b.Due == nil) {
+		return b.Due == nil
+	}
+	if query.SortBy == known.SortByCompleted && (a.Completed == nil) != (b.Completed == nil) {
+		return b.Completed == nil
+	}
+	if query.Desc {
+		return less(b, a, query.SortBy)
+	}
+	return less(a, b, query.SortBy)
+}
+
+// cursorItem stands for the item a cursor marks, to be compared with the listed ones.
+func cursorItem(cursor *known.PageCursor) *known.TodoItem {
+	item := known.TodoItem{ID: cursor.ID}
+	switch key := cursor.Key.(type) {
+	case known.Priority:
+		item.Priority = key
+	case string:
+		item.Title = key
+	case float64:
+		item.Position = key
+	case time.Time:
+		switch cursor.SortBy {
+		case known.SortByCreated:
+			item.Created = key
+		case known.SortByDue:
+			item.Due = &key
+		case known.SortByCompleted:
+			item.Completed = &key
+		case known.SortByDeleted:
+			item.Deleted = &key
+		}
+	}
+
+	return &item
+}
+
+func matches(item *known.TodoItem, query known.TodoQuery) bool {
+	if query.Done != nil && item.Done != *query.Done {
+		return false
+	}
+
+	if query.Text != "" {
+		text := strings.ToLower(query.Text)
+		if !strings.Contains(strings.ToLower(item.Title), text) && !strings.Contains(strings.ToLower(item.Description), text) {
+			return false
+		}
+	}
+
+	if len(query.Tags) > 0 {
+		found := 0
+		for _, name := range query.Tags {
+			if slices.Contains(item.Tags, name) {
+				found++
+			}
+		}
+		if found == 0 || (!query.AnyTag && found < len(query.Tags)) {
+			return false
+		}
+	}
+
+	if query.DueFrom != nil && (item.Due == nil || item.Due.Before(*query.DueFrom)) {
+		return false
+	}
+	if query.DueBefore != nil && (item.Due == nil || !item.Due.Before(*query.DueBefore)) {
+		return false
+	}
+
+	return true
+}
+
+// less orders by the sort field, breaking ties by ID like the SQL backends do.
+func less(a, b *known.TodoItem, sortBy string) bool {
+	switch sortBy {
+	case known.SortByID:
+	case known.SortByPriority:
+		if a.Priority != b.Priority {
+			return a.Priority < b.Priority
+		}
+	case known.SortByTitle:
+		if a.Title != b.Title {
+			return a.Title < b.Title
+		}
+	case known.SortByCreated:
+		if !a.Created.Equal(b.Created) {
+			return a.Created.Before(b.Created)
+		}
+	case known.SortByDue:
+		if a.Due != nil && b.Due != nil && !a.Due.Equal(*b.Due) {
+			return a.Due.Before(*b.Due)
+		}
+	case known.SortByCompleted:
+		if a.Completed != nil && b.Completed != nil && !a.Completed.Equal(*b.Completed) {
+			return a.Completed.Before(*b.Completed)
+		}
+	case known.SortByDeleted:
+		if a.Deleted != nil && b.Deleted != nil && !a.Deleted.Equal(*b.Deleted) {
+			return a.Deleted.Before(*b.Deleted)
+		}
+	default:
+		if a.Position != b.Position {
+			return a.Position < b.Position
+		}
+	}
+
+	return a.ID < b.ID
+}
+
+func paginate(items []*known.TodoItem, query known.TodoQuery) []*known.TodoItem {
+	if query.Limit > 0 && query.Limit < len(items) {
+		items = items[:query.Limit]
 	}
 
-	return result, nil
+	return items
 }
 
 func (tds *InMemoryStorage) Create(item *known.TodoItem) error {
 	tds.ramLock.Lock()
 	defer tds.ramLock.Unlock()
 
+	if item.ParentID != 0 {
+		parent, found := tds.ram[item.ParentID]
+		if !found || parent.OwnerID != item.OwnerID {
+			return ErrNoItem
+		}
+		if tds.depth(parent.ID)+1 > MaxDepth {
+			return ErrTooDeep
+		}
+		item.ListID = parent.ListID
+	}
+
 	tds.currentIndex++
 	item.ID = tds.currentIndex
+	item.Version = 1
+	item.Position = 1
+	for _, existing := range tds.ram {
+		if existing.OwnerID == item.OwnerID && existing.Position >= item.Position {
+			item.Position = existing.Position + 1
+		}
+	}
 	tds.ram[tds.currentIndex] = *item
+	tds.linkTags(item.OwnerID, item.ID, item.Tags)
 
-	return nil
+	return tds.addRevision(item.OwnerID, item.ID, known.RevisionCreate, nil, item)
 }
 
 func (tds *InMemoryStorage) Update(item *known.TodoItem) error {
 	tds.ramLock.Lock()
 	defer tds.ramLock.Unlock()
-	_, found := tds.ram[item.ID]
-	if found {
+	existing, found := tds.ram[item.ID]
+	if found && existing.OwnerID == item.OwnerID {
+		if item.Version != 0 && item.Version != existing.Version {
+			return ErrStaleVersion
+		}
+		existing.Tags = tds.tagNames(item.ID)
+		changes, err := known.Diff(&existing, item)
+		if err != nil {
+			return err
+		}
+		if len(changes) == 0 {
+			// nothing is written, so the version clients hold stays current
+			item.Version = existing.Version
+			return nil
+		}
+		item.Version = existing.Version + 1
+		item.Created = existing.Created
+		item.Position = existing.Position
+		item.ListID = existing.ListID
+		item.ParentID = existing.ParentID
+		item.SeriesID = existing.SeriesID
+		// an item stays completed since it was first marked as done, and leaves the archive when it is undone
+		switch {
+		case !item.Done:
+			item.Completed, item.Archived = nil, nil
+		case existing.Done && existing.Completed != nil:
+			item.Completed, item.Archived = existing.Completed, existing.Archived
+		}
 		tds.ram[item.ID] = *item
-		return nil
+		for id, reminder := range tds.reminders {
+			if reminder.TodoID == item.ID && reminder.MinutesBefore != nil && reminder.SentAt == nil {
+				reminder.Resolve(item.Due)
+				tds.reminders[id] = reminder
+			}
+		}
+		delete(tds.todoTags, item.ID)
+		tds.linkTags(item.OwnerID, item.ID, item.Tags)
+		return tds.addRevision(item.OwnerID, item.ID, known.RevisionUpdate, &existing, item)
 	}
 
-	return errNoItem
+	return ErrNoItem
 }
 
-func (tds *InMemoryStorage) Delete(id int) error {
+func (tds *InMemoryStorage) Move(owner, id, anchor int, before bool) error {
 	tds.ramLock.Lock()
 	defer tds.ramLock.Unlock()
-	_, found := tds.ram[id]
-	if found {
+
+	item, found := tds.ram[id]
+	if !found || item.OwnerID != owner {
+		return ErrNoItem
+	}
+
+	for renumbered := false; ; renumbered = true {
+		pivot, found := tds.ram[anchor]
+		if !found || pivot.OwnerID != owner {
+			return ErrNoItem
+		}
+
+		var neighbour *float64
+		for _, other := range tds.ram {
+			if other.OwnerID != owner || other.ID == id {
+				continue
+			}
+			closer := neighbour == nil || (before && other.Position > *neighbour) || (!before && other.Position < *neighbour)
+			beside := (before && other.Position < pivot.Position) || (!before && other.Position > pivot.Position)
+			if beside && closer {
+				position := other.Position
+				neighbour = &position
+			}
+		}
+
+		position, ok := between(pivot.Position, neighbour, before)
+		if ok || renumbered {
+			item.Position = position
+			tds.ram[id] = item
+			return nil
+		}
+
+		tds.renumber(owner)
+	}
+}
+
+// renumber spreads the positions of the owner's items back to 1, 2, 3...
+func (tds *InMemoryStorage) renumber(owner int) {
+	var items []*known.TodoItem
+	for _, item := range tds.ram {
+		if item.OwnerID == owner {
+			item := item
+			items = append(items, &item)
+		}
+	}
+
+	sort.Slice(items, func(i, j int) bool { return less(items[i], items[j], known.SortByPosition) })
+	for i, item := range items {
+		item.Position = float64(i + 1)
+		tds.ram[item.ID] = *item
+	}
+}
+
+func (tds *InMemoryStorage) Delete(owner, id int, cascade bool, version int) error {
+	tds.ramLock.Lock()
+	defer tds.ramLock.Unlock()
+	existing, found := tds.ram[id]
+	if !found || existing.OwnerID != owner {
+		return ErrNoItem
+	}
+	if version != 0 && version != existing.Version {
+		return ErrStaleVersion
+	}
+
+	subtree := tds.subtree(id)
+	if len(subtree) > 1 && !cascade {
+		return ErrHasSubtasks
+	}
+
+	deleted := time.Now().UTC().Truncate(time.Microsecond)
+	for _, id := range subtree {
+		item := tds.ram[id]
+		item.Deleted = &deleted
+		tds.trash[id] = item
 		delete(tds.ram, id)
+		if err := tds.addRevision(owner, id, known.RevisionDelete, nil, nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (tds *InMemoryStorage) Restore(owner, id int) error {
+	tds.ramLock.Lock()
+	defer tds.ramLock.Unlock()
+
+	item, found := tds.trash[id]
+	if !found || item.OwnerID != owner {
+		return ErrNoItem
+	}
+	if _, live := tds.ram[item.ParentID]; item.ParentID != 0 && !live {
+		return ErrParentTrashed
+	}
+
+	// subtasks trashed on their own before the item stay in the trash
+	for _, id := range tds.trashedSubtree(id) {
+		trashed := tds.trash[id]
+		if trashed.Deleted.Equal(*item.Deleted) {
+			trashed.Deleted = nil
+			tds.ram[id] = trashed
+			delete(tds.trash, id)
+			if err := tds.addRevision(owner, id, known.RevisionRestore, nil, nil); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (tds *InMemoryStorage) Purge(owner, id int) error {
+	tds.ramLock.Lock()
+	defer tds.ramLock.Unlock()
+
+	if item, found := tds.trash[id]; !found || item.OwnerID != owner {
+		return ErrNoItem
+	}
+	tds.purge(id)
+
+	return nil
+}
+
+func (tds *InMemoryStorage) ArchiveDone(before time.Time) (int, error) {
+	tds.ramLock.Lock()
+	defer tds.ramLock.Unlock()
+
+	archived := time.Now().UTC().Truncate(time.Microsecond)
+	count := 0
+	for id, item := range tds.ram {
+		if item.Archived == nil && item.Completed != nil && item.Completed.Before(before) {
+			item.Archived = &archived
+			tds.ram[id] = item
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+func (tds *InMemoryStorage) Unarchive(owner, id int) error {
+	tds.ramLock.Lock()
+	defer tds.ramLock.Unlock()
+
+	item, found := tds.ram[id]
+	if !found || item.OwnerID != owner || item.Archived == nil {
+		return ErrNoItem
+	}
+	item.Archived = nil
+	tds.ram[id] = item
+
+	return nil
+}
+
+func (tds *InMemoryStorage) PurgeTrash(before time.Time) (int, error) {
+	tds.ramLock.Lock()
+	defer tds.ramLock.Unlock()
+
+	purged := 0
+	for id, item := range tds.trash {
+		if !item.Deleted.Before(before) {
+			continue
+		}
+		// subtasks of an expired parent go along with it uncounted
+		if parent, found := tds.trash[item.ParentID]; found && parent.Deleted.Before(before) {
+			continue
+		}
+		tds.purge(id)
+		purged++
+	}
+
+	return purged, nil
+}
+
+// purge drops a trashed item and its subtasks along with everything referring to them.
+func (tds *InMemoryStorage) purge(id int) {
+	for _, id := range tds.trashedSubtree(id) {
+		delete(tds.trash, id)
+		delete(tds.todoTags, id)
+		delete(tds.revisions, id)
+		delete(tds.blockers, id)
+		for _, blockers := range tds.blockers {
+			delete(blockers, id)
+		}
+		for reminder, r := range tds.reminders {
+			if r.TodoID == id {
+				delete(tds.reminders, reminder)
+			}
+		}
+	}
+}
+
+// trashedSubtree lists the trashed item id followed by all of its subtasks, which are all in the trash.
+func (tds *InMemoryStorage) trashedSubtree(id int) []int {
+	ids := []int{id}
+	for i := 0; i < len(ids); i++ {
+		for _, item := range tds.trash {
+			if item.ParentID == ids[i] {
+				ids = append(ids, item.ID)
+			}
+		}
+	}
+
+	return ids
+}
+
+func (tds *InMemoryStorage) SetParent(owner, id, parent int) error {
+	tds.ramLock.Lock()
+	defer tds.ramLock.Unlock()
+
+	item, found := tds.ram[id]
+	if !found || item.OwnerID != owner {
+		return ErrNoItem
+	}
+
+	if parent != 0 {
+		target, found := tds.ram[parent]
+		if !found || target.OwnerID != owner {
+			return ErrNoItem
+		}
+
+		subtree := tds.subtree(id)
+		if slices.Contains(subtree, parent) {
+			return ErrSubtaskCycle
+		}
+		if tds.depth(parent)+tds.height(id) > MaxDepth {
+			return ErrTooDeep
+		}
+
+		for _, id := range subtree {
+			moved := tds.ram[id]
+			moved.ListID = target.ListID
+			tds.ram[id] = moved
+		}
+		item = tds.ram[id]
+	}
+
+	item.ParentID = parent
+	tds.ram[id] = item
+
+	return nil
+}
+
+func (tds *InMemoryStorage) CompleteSubtasks(owner, id int) error {
+	tds.ramLock.Lock()
+	defer tds.ramLock.Unlock()
+
+	item, found := tds.ram[id]
+	if !found || item.OwnerID != owner {
+		return ErrNoItem
+	}
+
+	for _, id := range tds.subtree(id)[1:] {
+		subtask := tds.ram[id]
+		if subtask.Done {
+			continue
+		}
+		before := subtask
+		subtask.Done = true
+		subtask.Version++
+		if subtask.Completed == nil {
+			subtask.Completed = item.Completed
+		}
+		tds.ram[id] = subtask
+		if err := tds.addRevision(owner, id, known.RevisionUpdate, &before, &subtask); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (tds *InMemoryStorage) GetRevisions(owner, id int) ([]*known.Revision, error) {
+	tds.ramLock.Lock()
+	defer tds.ramLock.Unlock()
+
+	item, found := tds.ram[id]
+	if !found {
+		item, found = tds.trash[id]
+	}
+	if !found || item.OwnerID != owner {
+		return nil, ErrNoItem
+	}
+
+	revisions := []*known.Revision{}
+	for _, revision := range tds.revisions[id] {
+		revision := revision
+		revisions = append(revisions, &revision)
+	}
+
+	return revisions, nil
+}
+
+// addRevision records the change of the item id from before to after, made by actor; before is nil
+// for a created item and both are nil for a deletion or a restore. An update changing nothing is left out.
+func (tds *InMemoryStorage) addRevision(actor, id int, action string, before, after *known.TodoItem) error {
+	revision := known.Revision{TodoID: id, ActorID: actor, Action: action, Created: time.Now().UTC().Truncate(time.Microsecond)}
+	if after != nil {
+		changes, err := known.Diff(before, after)
+		if err != nil {
+			return err
+		}
+		if len(changes) == 0 && action == known.RevisionUpdate {
+			return nil
+		}
+		revision.Changes = changes
+	}
+
+	tds.revisionIndex++
+	revision.ID = tds.revisionIndex
+	tds.revisions[id] = append(tds.revisions[id], revision)
+
+	return nil
+}
+
+// subtree lists the item id followed by all of its subtasks, at any depth.
+func (tds *InMemoryStorage) subtree(id int) []int {
+	ids := []int{id}
+	for i := 0; i < len(ids); i++ {
+		for _, item := range tds.ram {
+			if item.ParentID == ids[i] {
+				ids = append(ids, item.ID)
+			}
+		}
+	}
+
+	return ids
+}
+
+// depth counts the levels from the top level item down to the item id, both included.
+func (tds *InMemoryStorage) depth(id int) int {
+	depth := 0
+	for ; id != 0; id = tds.ram[id].ParentID {
+		depth++
+	}
+
+	return depth
+}
+
+// height counts the levels from the item id down to its deepest subtask, both included.
+func (tds *InMemoryStorage) height(id int) int {
+	height := 0
+	for _, item := range tds.ram {
+		if item.ParentID == id {
+			height = max(height, tds.height(item.ID))
+		}
+	}
+
+	return height + 1
+}
+
+// progress counts the done direct subtasks and checklist entries of the item.
+func (tds *InMemoryStorage) progress(item *known.TodoItem) *known.Progress {
+	var progress known.Progress
+	for _, other := range tds.ram {
+		if other.ParentID == item.ID {
+			progress.Total++
+			if other.Done {
+				progress.Done++
+			}
+		}
+	}
+	for _, entry := range item.Checklist {
+		progress.Total++
+		if entry.Done {
+			progress.Done++
+		}
+	}
+
+	if progress.Total == 0 {
 		return nil
 	}
 
-	return errNoItem
+	return &progress
+}
+
+// tagNames lists the names of the item's tags in alphabetical order, nil without tags.
+func (tds *InMemoryStorage) tagNames(id int) []string {
+	var names []string
+	for tagID := range tds.todoTags[id] {
+		names = append(names, tds.tags[tagID].Name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// linkTags attaches the named tags of owner to an item, creating the missing ones.
+func (tds *InMemoryStorage) linkTags(owner, id int, names []string) {
+	for _, name := range names {
+		tag, found := tds.tagByName(owner, name)
+		if !found {
+			tds.tagIndex++
+			tag = known.Tag{ID: tds.tagIndex, OwnerID: owner, Name: name}
+			tds.tags[tag.ID] = tag
+		}
+
+		if tds.todoTags[id] == nil {
+			tds.todoTags[id] = map[int]bool{}
+		}
+		tds.todoTags[id][tag.ID] = true
+	}
+}
+
+func (tds *InMemoryStorage) tagByName(owner int, name string) (known.Tag, bool) {
+	for _, tag := range tds.tags {
+		if tag.OwnerID == owner && tag.Name == name {
+			return tag, true
+		}
+	}
+
+	return known.Tag{}, false
+}
+
+func (tds *InMemoryStorage) CreateTag(tag *known.Tag) error {
+	tds.ramLock.Lock()
+	defer tds.ramLock.Unlock()
+
+	if _, taken := tds.tagByName(tag.OwnerID, tag.Name); taken {
+		return ErrTagTaken
+	}
+
+	tds.tagIndex++
+	tag.ID = tds.tagIndex
+	tds.tags[tag.ID] = *tag
+
+	return nil
+}
+
+func (tds *InMemoryStorage) GetTags(owner int) ([]*known.Tag, error) {
+	tds.ramLock.Lock()
+	defer tds.ramLock.Unlock()
+
+	tags := []*known.Tag{}
+	for _, tag := range tds.tags {
+		if tag.OwnerID != owner {
+			continue
+		}
+
+		tag := tag
+		for id, linked := range tds.todoTags {
+			if _, live := tds.ram[id]; live && linked[tag.ID] {
+				tag.Items++
+			}
+		}
+		tags = append(tags, &tag)
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Name < tags[j].Name })
+
+	return tags, nil
+}
+
+func (tds *InMemoryStorage) RenameTag(owner, id int, name string) error {
+	tds.ramLock.Lock()
+	defer tds.ramLock.Unlock()
+
+	tag, found := tds.tags[id]
+	if !found || tag.OwnerID != owner {
+		return ErrNoTag
+	}
+	if other, taken := tds.tagByName(owner, name); taken && other.ID != id {
+		return ErrTagTaken
+	}
+
+	tag.Name = name
+	tds.tags[id] = tag
+
+	return nil
+}
+
+func (tds *InMemoryStorage) MergeTags(owner, from, into int) error {
+	tds.ramLock.Lock()
+	defer tds.ramLock.Unlock()
+
+	source, found := tds.tags[from]
+	target, foundTarget := tds.tags[into]
+	if !found || !foundTarget || source.OwnerID != owner || target.OwnerID != owner {
+		return ErrNoTag
+	}
+
+	for _, linked := range tds.todoTags {
+		if linked[from] {
+			delete(linked, from)
+			linked[into] = true
+		}
+	}
+	delete(tds.tags, from)
+
+	return nil
+}
+
+func (tds *InMemoryStorage) DeleteTag(owner, id int) error {
+	tds.ramLock.Lock()
+	defer tds.ramLock.Unlock()
+
+	tag, found := tds.tags[id]
+	if !found || tag.OwnerID != owner {
+		return ErrNoTag
+	}
+
+	for _, linked := range tds.todoTags {
+		delete(linked, id)
+	}
+	delete(tds.tags, id)
+
+	return nil
+}
+
+func (tds *InMemoryStorage) listByName(owner int, name string) (known.List, bool) {
+	for _, list := range tds.lists {
+		if list.OwnerID == owner && list.Name == name {
+			return list, true
+		}
+	}
+
+	return known.List{}, false
+}
+
+// countItems fills in the open and done counts of the list.
+func (tds *InMemoryStorage) countItems(list *known.List) {
+	for _, item := range tds.ram {
+		if item.ListID != list.ID {
+			continue
+		}
+		if item.Done {
+			list.Done++
+		} else {
+			list.Open++
+		}
+	}
+}
+
+func (tds *InMemoryStorage) CreateList(list *known.List) error {
+	tds.ramLock.Lock()
+	defer tds.ramLock.Unlock()
+
+	if _, taken := tds.listByName(list.OwnerID, list.Name); taken {
+		return ErrListTaken
+	}
+
+	tds.listIndex++
+	list.ID = tds.listIndex
+	tds.lists[list.ID] = *list
+
+	return nil
+}
+
+func (tds *InMemoryStorage) GetList(owner, id int) (*known.List, error) {
+	tds.ramLock.Lock()
+	defer tds.ramLock.Unlock()
+
+	list, found := tds.lists[id]
+	if !found || list.OwnerID != owner {
+		return nil, ErrNoList
+	}
+	tds.countItems(&list)
+
+	return &list, nil
+}
+
+func (tds *InMemoryStorage) GetLists(owner int, archived bool) ([]*known.List, error) {
+	tds.ramLock.Lock()
+	defer tds.ramLock.Unlock()
+
+	lists := []*known.List{}
+	for _, list := range tds.lists {
+		if list.OwnerID != owner || list.Archived && !archived {
+			continue
+		}
+
+		list := list
+		tds.countItems(&list)
+		lists = append(lists, &list)
+	}
+	sort.Slice(lists, func(i, j int) bool { return lists[i].ID < lists[j].ID })
+
+	return lists, nil
+}
+
+func (tds *InMemoryStorage) RenameList(owner, id int, name string) error {
+	tds.ramLock.Lock()
+	defer tds.ramLock.Unlock()
+
+	list, found := tds.lists[id]
+	if !found || list.OwnerID != owner {
+		return ErrNoList
+	}
+	if other, taken := tds.listByName(owner, name); taken && other.ID != id {
+		return ErrListTaken
+	}
+
+	list.Name = name
+	tds.lists[id] = list
+
+	return nil
+}
+
+func (tds *InMemoryStorage) ArchiveList(owner, id int, archived bool) error {
+	tds.ramLock.Lock()
+	defer tds.ramLock.Unlock()
+
+	list, found := tds.lists[id]
+	if !found || list.OwnerID != owner {
+		return ErrNoList
+	}
+
+	list.Archived = archived
+	tds.lists[id] = list
+
+	return nil
+}
+
+func (tds *InMemoryStorage) DeleteList(owner, id int) error {
+	tds.ramLock.Lock()
+	defer tds.ramLock.Unlock()
+
+	list, found := tds.lists[id]
+	if !found || list.OwnerID != owner {
+		return ErrNoList
+	}
+
+	// trashed items are restored into the inbox too
+	for _, items := range []map[int]known.TodoItem{tds.ram, tds.trash} {
+		for itemID, item := range items {
+			if item.ListID == id {
+				item.ListID = 0
+				items[itemID] = item
+			}
+		}
+	}
+	delete(tds.lists, id)
+
+	return nil
+}
+
+func (tds *InMemoryStorage) MoveToList(owner, id, list int) error {
+	tds.ramLock.Lock()
+	defer tds.ramLock.Unlock()
+
+	if target, found := tds.lists[list]; list != 0 && (!found || target.OwnerID != owner) {
+		return ErrNoList
+	}
+
+	item, found := tds.ram[id]
+	if !found || item.OwnerID != owner {
+		return ErrNoItem
+	}
+	if item.ParentID != 0 {
+		return ErrSubtaskList
+	}
+
+	for _, id := range tds.subtree(id) {
+		moved := tds.ram[id]
+		moved.ListID = list
+		tds.ram[id] = moved
+	}
+
+	return nil
+}
+
+func (tds *InMemoryStorage) AddBlocker(owner, id, blocker int) error {
+	tds.ramLock.Lock()
+	defer tds.ramLock.Unlock()
+
+	for _, id := range []int{id, blocker} {
+		if item, found := tds.ram[id]; !found || item.OwnerID != owner {
+			return ErrNoItem
+		}
+	}
+
+	// walk up from the blocker, reaching the item would close a cycle
+	seen := map[int]bool{blocker: true}
+	for queue := []int{blocker}; len(queue) > 0; queue = queue[1:] {
+		if queue[0] == id {
+			return ErrBlockerCycle
+		}
+		for upstream := range tds.blockers[queue[0]] {
+			if !seen[upstream] {
+				seen[upstream] = true
+				queue = append(queue, upstream)
+			}
+		}
+	}
+
+	if tds.blockers[id] == nil {
+		tds.blockers[id] = map[int]bool{}
+	}
+	tds.blockers[id][blocker] = true
+
+	return nil
+}
+
+func (tds *InMemoryStorage) RemoveBlocker(owner, id, blocker int) error {
+	tds.ramLock.Lock()
+	defer tds.ramLock.Unlock()
+
+	if item, found := tds.ram[id]; !found || item.OwnerID != owner {
+		return ErrNoItem
+	}
+	if !tds.blockers[id][blocker] {
+		return ErrNoBlocker
+	}
+
+	delete(tds.blockers[id], blocker)
+
+	return nil
+}
+
+func (tds *InMemoryStorage) GetBlockers(owner, id int) ([]*known.TodoItem, error) {
+	tds.ramLock.Lock()
+	defer tds.ramLock.Unlock()
+
+	if item, found := tds.ram[id]; !found || item.OwnerID != owner {
+		return nil, ErrNoItem
+	}
+
+	items := []*known.TodoItem{}
+	for blocker := range tds.blockers[id] {
+		item, live := tds.ram[blocker]
+		if !live {
+			continue
+		}
+		item.Tags = tds.tagNames(item.ID)
+		item.Progress = tds.progress(&item)
+		items = append(items, &item)
+	}
+	sort.Slice(items, func(i, j int) bool { return less(items[i], items[j], known.SortByPosition) })
+
+	return items, nil
+}
+
+func (tds *InMemoryStorage) GetDependencies(owner int) ([]known.Dependency, error) {
+	tds.ramLock.Lock()
+	defer tds.ramLock.Unlock()
+
+	edges := []known.Dependency{}
+	for id, blockers := range tds.blockers {
+		item := tds.ram[id]
+		if item.OwnerID != owner || item.Done {
+			continue
+		}
+		for blocker := range blockers {
+			if item, live := tds.ram[blocker]; live && !item.Done {
+				edges = append(edges, known.Dependency{ItemID: id, BlockerID: blocker})
+			}
+		}
+	}
+
+	return edges, nil
+}
+
+func (tds *InMemoryStorage) CreateReminder(reminder *known.Reminder) error {
+	tds.ramLock.Lock()
+	defer tds.ramLock.Unlock()
+
+	item, found := tds.ram[reminder.TodoID]
+	if !found || item.OwnerID != reminder.OwnerID {
+		return ErrNoItem
+	}
+
+	reminder.Resolve(item.Due)
+	tds.reminderIndex++
+	reminder.ID = tds.reminderIndex
+	tds.reminders[reminder.ID] = *reminder
+
+	return nil
+}
+
+func (tds *InMemoryStorage) GetReminders(owner, id int) ([]*known.Reminder, error) {
+	tds.ramLock.Lock()
+	defer tds.ramLock.Unlock()
+
+	if item, found := tds.ram[id]; !found || item.OwnerID != owner {
+		return nil, ErrNoItem
+	}
+
+	reminders := []*known.Reminder{}
+	for _, reminder := range tds.reminders {
+		if reminder.TodoID == id {
+			reminder := reminder
+			reminders = append(reminders, &reminder)
+		}
+	}
+	sortReminders(reminders)
+
+	return reminders, nil
+}
+
+// sortReminders orders by the firing time like the SQL backends do, unresolved ones last.
+func sortReminders(reminders []*known.Reminder) {
+	sort.Slice(reminders, func(i, j int) bool {
+		a, b := reminders[i], reminders[j]
+		if (a.FireAt == nil) != (b.FireAt == nil) {
+			return b.FireAt == nil
+		}
+		if a.FireAt != nil && !a.FireAt.Equal(*b.FireAt) {
+			return a.FireAt.Before(*b.FireAt)
+		}
+		return a.ID < b.ID
+	})
+}
+
+func (tds *InMemoryStorage) DeleteReminder(owner, id, reminder int) error {
+	tds.ramLock.Lock()
+	defer tds.ramLock.Unlock()
+
+	existing, found := tds.reminders[reminder]
+	if !found || existing.TodoID != id || tds.ram[id].OwnerID != owner {
+		return ErrNoReminder
+	}
+	delete(tds.reminders, reminder)
+
+	return nil
+}
+
+func (tds *InMemoryStorage) DueReminders(now, lapsed time.Time, maxAttempts, limit int) ([]*known.Reminder, error) {
+	tds.ramLock.Lock()
+	defer tds.ramLock.Unlock()
+
+	reminders := []*known.Reminder{}
+	for _, reminder := range tds.reminders {
+		item, live := tds.ram[reminder.TodoID]
+		if reminder.SentAt != nil || reminder.FireAt == nil || reminder.FireAt.After(now) ||
+			reminder.Attempts >= maxAttempts || !live || item.Done || claimedSince(&reminder, lapsed) {
+			continue
+		}
+		reminder := reminder
+		reminders = append(reminders, &reminder)
+	}
+	sortReminders(reminders)
+	if len(reminders) > limit {
+		reminders = reminders[:limit]
+	}
+
+	return reminders, nil
+}
+
+func (tds *InMemoryStorage) ClaimReminder(id int, at, lapsed time.Time) (bool, error) {
+	tds.ramLock.Lock()
+	defer tds.ramLock.Unlock()
+
+	reminder, found := tds.reminders[id]
+	if !found || reminder.SentAt != nil || claimedSince(&reminder, lapsed) {
+		return false, nil
+	}
+	reminder.ClaimedAt = &at
+	tds.reminders[id] = reminder
+
+	return true, nil
+}
+
+// claimedSince tells whether a server took the reminder up at lapsed or later.
+func claimedSince(reminder *known.Reminder, lapsed time.Time) bool {
+	return reminder.ClaimedAt != nil && !reminder.ClaimedAt.Before(lapsed)
+}
+
+func (tds *InMemoryStorage) SentReminder(id int, at time.Time) error {
+	tds.ramLock.Lock()
+	defer tds.ramLock.Unlock()
+
+	reminder, found := tds.reminders[id]
+	if !found {
+		return ErrNoReminder
+	}
+	reminder.SentAt, reminder.ClaimedAt = &at, nil
+	tds.reminders[id] = reminder
+
+	return nil
+}
+
+func (tds *InMemoryStorage) ReleaseReminder(id int) error {
+	tds.ramLock.Lock()
+	defer tds.ramLock.Unlock()
+
+	reminder, found := tds.reminders[id]
+	if !found {
+		return ErrNoReminder
+	}
+	reminder.ClaimedAt = nil
+	reminder.Attempts++
+	tds.reminders[id] = reminder
+
+	return nil
+}
+
+// Lead always succeeds, the memory is not shared with other servers.
+func (tds *InMemoryStorage) Lead(ctx context.Context) (func(), bool, error) {
+	return func() {}, true, nil
+}
+
+func (tds *InMemoryStorage) CreateUser(user *known.User) error {
+	tds.ramLock.Lock()
+	defer tds.ramLock.Unlock()
+
+	for _, existing := range tds.users {
+		if existing.Username == user.Username {
+			return ErrUsernameTaken
+		}
+	}
+
+	tds.userIndex++
+	user.ID = tds.userIndex
+	tds.users[user.ID] = *user
+
+	return nil
+}
+
+func (tds *InMemoryStorage) GetUser(id int) (*known.User, error) {
+	tds.ramLock.Lock()
+	defer tds.ramLock.Unlock()
+
+	result, found := tds.users[id]
+	if found {
+		return &result, nil
+	}
+
+	return nil, ErrNoUser
+}
+
+func (tds *InMemoryStorage) GetUserByUsername(username string) (*known.User, error) {
+	tds.ramLock.Lock()
+	defer tds.ramLock.Unlock()
+
+	for _, user := range tds.users {
+		if user.Username == username {
+			return &user, nil
+		}
+	}
+
+	return nil, ErrNoUser
+}
+
+func (tds *InMemoryStorage) UpdateUser(user *known.User) error {
+	tds.ramLock.Lock()
+	defer tds.ramLock.Unlock()
+
+	existing, found := tds.users[user.ID]
+	if !found {
+		return ErrNoUser
+	}
+
+	existing.Name = user.Name
+	existing.TimeZone = user.TimeZone
+	existing.Email = user.Email
+	tds.users[user.ID] = existing
+
+	return nil
+}
+
+func (tds *InMemoryStorage) SaveRefreshToken(token *known.RefreshToken) error {
+	tds.ramLock.Lock()
+	defer tds.ramLock.Unlock()
+
+	tds.refresh[token.Hash] = *token
+
+	return nil
+}
+
+func (tds *InMemoryStorage) GetRefreshToken(hash string) (*known.RefreshToken, error) {
+	tds.ramLock.Lock()
+	defer tds.ramLock.Unlock()
+
+	result, found := tds.refresh[hash]
+	if found {
+		return &result, nil
+	}
+
+	return nil, ErrNoToken
+}
+
+func (tds *InMemoryStorage) RevokeRefreshToken(hash string) (bool, error) {
+	tds.ramLock.Lock()
+	defer tds.ramLock.Unlock()
+
+	token, found := tds.refresh[hash]
+	if !found || token.Revoked {
+		return false, nil
+	}
+
+	token.Revoked = true
+	tds.refresh[hash] = token
+
+	return true, nil
+}
+
+func (tds *InMemoryStorage) RevokeTokenFamily(family string) error {
+	tds.ramLock.Lock()
+	defer tds.ramLock.Unlock()
+
+	for hash, token := range tds.refresh {
+		if token.Family == family {
+			token.Revoked = true
+			tds.refresh[hash] = token
+		}
+	}
+
+	return nil
+}
+
+func (tds *InMemoryStorage) RevokeAccessToken(id string, expiresAt time.Time) error {
+	tds.ramLock.Lock()
+	defer tds.ramLock.Unlock()
+
+	now := time.Now()
+	for revokedID, until := range tds.revoked {
+		if until.Before(now) {
+			delete(tds.revoked, revokedID)
+		}
+	}
+	tds.revoked[id] = expiresAt
+
+	return nil
+}
+
+func (tds *InMemoryStorage) IsAccessTokenRevoked(id string) (bool, error) {
+	tds.ramLock.Lock()
+	defer tds.ramLock.Unlock()
+
+	_, found := tds.revoked[id]
+
+	return found, nil
+}
+
+// idempotencyKey is a key of an owner, the keys of different owners are unrelated.
+type idempotencyKey struct {
+	owner int
+	key   string
+}
+
+func (tds *InMemoryStorage) ClaimIdempotencyKey(request *known.IdempotentRequest, now time.Time) (*known.IdempotentRequest, error) {
+	tds.ramLock.Lock()
+	defer tds.ramLock.Unlock()
+
+	for key, kept := range tds.idempotent {
+		if !kept.ExpiresAt.After(now) {
+			delete(tds.idempotent, key)
+		}
+	}
+
+	key := idempotencyKey{request.OwnerID, request.Key}
+	if kept, found := tds.idempotent[key]; found {
+		return &kept, nil
+	}
+	tds.idempotent[key] = *request
+
+	return nil, nil
+}
+
+func (tds *InMemoryStorage) SaveIdempotentResponse(request *known.IdempotentRequest) error {
+	tds.ramLock.Lock()
+	defer tds.ramLock.Unlock()
+
+	key := idempotencyKey{request.OwnerID, request.Key}
+	kept, found := tds.idempotent[key]
+	if !found {
+		return nil
+	}
+	kept.Status = request.Status
+	kept.Header = maps.Clone(request.Header)
+	kept.Body = slices.Clone(request.Body)
+	tds.idempotent[key] = kept
+
+	return nil
+}
+
+func (tds *InMemoryStorage) ReleaseIdempotencyKey(owner int, key string) error {
+	tds.ramLock.Lock()
+	defer tds.ramLock.Unlock()
+
+	delete(tds.idempotent, idempotencyKey{owner, key})
+
+	return nil
 }
@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrations_Embedded(t *testing.T) {
+	postgres, err := loadMigrations(dialectPostgres)
+	require.NoError(t, err)
+	require.NotEmpty(t, postgres)
+
+	sqlite, err := loadMigrations(dialectSQLite)
+	require.NoError(t, err)
+	require.Len(t, sqlite, len(postgres), "every schema change must land in both dialects")
+
+	for i, m := range postgres {
+		assert.Equal(t, i+1, m.Version, "versions must be contiguous")
+		assert.Equal(t, m.Name, sqlite[i].Name)
+		assert.NotEmpty(t, m.Up)
+		assert.NotEmpty(t, m.Down)
+	}
+}
+
+func TestMigrator_UpAppliesPending(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	migrator, err := NewMigrator(db, dialectPostgres, logger)
+	require.NoError(t, err)
+	migrator.migrations = []Migration{
+		{Version: 1, Name: "first", Up: "create table a (id int)", Down: "drop table a"},
+		{Version: 2, Name: "second", Up: "create table b (id int)", Down: "drop table b"},
+	}
+
+	mock.ExpectExec(regexp.QuoteMeta("select pg_advisory_lock($1)")).WithArgs(migrationLockKey).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("create table if not exists schema_migrations").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("select version, applied_at from schema_migrations").
+		WillReturnRows(sqlmock.NewRows([]string{"version", "applied_at"}).AddRow(1, time.Now()))
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("create table b (id int)")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("insert into schema_migrations (version, name) values ($1, $2)")).
+		WithArgs(2, "second").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectExec(regexp.QuoteMeta("select pg_advisory_unlock($1)")).WithArgs(migrationLockKey).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	count, err := migrator.Up(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigrator_DownRollsBackFailedStep(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	migrator, err := NewMigrator(db, dialectPostgres, logger)
+	require.NoError(t, err)
+	migrator.migrations = []Migration{{Version: 1, Name: "first", Up: "create table a (id int)", Down: "drop table a"}}
+
+	mock.ExpectExec(regexp.QuoteMeta("select pg_advisory_lock($1)")).WithArgs(migrationLockKey).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("create table if not exists schema_migrations").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("select version, applied_at from schema_migrations").
+		WillReturnRows(sqlmock.NewRows([]string{"version", "applied_at"}).AddRow(1, time.Now()))
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("drop table a")).WillReturnError(context.DeadlineExceeded)
+	mock.ExpectRollback()
+	mock.ExpectExec(regexp.QuoteMeta("select pg_advisory_unlock($1)")).WithArgs(migrationLockKey).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	count, err := migrator.Down(context.Background(), 1)
+	assert.EqualError(t, err, "migration 1_first down: context deadline exceeded")
+	assert.Equal(t, 0, count)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,4 @@
+drop table if exists revoked_tokens;
+drop table if exists refresh_tokens;
+drop table if exists todos;
+drop table if exists users;
@@ -0,0 +1,29 @@
+-- Tables may predate the migrations, hence "if not exists" everywhere.
+create table if not exists users (
+	id serial primary key,
+	name varchar(100),
+	username varchar(50) not null unique,
+	password_hash varchar(100) not null
+);
+
+create table if not exists todos (
+	id serial primary key,
+	title varchar(100),
+	description varchar(100),
+	done boolean
+);
+
+alter table todos add column if not exists owner_id integer references users(id);
+
+create table if not exists refresh_tokens (
+	token_hash varchar(64) primary key,
+	user_id integer not null references users(id),
+	family varchar(64) not null,
+	expires_at timestamptz not null,
+	revoked_at timestamptz
+);
+
+create table if not exists revoked_tokens (
+	token_id varchar(64) primary key,
+	expires_at timestamptz not null
+);
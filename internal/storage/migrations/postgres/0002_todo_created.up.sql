@@ -0,0 +1 @@
+alter table todos add column created_at timestamptz not null default now();
@@ -0,0 +1,5 @@
+drop index todos_owner_due_idx;
+
+alter table users drop column time_zone;
+alter table todos drop column start_at;
+alter table todos drop column due_at;
@@ -0,0 +1,5 @@
+alter table todos add column due_at timestamptz;
+alter table todos add column start_at timestamptz;
+alter table users add column time_zone varchar(64) not null default 'UTC';
+
+create index todos_owner_due_idx on todos (owner_id, due_at);
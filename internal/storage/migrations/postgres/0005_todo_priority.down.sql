@@ -0,0 +1,4 @@
+drop index todos_owner_position_idx;
+
+alter table todos drop column position;
+alter table todos drop column priority;
@@ -0,0 +1,2 @@
+drop table todo_tags;
+drop table tags;
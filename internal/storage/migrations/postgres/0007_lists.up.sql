@@ -0,0 +1,12 @@
+create table lists (
+	id serial primary key,
+	owner_id integer not null references users(id) on delete cascade,
+	name varchar(100) not null,
+	archived boolean not null default false,
+	unique (owner_id, name)
+);
+
+-- deleting a list moves its items back to the inbox
+alter table todos add column list_id integer references lists(id) on delete set null;
+
+create index todos_list_idx on todos (list_id);
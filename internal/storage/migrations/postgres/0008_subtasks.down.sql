@@ -0,0 +1,4 @@
+drop index todos_parent_idx;
+
+alter table todos drop column checklist;
+alter table todos drop column parent_id;
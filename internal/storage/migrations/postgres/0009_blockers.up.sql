@@ -0,0 +1,8 @@
+-- todo_id is blocked by blocker_id, both items belong to the same owner
+create table todo_blockers (
+	todo_id integer not null references todos(id) on delete cascade,
+	blocker_id integer not null references todos(id) on delete cascade,
+	primary key (todo_id, blocker_id)
+);
+
+create index todo_blockers_blocker_idx on todo_blockers (blocker_id);
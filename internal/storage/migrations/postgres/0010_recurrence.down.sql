@@ -0,0 +1,4 @@
+drop index todos_series_idx;
+
+alter table todos drop column series_id;
+alter table todos drop column recurrence;
@@ -0,0 +1,7 @@
+-- a rule in a subset of the RFC 5545 RRULE syntax, null for items that do not repeat
+alter table todos add column recurrence text;
+-- the first item of the series an occurrence belongs to; not a foreign key,
+-- the series outlives its first item
+alter table todos add column series_id integer;
+
+create index todos_series_idx on todos (series_id);
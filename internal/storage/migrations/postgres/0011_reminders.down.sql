@@ -0,0 +1,3 @@
+alter table users drop column email;
+
+drop table reminders;
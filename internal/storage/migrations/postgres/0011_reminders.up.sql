@@ -0,0 +1,17 @@
+-- a reminder goes off at remind_at, or minutes_before the due date of its item;
+-- fire_at is the resolved time, null for an offset from an item without a due date
+create table reminders (
+	id serial primary key,
+	todo_id integer not null references todos(id) on delete cascade,
+	remind_at timestamptz,
+	minutes_before integer,
+	fire_at timestamptz,
+	-- set when the reminder is claimed for delivery, so it fires once across restarts
+	sent_at timestamptz,
+	attempts integer not null default 0
+);
+
+create index reminders_todo_idx on reminders (todo_id);
+create index reminders_pending_idx on reminders (fire_at) where sent_at is null;
+
+alter table users add column email varchar(254);
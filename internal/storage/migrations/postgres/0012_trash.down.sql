@@ -0,0 +1,3 @@
+drop index todos_deleted_idx;
+
+alter table todos drop column deleted_at;
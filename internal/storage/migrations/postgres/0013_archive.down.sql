@@ -0,0 +1,6 @@
+drop index todos_archivable_idx;
+drop index todos_archive_idx;
+drop index todos_active_idx;
+
+alter table todos drop column archived_at;
+alter table todos drop column completed_at;
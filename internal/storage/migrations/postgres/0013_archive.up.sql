@@ -0,0 +1,10 @@
+-- completed_at is when the item was last marked as done, archived_at when it left the active listings
+alter table todos add column completed_at timestamptz;
+alter table todos add column archived_at timestamptz;
+
+update todos set completed_at = current_timestamp where done;
+
+-- the active listings only read live items out of the archive
+create index todos_active_idx on todos (owner_id, position) where deleted_at is null and archived_at is null;
+create index todos_archive_idx on todos (owner_id, completed_at) where archived_at is not null;
+create index todos_archivable_idx on todos (completed_at) where archived_at is null and completed_at is not null;
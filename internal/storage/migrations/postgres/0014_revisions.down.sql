@@ -0,0 +1 @@
+drop table item_revisions;
@@ -0,0 +1,12 @@
+-- every change of an item, kept until the item is purged; changes is a JSON array
+-- of the fields the change replaced, with their values before and after it
+create table item_revisions (
+	id serial primary key,
+	todo_id integer not null references todos(id) on delete cascade,
+	actor_id integer not null references users(id),
+	action varchar(16) not null,
+	created_at timestamptz not null,
+	changes text
+);
+
+create index item_revisions_todo_idx on item_revisions (todo_id, id);
@@ -0,0 +1,14 @@
+-- requests made with an Idempotency-Key along with the responses replayed to their retries,
+-- kept until they expire; status is 0 while the request is being processed and headers is a JSON object
+create table idempotency_keys (
+	owner_id integer not null references users(id) on delete cascade,
+	idempotency_key varchar(255) not null,
+	fingerprint varchar(64) not null,
+	status integer not null default 0,
+	headers text,
+	body bytea,
+	expires_at timestamptz not null,
+	primary key (owner_id, idempotency_key)
+);
+
+create index idempotency_keys_expires_idx on idempotency_keys (expires_at);
@@ -0,0 +1 @@
+alter table reminders drop column claimed_at;
@@ -0,0 +1,3 @@
+-- set while a server delivers the reminder; sent_at is only set once the delivery succeeded,
+-- and a claim older than the lease of the scheduler is left by a server that stopped midway
+alter table reminders add column claimed_at timestamptz;
@@ -0,0 +1,27 @@
+create table if not exists users (
+	id integer primary key autoincrement,
+	name varchar(100),
+	username varchar(50) not null unique,
+	password_hash varchar(100) not null
+);
+
+create table if not exists todos (
+	id integer primary key autoincrement,
+	title varchar(100),
+	description varchar(100),
+	done boolean,
+	owner_id integer references users(id)
+);
+
+create table if not exists refresh_tokens (
+	token_hash varchar(64) primary key,
+	user_id integer not null references users(id),
+	family varchar(64) not null,
+	expires_at timestamp not null,
+	revoked_at timestamp
+);
+
+create table if not exists revoked_tokens (
+	token_id varchar(64) primary key,
+	expires_at timestamp not null
+);
@@ -0,0 +1 @@
+alter table todos drop column created_at;
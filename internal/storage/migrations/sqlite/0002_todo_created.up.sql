@@ -0,0 +1,3 @@
+-- SQLite cannot add a column with a non-constant default, rows get their time from the application.
+alter table todos add column created_at timestamp;
+update todos set created_at = current_timestamp;
@@ -0,0 +1 @@
+drop index todos_owner_done_idx;
@@ -0,0 +1 @@
+create index todos_owner_done_idx on todos (owner_id, done, id);
@@ -0,0 +1,7 @@
+alter table todos add column priority smallint not null default 0;
+alter table todos add column position real not null default 0;
+
+-- the manual order starts as the order of creation
+update todos set position = id;
+
+create index todos_owner_position_idx on todos (owner_id, position);
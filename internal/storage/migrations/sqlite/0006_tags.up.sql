@@ -0,0 +1,14 @@
+create table tags (
+	id integer primary key autoincrement,
+	owner_id integer not null references users(id) on delete cascade,
+	name varchar(50) not null,
+	unique (owner_id, name)
+);
+
+create table todo_tags (
+	todo_id integer not null references todos(id) on delete cascade,
+	tag_id integer not null references tags(id) on delete cascade,
+	primary key (todo_id, tag_id)
+);
+
+create index todo_tags_tag_idx on todo_tags (tag_id);
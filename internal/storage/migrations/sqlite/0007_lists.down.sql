@@ -0,0 +1,4 @@
+drop index todos_list_idx;
+
+alter table todos drop column list_id;
+drop table lists;
@@ -0,0 +1,5 @@
+alter table todos add column parent_id integer references todos(id) on delete cascade;
+-- a JSON array of {"text", "done"} entries, null without a checklist
+alter table todos add column checklist text;
+
+create index todos_parent_idx on todos (parent_id);
@@ -0,0 +1,4 @@
+-- set when the item is moved to the trash, null for a live item
+alter table todos add column deleted_at timestamp;
+
+create index todos_deleted_idx on todos (deleted_at) where deleted_at is not null;
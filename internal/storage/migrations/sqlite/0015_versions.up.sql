@@ -0,0 +1,2 @@
+-- counts the updates of an item, so concurrent edits of the same version are told apart
+alter table todos add column version integer not null default 1;
@@ -0,0 +1,16 @@
+package storage
+
+// between returns the position halfway from pivot to its neighbour on the side the item
+// moves to, or one step away from pivot at an end of the list. ok is false once the two
+// are too close for a float in between, then the list has to be renumbered first.
+func between(pivot float64, neighbour *float64, before bool) (position float64, ok bool) {
+	if neighbour == nil {
+		if before {
+			return pivot - 1, true
+		}
+		return pivot + 1, true
+	}
+
+	position = pivot + (*neighbour-pivot)/2
+	return position, position != pivot && position != *neighbour
+}
@@ -3,69 +3,52 @@ package storage
 import (
 	"context"
 	"database/sql"
-	"fmt"
+	"encoding/json"
+	"errors"
 	"log/slog"
-	"os"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
 	_ "github.com/lib/pq" // driver import
 	"github.com/scriptdealer/to-do-go/known"
 )
 
-type PostgreConfiguration struct {
-	UserName string
-	Password string
-	Host     string
-	Port     string
-	DBName   string
+type PostgresStorage struct {
+	DB      *sql.DB
+	dialect dialect
+	Log     *slog.Logger
 }
 
-func getConfig() *PostgreConfiguration {
-	cfg := PostgreConfiguration{
-		Host:     "db",
-		Port:     "5432",
-		DBName:   "todo_demo",
-		UserName: "pguser",
-		Password: "pgpassword",
-	}
-
-	host, found := os.LookupEnv("DB_HOST")
-	if found {
-		cfg.Host = host
+func init() {
+	opener := func(dsn *url.URL, logger *slog.Logger) (Backend, error) {
+		return OpenPostgres(dsn.String(), logger)
 	}
+	Register("postgres", opener)
+	Register("postgresql", opener)
+}
 
-	user, found := os.LookupEnv("DB_USER")
-	if found {
-		cfg.UserName = user
+// NewPostgresStore connects to the database and brings its schema up to date.
+func NewPostgresStore(dsn string, logger *slog.Logger) (*PostgresStorage, error) {
+	store, err := OpenPostgres(dsn, logger)
+	if err != nil {
+		return nil, err
 	}
 
-	pass, found := os.LookupEnv("DB_PASS")
-	if found {
-		cfg.Password = pass
+	if err := store.Init(); err != nil {
+		logger.Info("DB init failed", slog.String("reason", err.Error()))
+		return nil, err
 	}
 
-	return &cfg
-}
-
-type PostgresStorage struct {
-	DB  *sql.DB
-	cfg *PostgreConfiguration
-	Log *slog.Logger
+	return store, nil
 }
 
-func NewPostgresStore(logger *slog.Logger) (*PostgresStorage, error) {
+// OpenPostgres only connects to the database, leaving the schema as it is.
+func OpenPostgres(dsn string, logger *slog.Logger) (*PostgresStorage, error) {
 	logger.Info("Postgre storage selected")
 
-	config := getConfig()
-	connStr := fmt.Sprintf(
-		"host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		config.Host,
-		config.Port,
-		config.UserName,
-		config.Password,
-		config.DBName,
-	)
-
-	db, err := sql.Open("postgres", connStr)
+	db, err := sql.Open("postgres", dsn)
 	if err != nil {
 		return nil, err
 	}
@@ -75,108 +58,1733 @@ func NewPostgresStore(logger *slog.Logger) (*PostgresStorage, error) {
 		return nil, err
 	}
 
-	store := &PostgresStorage{
+	return &PostgresStorage{
 		DB:  db,
-		cfg: config,
 		Log: logger,
-	}
+	}, nil
+}
 
-	if err := store.Init(); err != nil {
-		logger.Info("DB init failed", slog.String("reason", err.Error()))
-		return nil, err
+func (s *PostgresStorage) Init() error {
+	migrator, err := s.Migrator()
+	if err != nil {
+		return err
 	}
 
-	return store, nil
+	_, err = migrator.Up(context.Background())
+	return err
 }
 
-func (s *PostgresStorage) Init() error {
-	return s.createToDoTable()
+func (s *PostgresStorage) Close() error {
+	return s.DB.Close()
 }
 
-func (s *PostgresStorage) createToDoTable() error {
-	query := `create table if not exists todos (
-		id serial primary key,
-		title varchar(100),
-		description varchar(100),
-		done boolean
-	)`
-
-	_, err := s.DB.Exec(query)
-	return err
+func (s *PostgresStorage) Migrator() (*Migrator, error) {
+	return NewMigrator(s.DB, s.dialect, s.Log)
 }
 
 func (s *PostgresStorage) Create(item *known.TodoItem) error {
-	query := `insert into todos (title, description, done) values ($1, $2, $3)`
+	// new items go to the end of the manual order, and only into a list of their owner
+	query := `insert into todos (title, description, done, owner_id, created_at, start_at, due_at, priority, position, list_id, parent_id, checklist, recurrence, series_id, completed_at) ` +
+		`values ($1, $2, $3, $4, $5, $6, $7, $8, (select coalesce(max(position), 0) + 1 from todos where owner_id = $4), ` +
+		`(select id from lists where id = $9 and owner_id = $4), $10, $11, $12, $13, $14) returning id, position`
 
-	_, err := s.DB.Exec(
+	checklist, err := checklistValue(item.Checklist)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if item.ParentID != 0 {
+		// subtasks go into the list of their parent
+		depth, list, err := nesting(tx, item.OwnerID, item.ParentID)
+		if err != nil {
+			return err
+		}
+		if depth+1 > MaxDepth {
+			return ErrTooDeep
+		}
+		item.ListID = list
+	}
+
+	err = tx.QueryRow(
 		query,
 		item.Title,
 		item.Description,
 		item.Done,
-	)
-
+		item.OwnerID,
+		item.Created.UTC(),
+		nullableTime(item.Start),
+		nullableTime(item.Due),
+		item.Priority,
+		item.ListID,
+		nullableID(item.ParentID),
+		checklist,
+		nullableText(item.Recurrence),
+		nullableID(item.SeriesID),
+		nullableTime(item.Completed),
+	).Scan(&item.ID, &item.Position)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	if err := linkTags(tx, item.OwnerID, item.ID, item.Tags); err != nil {
+		return err
+	}
+
+	item.Version = 1
+	if err := addRevision(tx, item.OwnerID, item.ID, known.RevisionCreate, nil, item); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
+// Update replaces the fields and the tags of the item in one transaction. An item stays
+// completed since it was first marked as done, and leaves the archive when it is undone.
 func (s *PostgresStorage) Update(item *known.TodoItem) error {
-	_, err := s.DB.Exec(
-		"update todos set title = $1, description = $2, done = $3 where id = $4",
+	checklist, err := checklistValue(item.Checklist)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	before, err := liveItem(tx, item.OwnerID, item.ID)
+	if err != nil {
+		return err
+	}
+	expected := item.Version
+	if expected != 0 && expected != before.Version {
+		return ErrStaleVersion
+	}
+	changes, err := known.Diff(before, item)
+	if err != nil {
+		return err
+	}
+	if len(changes) == 0 {
+		// nothing is written, so the version clients hold stays current
+		item.Version = before.Version
+		return nil
+	}
+
+	err = tx.QueryRow(
+		"update todos set title = $1, description = $2, done = $3, start_at = $4, due_at = $5, priority = $6, checklist = $7, recurrence = $8, "+
+			"completed_at = case when $3 then coalesce(completed_at, $11) end, archived_at = case when $3 then archived_at end, version = version + 1 "+
+			"where id = $9 and owner_id = $10 and deleted_at is null and ($12 = 0 or version = $12) returning version",
 		item.Title,
 		item.Description,
 		item.Done,
+		nullableTime(item.Start),
+		nullableTime(item.Due),
+		item.Priority,
+		checklist,
+		nullableText(item.Recurrence),
 		item.ID,
+		item.OwnerID,
+		nullableTime(item.Completed),
+		expected,
+	).Scan(&item.Version)
+	if errors.Is(err, sql.ErrNoRows) {
+		// the item changed or went away since it was read
+		if expected != 0 {
+			return ErrStaleVersion
+		}
+		return ErrNoItem
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := resolveReminders(tx, item.ID, item.Due); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("delete from todo_tags where todo_id = $1", item.ID); err != nil {
+		return err
+	}
+
+	if err := linkTags(tx, item.OwnerID, item.ID, item.Tags); err != nil {
+		return err
+	}
+
+	if err := addRevision(tx, item.OwnerID, item.ID, known.RevisionUpdate, before, item); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Delete stamps the item, and with cascade its live subtasks, with the time they were trashed at.
+func (s *PostgresStorage) Delete(owner, id int, cascade bool, version int) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if version != 0 {
+		if err := checkVersion(tx, owner, id, version); err != nil {
+			return err
+		}
+	}
+
+	if !cascade {
+		var subtasks int
+		if err := tx.QueryRow("select count(*) from todos where parent_id = $1 and owner_id = $2 and deleted_at is null", id, owner).Scan(&subtasks); err != nil {
+			return err
+		}
+		if subtasks > 0 {
+			return ErrHasSubtasks
+		}
+	}
+
+	deleted := time.Now().UTC().Truncate(time.Microsecond)
+	result, err := tx.Exec("update todos set deleted_at = $3 where deleted_at is null and id in ("+subtree+"select id from subtree)", id, owner, deleted)
+	if err != nil {
+		return err
+	}
+
+	if err := expectAffected(result, ErrNoItem); err != nil {
+		return err
+	}
+
+	trashed := "select id from todos where deleted_at = $2 and id in (" + trashedSubtree + "select id from subtree)"
+	if err := addRevisions(tx, owner, known.RevisionDelete, trashed, id, deleted); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Restore only takes back the subtasks trashed at the same time as the item,
+// those trashed on their own before it stay in the trash.
+func (s *PostgresStorage) Restore(owner, id int) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var deleted time.Time
+	var parent sql.NullInt64
+	err = tx.QueryRow("select deleted_at, parent_id from todos where id = $1 and owner_id = $2 and deleted_at is not null", id, owner).Scan(&deleted, &parent)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNoItem
+	}
+	if err != nil {
+		return err
+	}
+
+	if parent.Valid {
+		var live int
+		if err := tx.QueryRow("select count(*) from todos where id = $1 and deleted_at is null", parent.Int64).Scan(&live); err != nil {
+			return err
+		}
+		if live == 0 {
+			return ErrParentTrashed
+		}
+	}
+
+	restored := "deleted_at = $2 and id in (" + trashedSubtree + "select id from subtree)"
+	if err := addRevisions(tx, owner, known.RevisionRestore, "select id from todos where "+restored, id, deleted.UTC()); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("update todos set deleted_at = null where "+restored, id, deleted.UTC()); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Purge relies on the foreign keys to delete the subtasks, tags, edges and reminders along.
+func (s *PostgresStorage) Purge(owner, id int) error {
+	result, err := s.DB.Exec("delete from todos where id = $1 and owner_id = $2 and deleted_at is not null", id, owner)
+	if err != nil {
+		return err
+	}
+
+	return expectAffected(result, ErrNoItem)
+}
+
+func (s *PostgresStorage) ArchiveDone(before time.Time) (int, error) {
+	result, err := s.DB.Exec(
+		"update todos set archived_at = $2 where archived_at is null and completed_at < $1 and deleted_at is null",
+		before.UTC(), time.Now().UTC().Truncate(time.Microsecond),
 	)
-	return err
+	if err != nil {
+		return 0, err
+	}
+
+	archived, err := result.RowsAffected()
+
+	return int(archived), err
 }
 
-func (s *PostgresStorage) Delete(id int) error {
-	_, err := s.DB.Exec("delete from todos where id = $1", id)
-	return err
+func (s *PostgresStorage) Unarchive(owner, id int) error {
+	result, err := s.DB.Exec(
+		"update todos set archived_at = null where id = $1 and owner_id = $2 and archived_at is not null and deleted_at is null",
+		id, owner,
+	)
+	if err != nil {
+		return err
+	}
+
+	return expectAffected(result, ErrNoItem)
 }
 
-func (s *PostgresStorage) GetOne(id int) (*known.TodoItem, error) {
-	rows, err := s.DB.Query("select * from todos where id = $1", id)
+func (s *PostgresStorage) PurgeTrash(before time.Time) (int, error) {
+	// the foreign key takes the subtasks of an expired parent along, only the parent is counted
+	result, err := s.DB.Exec(
+		"delete from todos where deleted_at < $1 and (parent_id is null or parent_id not in (select id from todos where deleted_at < $1))",
+		before.UTC(),
+	)
 	if err != nil {
-		return nil, err
+		return 0, err
+	}
+
+	purged, err := result.RowsAffected()
+
+	return int(purged), err
+}
+
+// subtree selects the item $1 of the owner $2 and all of its subtasks,
+// with their depth below it starting from 1; statements append their own select.
+const subtree = "with recursive subtree(id, depth) as (select id, 1 from todos where id = $1 and owner_id = $2 and deleted_at is null " +
+	"union all select t.id, s.depth + 1 from todos t join subtree s on t.parent_id = s.id where t.deleted_at is null) "
+
+// trashedSubtree selects the trashed item $1 and all of its subtasks, which are all in the trash.
+const trashedSubtree = "with recursive subtree(id) as (select id from todos where id = $1 and deleted_at is not null " +
+	"union all select t.id from todos t join subtree s on t.parent_id = s.id) "
+
+// nesting reports how deep the item is nested, counting itself, and the list it is in.
+func nesting(tx *sql.Tx, owner, id int) (int, int, error) {
+	var list sql.NullInt64
+	err := tx.QueryRow("select list_id from todos where id = $1 and owner_id = $2 and deleted_at is null", id, owner).Scan(&list)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, 0, ErrNoItem
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var depth int
+	err = tx.QueryRow(
+		"with recursive ancestors(id, parent_id) as (select id, parent_id from todos where id = $1 "+
+			"union all select t.id, t.parent_id from todos t join ancestors a on t.id = a.parent_id) select count(*) from ancestors",
+		id,
+	).Scan(&depth)
+
+	return depth, int(list.Int64), err
+}
+
+func (s *PostgresStorage) SetParent(owner, id, parent int) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var height, cycle int
+	err = tx.QueryRow(subtree+"select coalesce(max(depth), 0), count(case when id = $3 then 1 end) from subtree", id, owner, parent).Scan(&height, &cycle)
+	if err != nil {
+		return err
+	}
+	if height == 0 {
+		return ErrNoItem
+	}
+
+	if parent != 0 {
+		depth, list, err := nesting(tx, owner, parent)
+		if err != nil {
+			return err
+		}
+		if cycle > 0 {
+			return ErrSubtaskCycle
+		}
+		if depth+height > MaxDepth {
+			return ErrTooDeep
+		}
+
+		if _, err := tx.Exec("update todos set list_id = $3 where id in ("+subtree+"select id from subtree)", id, owner, nullableID(list)); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec("update todos set parent_id = $1 where id = $2", nullableID(parent), id); err != nil {
+		return err
 	}
 
+	return tx.Commit()
+}
+
+func (s *PostgresStorage) CompleteSubtasks(owner, id int) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	rows, err := tx.Query("select "+todoColumns+" from todos where not done and id <> $1 and id in ("+subtree+"select id from subtree)", id, owner)
+	if err != nil {
+		return err
+	}
+	var undone []*known.TodoItem
 	for rows.Next() {
-		return scanItem(rows)
+		subtask, err := scanItem(rows)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		undone = append(undone, subtask)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	// the subtasks are completed along with the item
+	_, err = tx.Exec(
+		"update todos set done = $3, completed_at = coalesce(completed_at, (select completed_at from todos where id = $1)), version = case when done then version else version + 1 end "+
+			"where id <> $1 and id in ("+subtree+"select id from subtree)",
+		id, owner, true,
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, subtask := range undone {
+		done := *subtask
+		done.Done = true
+		if err := addRevision(tx, owner, subtask.ID, known.RevisionUpdate, subtask, &done); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// checkVersion compares the current version of the live item id with the expected one; the update
+// changing nothing locks the row, so the version holds until the transaction ends.
+func checkVersion(tx *sql.Tx, owner, id, version int) error {
+	var current int
+	err := tx.QueryRow("update todos set version = version where id = $1 and owner_id = $2 and deleted_at is null returning version", id, owner).Scan(&current)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNoItem
+	}
+	if err != nil {
+		return err
 	}
 
-	return nil, errNoItem
+	if current != version {
+		return ErrStaleVersion
+	}
+
+	return nil
 }
 
-func (s *PostgresStorage) GetAll(ctx context.Context) ([]*known.TodoItem, error) {
-	rows, err := s.DB.QueryContext(ctx, "select * from todos")
+// liveItem reads the item id along with its tags within tx, to compare it with its update.
+func liveItem(tx *sql.Tx, owner, id int) (*known.TodoItem, error) {
+	rows, err := tx.Query("select "+todoColumns+" from todos where id = $1 and owner_id = $2 and deleted_at is null", id, owner)
+	if err != nil {
+		return nil, err
+	}
+	var item *known.TodoItem
+	if rows.Next() {
+		item, err = scanItem(rows)
+	}
+	rows.Close()
+	if err == nil {
+		err = rows.Err()
+	}
 	if err != nil {
 		return nil, err
 	}
+	if item == nil {
+		return nil, ErrNoItem
+	}
+
+	rows, err = tx.Query("select t.name from todo_tags tt join tags t on t.id = tt.tag_id where tt.todo_id = $1 order by t.name", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
-	items := []*known.TodoItem{}
 	for rows.Next() {
-		item, err := scanItem(rows)
-		if err != nil {
+		var name string
+		if err := rows.Scan(&name); err != nil {
 			return nil, err
 		}
+		item.Tags = append(item.Tags, name)
+	}
 
-		items = append(items, item)
+	return item, rows.Err()
+}
+
+// addRevision records the change of the item id from before to after, made by actor; before is nil
+// for a created item and both are nil for a deletion or a restore. An update changing nothing is left out.
+func addRevision(tx *sql.Tx, actor, id int, action string, before, after *known.TodoItem) error {
+	var changes any
+	if after != nil {
+		diff, err := known.Diff(before, after)
+		if err != nil {
+			return err
+		}
+		if len(diff) == 0 && action == known.RevisionUpdate {
+			return nil
+		}
+
+		encoded, err := json.Marshal(diff)
+		if err != nil {
+			return err
+		}
+		changes = string(encoded)
 	}
 
-	return items, nil
+	_, err := tx.Exec(
+		"insert into item_revisions (todo_id, actor_id, action, created_at, changes) values ($1, $2, $3, $4, $5)",
+		id, actor, action, time.Now().UTC().Truncate(time.Microsecond), changes,
+	)
+	return err
 }
 
-func scanItem(rows *sql.Rows) (*known.TodoItem, error) {
-	item := new(known.TodoItem)
-	err := rows.Scan(
-		&item.ID,
-		&item.Title,
-		&item.Description,
-		&item.Done)
+// addRevisions records a deletion or a restore of every item the query selects.
+func addRevisions(tx *sql.Tx, actor int, action, query string, args ...any) error {
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
 
-	return item, err
+	for _, id := range ids {
+		if err := addRevision(tx, actor, id, action, nil, nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *PostgresStorage) GetRevisions(owner, id int) ([]*known.Revision, error) {
+	var found int
+	if err := s.DB.QueryRow("select count(*) from todos where id = $1 and owner_id = $2", id, owner).Scan(&found); err != nil {
+		return nil, err
+	}
+	if found == 0 {
+		return nil, ErrNoItem
+	}
+
+	rows, err := s.DB.Query("select id, todo_id, actor_id, action, created_at, changes from item_revisions where todo_id = $1 order by id", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []*known.Revision{}
+	for rows.Next() {
+		revision := new(known.Revision)
+		var changes sql.NullString
+		if err := rows.Scan(&revision.ID, &revision.TodoID, &revision.ActorID, &revision.Action, &revision.Created, &changes); err != nil {
+			return nil, err
+		}
+		revision.Created = revision.Created.UTC()
+		if changes.Valid {
+			if err := json.Unmarshal([]byte(changes.String), &revision.Changes); err != nil {
+				return nil, err
+			}
+		}
+		revisions = append(revisions, revision)
+	}
+
+	return revisions, rows.Err()
+}
+
+func (s *PostgresStorage) GetOne(owner, id int) (*known.TodoItem, error) {
+	items, err := s.queryItems(context.Background(), "select "+todoColumns+" from todos where id = $1 and owner_id = $2 and deleted_at is null", id, owner)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(items) == 0 {
+		return nil, ErrNoItem
+	}
+
+	return items[0], nil
+}
+
+func (s *PostgresStorage) GetAll(ctx context.Context, owner int, query known.TodoQuery) ([]*known.TodoItem, error) {
+	where := newConditions(owner)
+	switch {
+	case query.Trashed:
+		where.add("deleted_at is not null")
+	case query.AnyArchived:
+		where.add("deleted_at is null")
+	case query.Archived:
+		where.add("deleted_at is null and archived_at is not null")
+	default:
+		where.add("deleted_at is null and archived_at is null")
+	}
+	if query.ListID != 0 {
+		where.add("list_id = %s", query.ListID)
+	} else if !query.Trashed {
+		where.add("(list_id is null or list_id not in (select id from lists where owner_id = %s and archived))", owner)
+	}
+	if query.ParentID != 0 {
+		where.add("parent_id = %s", query.ParentID)
+	}
+	if query.SeriesID != 0 {
+		where.add("(id = %[1]s or series_id = %[1]s)", query.SeriesID)
+	}
+	if query.Done != nil {
+		where.add("done = %s", *query.Done)
+	}
+	if query.Text != "" {
+		where.add("(title "+s.dialect.ilike()+" %[1]s escape '\\' or description "+s.dialect.ilike()+" %[1]s escape '\\')", likePattern(query.Text))
+	}
+	if query.DueFrom != nil {
+		where.add("due_at >= %s", query.DueFrom.UTC())
+	}
+	if query.DueBefore != nil {
+		where.add("due_at < %s", query.DueBefore.UTC())
+	}
+	if len(query.Tags) > 0 {
+		names := make([]any, len(query.Tags))
+		for i, name := range query.Tags {
+			names[i] = name
+		}
+		clause := "id in (select tt.todo_id from todo_tags tt join tags t on t.id = tt.tag_id where t.name in (" +
+			strings.TrimSuffix(strings.Repeat("%s, ", len(names)), ", ") + ") group by tt.todo_id"
+		if !query.AnyTag {
+			clause += " having count(*) = " + strconv.Itoa(len(names))
+		}
+		where.add(clause+")", names...)
+	}
+
+	nullsLast := query.SortBy == known.SortByDue || query.SortBy == known.SortByCompleted
+	if after := query.After; after != nil {
+		addCursor(where, sortColumn(query.SortBy), after, nullsLast)
+	}
+
+	direction := " asc"
+	if query.Desc {
+		direction = " desc"
+	}
+	order := sortColumn(query.SortBy) + direction
+	if nullsLast {
+		order += " nulls last"
+	}
+	statement := "select " + todoColumns + " from todos where " + where.String() +
+		" order by " + order + ", id" + direction
+	if query.Limit > 0 {
+		statement += " limit " + where.arg(query.Limit)
+	}
+
+	return s.queryItems(ctx, statement, where.args...)
+}
+
+// queryItems reads the items selected by statement along with their tags.
+// The rows are closed before the tags are queried, SQLite has a single connection.
+func (s *PostgresStorage) queryItems(ctx context.Context, statement string, args ...any) ([]*known.TodoItem, error) {
+	rows, err := s.DB.QueryContext(ctx, statement, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	items := []*known.TodoItem{}
+	for rows.Next() {
+		item, err := scanItem(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+
+		items = append(items, item)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := s.loadTags(ctx, items); err != nil {
+		return nil, err
+	}
+
+	return items, s.loadProgress(ctx, items)
+}
+
+func (s *PostgresStorage) loadTags(ctx context.Context, items []*known.TodoItem) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	byID := make(map[int]*known.TodoItem, len(items))
+	ids := &conditions{}
+	placeholders := make([]string, len(items))
+	for i, item := range items {
+		byID[item.ID] = item
+		placeholders[i] = ids.arg(item.ID)
+	}
+
+	rows, err := s.DB.QueryContext(ctx,
+		"select tt.todo_id, t.name from todo_tags tt join tags t on t.id = tt.tag_id where tt.todo_id in ("+
+			strings.Join(placeholders, ", ")+") order by t.name",
+		ids.args...,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return err
+		}
+		byID[id].Tags = append(byID[id].Tags, name)
+	}
+
+	return rows.Err()
+}
+
+// loadProgress counts the done direct subtasks of the items along with their checklist entries.
+func (s *PostgresStorage) loadProgress(ctx context.Context, items []*known.TodoItem) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	progress := make(map[int]*known.Progress, len(items))
+	ids := &conditions{}
+	placeholders := make([]string, len(items))
+	for i, item := range items {
+		progress[item.ID] = &known.Progress{}
+		placeholders[i] = ids.arg(item.ID)
+	}
+
+	rows, err := s.DB.QueryContext(ctx,
+		"select parent_id, count(*), count(case when done then 1 end) from todos where deleted_at is null and parent_id in ("+
+			strings.Join(placeholders, ", ")+") group by parent_id",
+		ids.args...,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id, total, done int
+		if err := rows.Scan(&id, &total, &done); err != nil {
+			return err
+		}
+		progress[id].Total, progress[id].Done = total, done
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		for _, entry := range item.Checklist {
+			progress[item.ID].Total++
+			if entry.Done {
+				progress[item.ID].Done++
+			}
+		}
+		if progress[item.ID].Total > 0 {
+			item.Progress = progress[item.ID]
+		}
+	}
+
+	return nil
+}
+
+// linkTags attaches the named tags of owner to an item, creating the missing ones.
+func linkTags(tx *sql.Tx, owner, todoID int, names []string) error {
+	for _, name := range names {
+		_, err := tx.Exec("insert into tags (owner_id, name) values ($1, $2) on conflict (owner_id, name) do nothing", owner, name)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec("insert into todo_tags (todo_id, tag_id) select $1, id from tags where owner_id = $2 and name = $3", todoID, owner, name)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *PostgresStorage) CreateTag(tag *known.Tag) error {
+	err := s.DB.QueryRow("insert into tags (owner_id, name) values ($1, $2) returning id", tag.OwnerID, tag.Name).Scan(&tag.ID)
+	if isUniqueViolation(err) {
+		return ErrTagTaken
+	}
+
+	return err
+}
+
+func (s *PostgresStorage) GetTags(owner int) ([]*known.Tag, error) {
+	rows, err := s.DB.Query(
+		"select t.id, t.name, count(i.id) from tags t left join todo_tags tt on tt.tag_id = t.id left join todos i on i.id = tt.todo_id and i.deleted_at is null "+
+			"where t.owner_id = $1 group by t.id, t.name order by t.name",
+		owner,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []*known.Tag{}
+	for rows.Next() {
+		tag := known.Tag{OwnerID: owner}
+		if err := rows.Scan(&tag.ID, &tag.Name, &tag.Items); err != nil {
+			return nil, err
+		}
+		tags = append(tags, &tag)
+	}
+
+	return tags, rows.Err()
+}
+
+func (s *PostgresStorage) RenameTag(owner, id int, name string) error {
+	result, err := s.DB.Exec("update tags set name = $1 where id = $2 and owner_id = $3", name, id, owner)
+	if isUniqueViolation(err) {
+		return ErrTagTaken
+	}
+	if err != nil {
+		return err
+	}
+
+	return expectAffected(result, ErrNoTag)
+}
+
+// MergeTags moves the items of the tag from over to the tag into and drops the former,
+// all in one transaction.
+func (s *PostgresStorage) MergeTags(owner, from, into int) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var found int
+	err = tx.QueryRow("select count(*) from tags where owner_id = $1 and id in ($2, $3)", owner, from, into).Scan(&found)
+	if err != nil {
+		return err
+	}
+	if found != 2 {
+		return ErrNoTag
+	}
+
+	_, err = tx.Exec("insert into todo_tags (todo_id, tag_id) select todo_id, $2 from todo_tags where tag_id = $1 on conflict do nothing", from, into)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("delete from tags where id = $1", from); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *PostgresStorage) DeleteTag(owner, id int) error {
+	result, err := s.DB.Exec("delete from tags where id = $1 and owner_id = $2", id, owner)
+	if err != nil {
+		return err
+	}
+
+	return expectAffected(result, ErrNoTag)
+}
+
+func (s *PostgresStorage) CreateList(list *known.List) error {
+	err := s.DB.QueryRow("insert into lists (owner_id, name) values ($1, $2) returning id", list.OwnerID, list.Name).Scan(&list.ID)
+	if isUniqueViolation(err) {
+		return ErrListTaken
+	}
+
+	return err
+}
+
+func (s *PostgresStorage) GetList(owner, id int) (*known.List, error) {
+	lists, err := s.queryLists("l.owner_id = $1 and l.id = $2", owner, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(lists) == 0 {
+		return nil, ErrNoList
+	}
+
+	return lists[0], nil
+}
+
+func (s *PostgresStorage) GetLists(owner int, archived bool) ([]*known.List, error) {
+	where := "l.owner_id = $1"
+	if !archived {
+		where += " and not l.archived"
+	}
+
+	return s.queryLists(where, owner)
+}
+
+// queryLists reads the lists matching where along with the counts of their items.
+func (s *PostgresStorage) queryLists(where string, args ...any) ([]*known.List, error) {
+	rows, err := s.DB.Query(
+		"select l.id, l.owner_id, l.name, l.archived, count(case when not t.done then 1 end), count(case when t.done then 1 end) "+
+			"from lists l left join todos t on t.list_id = l.id and t.deleted_at is null where "+where+
+			" group by l.id, l.owner_id, l.name, l.archived order by l.id",
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lists := []*known.List{}
+	for rows.Next() {
+		list := new(known.List)
+		if err := rows.Scan(&list.ID, &list.OwnerID, &list.Name, &list.Archived, &list.Open, &list.Done); err != nil {
+			return nil, err
+		}
+		lists = append(lists, list)
+	}
+
+	return lists, rows.Err()
+}
+
+func (s *PostgresStorage) RenameList(owner, id int, name string) error {
+	result, err := s.DB.Exec("update lists set name = $1 where id = $2 and owner_id = $3", name, id, owner)
+	if isUniqueViolation(err) {
+		return ErrListTaken
+	}
+	if err != nil {
+		return err
+	}
+
+	return expectAffected(result, ErrNoList)
+}
+
+func (s *PostgresStorage) ArchiveList(owner, id int, archived bool) error {
+	result, err := s.DB.Exec("update lists set archived = $1 where id = $2 and owner_id = $3", archived, id, owner)
+	if err != nil {
+		return err
+	}
+
+	return expectAffected(result, ErrNoList)
+}
+
+// DeleteList relies on the foreign key to move the items back to the inbox.
+func (s *PostgresStorage) DeleteList(owner, id int) error {
+	result, err := s.DB.Exec("delete from lists where id = $1 and owner_id = $2", id, owner)
+	if err != nil {
+		return err
+	}
+
+	return expectAffected(result, ErrNoList)
+}
+
+func (s *PostgresStorage) MoveToList(owner, id, list int) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var target *int
+	if list != 0 {
+		err := tx.QueryRow("select id from lists where id = $1 and owner_id = $2", list, owner).Scan(&target)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoList
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	var parent sql.NullInt64
+	err = tx.QueryRow("select parent_id from todos where id = $1 and owner_id = $2 and deleted_at is null", id, owner).Scan(&parent)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNoItem
+	}
+	if err != nil {
+		return err
+	}
+	if parent.Valid {
+		return ErrSubtaskList
+	}
+
+	if _, err := tx.Exec("update todos set list_id = $3 where id in ("+subtree+"select id from subtree)", id, owner, target); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// AddBlocker checks for a cycle and adds the edge holding the owner's lock, so two
+// concurrent edges cannot close a cycle together.
+func (s *PostgresStorage) AddBlocker(owner, id, blocker int) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := s.lockOwner(tx, owner); err != nil {
+		return err
+	}
+	var found int
+	err = tx.QueryRow("select count(*) from todos where owner_id = $1 and id in ($2, $3) and deleted_at is null", owner, id, blocker).Scan(&found)
+	if err != nil {
+		return err
+	}
+	if found == 0 || found == 1 && id != blocker {
+		return ErrNoItem
+	}
+
+	// the items the blocker depends on, directly or not, must not include the item
+	var cycle int
+	err = tx.QueryRow(
+		"with recursive upstream(id) as (select cast($1 as integer) union select tb.blocker_id from todo_blockers tb join upstream u on tb.todo_id = u.id) "+
+			"select count(*) from upstream where id = $2",
+		blocker, id,
+	).Scan(&cycle)
+	if err != nil {
+		return err
+	}
+	if cycle > 0 {
+		return ErrBlockerCycle
+	}
+
+	_, err = tx.Exec("insert into todo_blockers (todo_id, blocker_id) values ($1, $2) on conflict do nothing", id, blocker)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *PostgresStorage) RemoveBlocker(owner, id, blocker int) error {
+	result, err := s.DB.Exec(
+		"delete from todo_blockers where todo_id = $1 and blocker_id = $2 and todo_id in (select id from todos where owner_id = $3 and deleted_at is null)",
+		id, blocker, owner,
+	)
+	if err != nil {
+		return err
+	}
+
+	return expectAffected(result, ErrNoBlocker)
+}
+
+func (s *PostgresStorage) GetBlockers(owner, id int) ([]*known.TodoItem, error) {
+	var found int
+	if err := s.DB.QueryRow("select count(*) from todos where id = $1 and owner_id = $2 and deleted_at is null", id, owner).Scan(&found); err != nil {
+		return nil, err
+	}
+	if found == 0 {
+		return nil, ErrNoItem
+	}
+
+	return s.queryItems(context.Background(),
+		"select "+todoColumns+" from todos where owner_id = $1 and deleted_at is null and id in (select blocker_id from todo_blockers where todo_id = $2) order by position, id",
+		owner, id,
+	)
+}
+
+func (s *PostgresStorage) GetDependencies(owner int) ([]known.Dependency, error) {
+	rows, err := s.DB.Query(
+		"select tb.todo_id, tb.blocker_id from todo_blockers tb join todos t on t.id = tb.todo_id join todos b on b.id = tb.blocker_id "+
+			"where t.owner_id = $1 and not t.done and not b.done and t.deleted_at is null and b.deleted_at is null",
+		owner,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	edges := []known.Dependency{}
+	for rows.Next() {
+		var edge known.Dependency
+		if err := rows.Scan(&edge.ItemID, &edge.BlockerID); err != nil {
+			return nil, err
+		}
+		edges = append(edges, edge)
+	}
+
+	return edges, rows.Err()
+}
+
+// CreateReminder resolves the firing time of the reminder against the due date of its item.
+func (s *PostgresStorage) CreateReminder(reminder *known.Reminder) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var due sql.NullTime
+	err = tx.QueryRow("select due_at from todos where id = $1 and owner_id = $2 and deleted_at is null", reminder.TodoID, reminder.OwnerID).Scan(&due)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNoItem
+	}
+	if err != nil {
+		return err
+	}
+
+	reminder.Resolve(timeOrNil(due))
+	err = tx.QueryRow(
+		"insert into reminders (todo_id, remind_at, minutes_before, fire_at) values ($1, $2, $3, $4) returning id",
+		reminder.TodoID,
+		nullableTime(reminder.At),
+		reminder.MinutesBefore,
+		nullableTime(reminder.FireAt),
+	).Scan(&reminder.ID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// resolveReminders moves the pending offset reminders of an item along with its due date.
+// The rows are read before the updates run, SQLite has a single connection.
+func resolveReminders(tx *sql.Tx, id int, due *time.Time) error {
+	rows, err := tx.Query("select id, minutes_before from reminders where todo_id = $1 and minutes_before is not null and sent_at is null", id)
+	if err != nil {
+		return err
+	}
+
+	var reminders []known.Reminder
+	for rows.Next() {
+		var reminder known.Reminder
+		if err := rows.Scan(&reminder.ID, &reminder.MinutesBefore); err != nil {
+			rows.Close()
+			return err
+		}
+		reminders = append(reminders, reminder)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, reminder := range reminders {
+		reminder.Resolve(due)
+		if _, err := tx.Exec("update reminders set fire_at = $1 where id = $2", nullableTime(reminder.FireAt), reminder.ID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *PostgresStorage) GetReminders(owner, id int) ([]*known.Reminder, error) {
+	var found int
+	if err := s.DB.QueryRow("select count(*) from todos where id = $1 and owner_id = $2 and deleted_at is null", id, owner).Scan(&found); err != nil {
+		return nil, err
+	}
+	if found == 0 {
+		return nil, ErrNoItem
+	}
+
+	return s.queryReminders("t.owner_id = $1 and r.todo_id = $2 order by r.fire_at nulls last, r.id", owner, id)
+}
+
+func (s *PostgresStorage) DeleteReminder(owner, id, reminder int) error {
+	result, err := s.DB.Exec(
+		"delete from reminders where id = $1 and todo_id = $2 and todo_id in (select id from todos where owner_id = $3 and deleted_at is null)",
+		reminder, id, owner,
+	)
+	if err != nil {
+		return err
+	}
+
+	return expectAffected(result, ErrNoReminder)
+}
+
+func (s *PostgresStorage) DueReminders(now, lapsed time.Time, maxAttempts, limit int) ([]*known.Reminder, error) {
+	return s.queryReminders(
+		"r.sent_at is null and (r.claimed_at is null or r.claimed_at < $1) and r.fire_at <= $2 and r.attempts < $3 "+
+			"and not t.done and t.deleted_at is null order by r.fire_at, r.id limit $4",
+		lapsed.UTC(), now.UTC(), maxAttempts, limit,
+	)
+}
+
+// ClaimReminder only updates an unsent reminder without a live claim, so of two concurrent claims one wins.
+func (s *PostgresStorage) ClaimReminder(id int, at, lapsed time.Time) (bool, error) {
+	result, err := s.DB.Exec(
+		"update reminders set claimed_at = $1 where id = $2 and sent_at is null and (claimed_at is null or claimed_at < $3)",
+		at.UTC(), id, lapsed.UTC(),
+	)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	return affected == 1, err
+}
+
+func (s *PostgresStorage) SentReminder(id int, at time.Time) error {
+	result, err := s.DB.Exec("update reminders set sent_at = $1, claimed_at = null where id = $2", at.UTC(), id)
+	if err != nil {
+		return err
+	}
+
+	return expectAffected(result, ErrNoReminder)
+}
+
+func (s *PostgresStorage) ReleaseReminder(id int) error {
+	result, err := s.DB.Exec("update reminders set claimed_at = null, attempts = attempts + 1 where id = $1", id)
+	if err != nil {
+		return err
+	}
+
+	return expectAffected(result, ErrNoReminder)
+}
+
+// schedulerLock is the key of the Postgres advisory lock of the reminder scheduler.
+const schedulerLock = 7_106_511
+
+// Lead holds a session-level advisory lock on a connection of its own, which Postgres
+// releases when the connection drops; SQLite is used by a single server, which always leads.
+func (s *PostgresStorage) Lead(ctx context.Context) (func(), bool, error) {
+	if s.dialect == dialectSQLite {
+		return func() {}, true, nil
+	}
+
+	conn, err := s.DB.Conn(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var acquired bool
+	if err := conn.QueryRowContext(ctx, "select pg_try_advisory_lock($1)", schedulerLock).Scan(&acquired); err != nil || !acquired {
+		conn.Close()
+		return nil, false, err
+	}
+
+	release := func() {
+		if _, err := conn.ExecContext(context.Background(), "select pg_advisory_unlock($1)", schedulerLock); err != nil {
+			s.Log.Warn("Releasing the scheduler lock", slog.String("reason", err.Error()))
+		}
+		conn.Close()
+	}
+
+	return release, true, nil
+}
+
+const reminderColumns = "r.id, r.todo_id, t.owner_id, r.remind_at, r.minutes_before, r.fire_at, r.sent_at, r.attempts"
+
+func (s *PostgresStorage) queryReminders(where string, args ...any) ([]*known.Reminder, error) {
+	rows, err := s.DB.Query("select "+reminderColumns+" from reminders r join todos t on t.id = r.todo_id where "+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reminders := []*known.Reminder{}
+	for rows.Next() {
+		reminder := new(known.Reminder)
+		var at, fireAt, sentAt sql.NullTime
+		var minutes sql.NullInt64
+		err := rows.Scan(&reminder.ID, &reminder.TodoID, &reminder.OwnerID, &at, &minutes, &fireAt, &sentAt, &reminder.Attempts)
+		if err != nil {
+			return nil, err
+		}
+		reminder.At = timeOrNil(at)
+		reminder.FireAt = timeOrNil(fireAt)
+		reminder.SentAt = timeOrNil(sentAt)
+		if minutes.Valid {
+			before := int(minutes.Int64)
+			reminder.MinutesBefore = &before
+		}
+		reminders = append(reminders, reminder)
+	}
+
+	return reminders, rows.Err()
+}
+
+// Move puts the item id right before or after the anchor item in the manual order.
+// It holds the owner's lock, so concurrent moves cannot pick the same position.
+func (s *PostgresStorage) Move(owner, id, anchor int, before bool) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := s.lockOwner(tx, owner); err != nil {
+		return err
+	}
+	if _, err := positionOf(tx, owner, id); err != nil {
+		return err
+	}
+
+	neighbourQuery := "select position from todos where owner_id = $1 and id <> $2 and deleted_at is null and position > $3 order by position asc limit 1"
+	if before {
+		neighbourQuery = "select position from todos where owner_id = $1 and id <> $2 and deleted_at is null and position < $3 order by position desc limit 1"
+	}
+
+	for renumbered := false; ; renumbered = true {
+		pivot, err := positionOf(tx, owner, anchor)
+		if err != nil {
+			return err
+		}
+
+		var neighbour *float64
+		err = tx.QueryRow(neighbourQuery, owner, id, pivot).Scan(&neighbour)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+
+		position, ok := between(pivot, neighbour, before)
+		if ok || renumbered {
+			if _, err := tx.Exec("update todos set position = $1 where id = $2 and owner_id = $3", position, id, owner); err != nil {
+				return err
+			}
+			return tx.Commit()
+		}
+
+		if err := renumber(tx, owner); err != nil {
+			return err
+		}
+	}
+}
+
+// ownerLockClass keys the Postgres advisory locks of the owners, in the two-key space
+// that does not overlap with the single keys of the migrations and the scheduler.
+const ownerLockClass = 7_318_112
+
+// lockOwner takes a lock on the owner's items held until tx ends, for the transactions
+// that read the items before changing them; at read committed they would otherwise act
+// on what another one is about to change. SQLite runs one transaction at a time anyway.
+func (s *PostgresStorage) lockOwner(tx *sql.Tx, owner int) error {
+	if s.dialect == dialectSQLite {
+		return nil
+	}
+
+	_, err := tx.Exec("select pg_advisory_xact_lock($1, $2)", ownerLockClass, owner)
+	return err
+}
+
+func positionOf(tx *sql.Tx, owner, id int) (float64, error) {
+	var position float64
+	err := tx.QueryRow("select position from todos where id = $1 and owner_id = $2 and deleted_at is null", id, owner).Scan(&position)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrNoItem
+	}
+
+	return position, err
+}
+
+// renumber spreads the positions of the owner's items back to 1, 2, 3...
+// It is only needed after a long run of moves into the same gap.
+func renumber(tx *sql.Tx, owner int) error {
+	rows, err := tx.Query("select id from todos where owner_id = $1 order by position, id", owner)
+	if err != nil {
+		return err
+	}
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for i, id := range ids {
+		if _, err := tx.Exec("update todos set position = $1 where id = $2", i+1, id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addCursor keeps the items listed past the one cursor marks, ties on column being broken by ID;
+// with nullsLast the items missing the column come after all the others.
+func addCursor(where *conditions, column string, cursor *known.PageCursor, nullsLast bool) {
+	past := " > "
+	if cursor.Desc {
+		past = " < "
+	}
+
+	key := cursor.Key
+	if date, ok := key.(time.Time); ok {
+		key = date.UTC()
+	}
+	switch {
+	case cursor.SortBy == known.SortByID:
+		where.add("id"+past+"%s", cursor.ID)
+	case key == nil:
+		where.add(column+" is null and id"+past+"%s", cursor.ID)
+	case nullsLast:
+		where.add("("+column+past+"%[1]s or "+column+" = %[1]s and id"+past+"%[2]s or "+column+" is null)", key, cursor.ID)
+	default:
+		where.add("("+column+past+"%[1]s or "+column+" = %[1]s and id"+past+"%[2]s)", key, cursor.ID)
+	}
+}
+
+// sortColumn maps known.TodoQuery.SortBy to a column; anything unknown follows the manual order.
+func sortColumn(sortBy string) string {
+	switch sortBy {
+	case known.SortByID:
+		return "id"
+	case known.SortByPriority:
+		return "priority"
+	case known.SortByTitle:
+		return "title"
+	case known.SortByCreated:
+		return "created_at"
+	case known.SortByDue:
+		return "due_at"
+	case known.SortByCompleted:
+		return "completed_at"
+	case known.SortByDeleted:
+		return "deleted_at"
+	default:
+		return "position"
+	}
+}
+
+// expectAffected turns a statement that matched no rows into the missing error,
+// so foreign and missing rows look the same to the caller.
+func expectAffected(result sql.Result, missing error) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return missing
+	}
+
+	return nil
+}
+
+const todoColumns = "id, title, description, done, owner_id, created_at, start_at, due_at, priority, position, list_id, parent_id, checklist, recurrence, series_id, completed_at, archived_at, deleted_at, version"
+
+func scanItem(rows *sql.Rows) (*known.TodoItem, error) {
+	item := new(known.TodoItem)
+	var start, due, completed, archived, deleted sql.NullTime
+	var list, parent, series sql.NullInt64
+	var checklist, recurrence sql.NullString
+	err := rows.Scan(
+		&item.ID,
+		&item.Title,
+		&item.Description,
+		&item.Done,
+		&item.OwnerID,
+		&item.Created,
+		&start,
+		&due,
+		&item.Priority,
+		&item.Position,
+		&list,
+		&parent,
+		&checklist,
+		&recurrence,
+		&series,
+		&completed,
+		&archived,
+		&deleted,
+		&item.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	item.Start = timeOrNil(start)
+	item.Due = timeOrNil(due)
+	item.ListID = int(list.Int64)
+	item.ParentID = int(parent.Int64)
+	item.Recurrence = recurrence.String
+	item.SeriesID = int(series.Int64)
+	item.Completed = timeOrNil(completed)
+	item.Archived = timeOrNil(archived)
+	item.Deleted = timeOrNil(deleted)
+	if checklist.Valid {
+		if err := json.Unmarshal([]byte(checklist.String), &item.Checklist); err != nil {
+			return nil, err
+		}
+	}
+
+	return item, nil
+}
+
+// checklistValue stores a checklist as a JSON array, and no checklist as null.
+func checklistValue(checklist []known.ChecklistEntry) (any, error) {
+	if len(checklist) == 0 {
+		return nil, nil
+	}
+
+	encoded, err := json.Marshal(checklist)
+	if err != nil {
+		return nil, err
+	}
+
+	return string(encoded), nil
+}
+
+// nullableID passes a zero reference as null.
+func nullableID(id int) any {
+	if id == 0 {
+		return nil
+	}
+
+	return id
+}
+
+// nullableText passes an empty text as null.
+func nullableText(text string) any {
+	if text == "" {
+		return nil
+	}
+
+	return text
+}
+
+// nullableTime passes an optional time in UTC, which both backends compare correctly.
+func nullableTime(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+
+	return t.UTC()
+}
+
+func timeOrNil(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+
+	utc := t.Time.UTC()
+	return &utc
+}
+
+func (s *PostgresStorage) CreateUser(user *known.User) error {
+	query := `insert into users (name, username, password_hash, time_zone) values ($1, $2, $3, $4) returning id`
+
+	err := s.DB.QueryRow(
+		query,
+		user.Name,
+		user.Username,
+		user.PasswordHash,
+		user.TimeZone,
+	).Scan(&user.ID)
+
+	if isUniqueViolation(err) {
+		return ErrUsernameTaken
+	}
+
+	return err
+}
+
+func (s *PostgresStorage) GetUser(id int) (*known.User, error) {
+	row := s.DB.QueryRow("select "+userColumns+" from users where id = $1", id)
+	return scanUser(row)
+}
+
+func (s *PostgresStorage) GetUserByUsername(username string) (*known.User, error) {
+	row := s.DB.QueryRow("select "+userColumns+" from users where username = $1", username)
+	return scanUser(row)
+}
+
+func (s *PostgresStorage) UpdateUser(user *known.User) error {
+	result, err := s.DB.Exec(
+		"update users set name = $1, time_zone = $2, email = $3 where id = $4",
+		user.Name,
+		user.TimeZone,
+		nullableText(user.Email),
+		user.ID,
+	)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err == nil && affected == 0 {
+		return ErrNoUser
+	}
+
+	return err
+}
+
+const userColumns = "id, name, username, password_hash, time_zone, email"
+
+func scanUser(row *sql.Row) (*known.User, error) {
+	user := new(known.User)
+	var email sql.NullString
+	err := row.Scan(
+		&user.ID,
+		&user.Name,
+		&user.Username,
+		&user.PasswordHash,
+		&user.TimeZone,
+		&email)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNoUser
+	}
+	if err != nil {
+		return nil, err
+	}
+	user.Email = email.String
+
+	return user, nil
+}
+
+func (s *PostgresStorage) SaveRefreshToken(token *known.RefreshToken) error {
+	_, err := s.DB.Exec(
+		"insert into refresh_tokens (token_hash, user_id, family, expires_at) values ($1, $2, $3, $4)",
+		token.Hash,
+		token.UserID,
+		token.Family,
+		token.ExpiresAt.UTC(),
+	)
+	return err
+}
+
+func (s *PostgresStorage) GetRefreshToken(hash string) (*known.RefreshToken, error) {
+	token := known.RefreshToken{Hash: hash}
+	err := s.DB.QueryRow(
+		"select user_id, family, expires_at, revoked_at is not null from refresh_tokens where token_hash = $1",
+		hash,
+	).Scan(&token.UserID, &token.Family, &token.ExpiresAt, &token.Revoked)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNoToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+func (s *PostgresStorage) RevokeRefreshToken(hash string) (bool, error) {
+	result, err := s.DB.Exec(
+		"update refresh_tokens set revoked_at = $2 where token_hash = $1 and revoked_at is null",
+		hash,
+		time.Now().UTC(),
+	)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	return affected == 1, err
+}
+
+func (s *PostgresStorage) RevokeTokenFamily(family string) error {
+	_, err := s.DB.Exec(
+		"update refresh_tokens set revoked_at = $2 where family = $1 and revoked_at is null",
+		family,
+		time.Now().UTC(),
+	)
+	return err
+}
+
+func (s *PostgresStorage) RevokeAccessToken(id string, expiresAt time.Time) error {
+	if _, err := s.DB.Exec("delete from revoked_tokens where expires_at < $1", time.Now().UTC()); err != nil {
+		return err
+	}
+
+	_, err := s.DB.Exec(
+		"insert into revoked_tokens (token_id, expires_at) values ($1, $2) on conflict do nothing",
+		id,
+		expiresAt.UTC(),
+	)
+	return err
+}
+
+func (s *PostgresStorage) IsAccessTokenRevoked(id string) (bool, error) {
+	var revoked bool
+	err := s.DB.QueryRow("select exists(select 1 from revoked_tokens where token_id = $1)", id).Scan(&revoked)
+	return revoked, err
+}
+
+// ClaimIdempotencyKey inserts the request unless its key is taken, so of two concurrent claims one wins.
+func (s *PostgresStorage) ClaimIdempotencyKey(request *known.IdempotentRequest, now time.Time) (*known.IdempotentRequest, error) {
+	if _, err := s.DB.Exec("delete from idempotency_keys where expires_at <= $1", now.UTC()); err != nil {
+		return nil, err
+	}
+
+	result, err := s.DB.Exec(
+		"insert into idempotency_keys (owner_id, idempotency_key, fingerprint, expires_at) values ($1, $2, $3, $4) on conflict do nothing",
+		request.OwnerID, request.Key, request.Fingerprint, request.ExpiresAt.UTC(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 1 {
+		return nil, err
+	}
+
+	kept := known.IdempotentRequest{OwnerID: request.OwnerID, Key: request.Key}
+	var header sql.NullString
+	err = s.DB.QueryRow(
+		"select fingerprint, status, headers, body, expires_at from idempotency_keys where owner_id = $1 and idempotency_key = $2",
+		request.OwnerID, request.Key,
+	).Scan(&kept.Fingerprint, &kept.Status, &header, &kept.Body, &kept.ExpiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		// released right after the insert lost; reported as still being processed, the retry claims it
+		kept.Fingerprint = request.Fingerprint
+		return &kept, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if header.Valid {
+		if err := json.Unmarshal([]byte(header.String), &kept.Header); err != nil {
+			return nil, err
+		}
+	}
+
+	return &kept, nil
+}
+
+func (s *PostgresStorage) SaveIdempotentResponse(request *known.IdempotentRequest) error {
+	header, err := json.Marshal(request.Header)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.DB.Exec(
+		"update idempotency_keys set status = $3, headers = $4, body = $5 where owner_id = $1 and idempotency_key = $2",
+		request.OwnerID, request.Key, request.Status, string(header), request.Body,
+	)
+	return err
+}
+
+func (s *PostgresStorage) ReleaseIdempotencyKey(owner int, key string) error {
+	_, err := s.DB.Exec("delete from idempotency_keys where owner_id = $1 and idempotency_key = $2", owner, key)
+	return err
 }
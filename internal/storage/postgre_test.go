@@ -1,13 +0,0 @@
-package storage
-
-import (
-	"os"
-	"testing"
-)
-
-func TestConfig(t *testing.T) {
-	os.Setenv("DB_HOST", "test")
-	os.Setenv("DB_USER", "test")
-	os.Setenv("DB_PASS", "test")
-	getConfig()
-}
@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/scriptdealer/to-do-go/known"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sqlStoresForTest are the SQL backends to race transactions against: SQLite always,
+// Postgres when TODO_TEST_POSTGRES holds the DSN of a database the tests may write to.
+func sqlStoresForTest(t *testing.T) map[string]*PostgresStorage {
+	t.Helper()
+
+	stores := map[string]*PostgresStorage{"sqlite": &newSQLiteForTest(t).PostgresStorage}
+	dsn := os.Getenv("TODO_TEST_POSTGRES")
+	if dsn == "" {
+		t.Log("TODO_TEST_POSTGRES is not set, Postgres is left out")
+		return stores
+	}
+
+	store, err := NewPostgresStore(dsn, slog.New(slog.NewJSONHandler(os.Stdout, nil)))
+	require.NoError(t, err)
+	t.Cleanup(func() { store.DB.Close() })
+	stores["postgres"] = store
+
+	return stores
+}
+
+// newOwnerForTest signs up a user of its own, the Postgres database outlives the test.
+func newOwnerForTest(t *testing.T, store *PostgresStorage) int {
+	t.Helper()
+
+	username := "racer" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	owner := known.User{Name: "Racer", Username: username, PasswordHash: "x"}
+	require.NoError(t, store.CreateUser(&owner))
+
+	return owner.ID
+}
+
+func TestConcurrentMoves(t *testing.T) {
+	for name, store := range sqlStoresForTest(t) {
+		t.Run(name, func(t *testing.T) {
+			owner := newOwnerForTest(t, store)
+			ids := []int{}
+			for i := 0; i < 10; i++ {
+				item := known.TodoItem{OwnerID: owner, Title: strconv.Itoa(i)}
+				require.NoError(t, store.Create(&item))
+				ids = append(ids, item.ID)
+			}
+
+			// every move reads the same anchor and neighbour unless they wait for each other
+			var wg sync.WaitGroup
+			for _, id := range ids[2:] {
+				wg.Add(1)
+				go func(id int) {
+					defer wg.Done()
+					assert.NoError(t, store.Move(owner, id, ids[0], false))
+				}(id)
+			}
+			wg.Wait()
+
+			items, err := store.GetAll(context.Background(), owner, known.TodoQuery{})
+			require.NoError(t, err)
+			positions := map[float64]bool{}
+			for _, item := range items {
+				positions[item.Position] = true
+			}
+			assert.Len(t, positions, len(ids), "no two items share a position")
+			assert.Equal(t, ids[0], items[0].ID)
+			assert.Equal(t, ids[1], items[len(items)-1].ID)
+		})
+	}
+}
+
+func TestConcurrentBlockers(t *testing.T) {
+	for name, store := range sqlStoresForTest(t) {
+		t.Run(name, func(t *testing.T) {
+			owner := newOwnerForTest(t, store)
+			for round := 0; round < 10; round++ {
+				a := known.TodoItem{OwnerID: owner, Title: "a"}
+				b := known.TodoItem{OwnerID: owner, Title: "b"}
+				require.NoError(t, store.Create(&a))
+				require.NoError(t, store.Create(&b))
+
+				// each edge alone is fine, together they close a cycle
+				errs := make([]error, 2)
+				var wg sync.WaitGroup
+				wg.Add(2)
+				go func() { defer wg.Done(); errs[0] = store.AddBlocker(owner, a.ID, b.ID) }()
+				go func() { defer wg.Done(); errs[1] = store.AddBlocker(owner, b.ID, a.ID) }()
+				wg.Wait()
+
+				refused := 0
+				for _, err := range errs {
+					if err != nil {
+						assert.ErrorIs(t, err, ErrBlockerCycle)
+						refused++
+					}
+				}
+				assert.Equal(t, 1, refused, "round %d", round)
+			}
+		})
+	}
+}
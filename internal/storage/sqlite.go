@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"database/sql"
+	"log/slog"
+	"net/url"
+
+	_ "modernc.org/sqlite" // pure Go driver, the image is built without cgo
+)
+
+// SQLiteStorage runs the same queries as PostgresStorage against a SQLite file;
+// they are written to be portable, and the few differences are switched on the dialect.
+type SQLiteStorage struct {
+	PostgresStorage
+}
+
+func init() {
+	Register("sqlite", func(dsn *url.URL, logger *slog.Logger) (Backend, error) {
+		// sqlite:relative.db and sqlite::memory: are opaque, sqlite:///abs/todo.db has a path
+		path := dsn.Opaque
+		if path == "" {
+			path = dsn.Host + dsn.Path
+		}
+		return OpenSQLite(path, logger)
+	})
+}
+
+// NewSQLiteStore opens (or creates) the database file at path and brings its schema up to date.
+func NewSQLiteStore(path string, logger *slog.Logger) (*SQLiteStorage, error) {
+	store, err := OpenSQLite(path, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := store.Init(); err != nil {
+		logger.Info("DB init failed", slog.String("reason", err.Error()))
+		return nil, err
+	}
+
+	return store, nil
+}
+
+// OpenSQLite only opens the database file, leaving the schema as it is.
+func OpenSQLite(path string, logger *slog.Logger) (*SQLiteStorage, error) {
+	logger.Info("SQLite storage selected", slog.String("path", path))
+
+	params := url.Values{}
+	params.Add("_pragma", "foreign_keys(1)")
+	params.Add("_pragma", "busy_timeout(5000)")
+	params.Add("_pragma", "journal_mode(WAL)")
+	params.Add("_time_format", "sqlite")
+
+	db, err := sql.Open("sqlite", "file:"+path+"?"+params.Encode())
+	if err != nil {
+		return nil, err
+	}
+
+	// SQLite allows a single writer, and an in-memory database lives in one connection.
+	db.SetMaxOpenConns(1)
+
+	if err := db.Ping(); err != nil {
+		logger.Info("DB open failed", slog.String("reason", err.Error()))
+		return nil, err
+	}
+
+	return &SQLiteStorage{
+		PostgresStorage: PostgresStorage{
+			DB:      db,
+			dialect: dialectSQLite,
+			Log:     logger,
+		},
+	}, nil
+}
@@ -0,0 +1,884 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/scriptdealer/to-do-go/known"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newSQLiteForTest(t *testing.T) *SQLiteStorage {
+	t.Helper()
+
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	store, err := NewSQLiteStore(filepath.Join(t.TempDir(), "todo.db"), logger)
+	require.NoError(t, err)
+	t.Cleanup(func() { store.DB.Close() })
+
+	return store
+}
+
+func TestSQLite_Migrations(t *testing.T) {
+	store := newSQLiteForTest(t)
+	migrator, err := store.Migrator()
+	require.NoError(t, err)
+
+	statuses, err := migrator.Status(context.Background())
+	require.NoError(t, err)
+	for _, status := range statuses {
+		assert.NotNil(t, status.AppliedAt, "migration %d should be applied", status.Version)
+	}
+
+	reverted, err := migrator.Down(context.Background(), len(statuses))
+	require.NoError(t, err)
+	assert.Equal(t, len(statuses), reverted)
+
+	applied, err := migrator.Up(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, len(statuses), applied)
+}
+
+func TestSQLite_Todos(t *testing.T) {
+	store := newSQLiteForTest(t)
+	ctx := context.Background()
+
+	owner := known.User{Name: "Jane", Username: "jane", PasswordHash: "x"}
+	require.NoError(t, store.CreateUser(&owner))
+	assert.ErrorIs(t, store.CreateUser(&known.User{Username: "jane", PasswordHash: "y"}), known.ErrConflict)
+
+	created := known.TodoItem{OwnerID: owner.ID, Title: "1st", Description: "first"}
+	require.NoError(t, store.Create(&created))
+	items, err := store.GetAll(ctx, owner.ID, known.TodoQuery{})
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	assert.Equal(t, items[0].ID, created.ID)
+	assert.Equal(t, items[0].Position, created.Position, "the position is read back")
+
+	item := items[0]
+	item.Done = true
+	require.NoError(t, store.Update(item))
+
+	got, err := store.GetOne(owner.ID, item.ID)
+	require.NoError(t, err)
+	assert.True(t, got.Done)
+
+	_, err = store.GetOne(owner.ID+1, item.ID)
+	assert.ErrorIs(t, err, ErrNoItem)
+	assert.ErrorIs(t, err, known.ErrNotFound)
+	assert.ErrorIs(t, store.Delete(owner.ID+1, item.ID, false, 0), ErrNoItem)
+	assert.NoError(t, store.Delete(owner.ID, item.ID, false, 0))
+}
+
+func TestSQLite_TodoQuery(t *testing.T) {
+	store := newSQLiteForTest(t)
+	ctx := context.Background()
+
+	owner := known.User{Name: "Jane", Username: "jane", PasswordHash: "x"}
+	require.NoError(t, store.CreateUser(&owner))
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, title := range []string{"milk", "bread", "100% juice", "eggs"} {
+		item := known.TodoItem{OwnerID: owner.ID, Title: title, Description: "shop", Done: i%2 == 0, Created: start.Add(time.Duration(i) * time.Hour)}
+		require.NoError(t, store.Create(&item))
+	}
+
+	titles := func(query known.TodoQuery) []string {
+		items, err := store.GetAll(ctx, owner.ID, query)
+		require.NoError(t, err)
+		result := []string{}
+		for _, item := range items {
+			result = append(result, item.Title)
+		}
+		return result
+	}
+
+	done := true
+	assert.Equal(t, []string{"100% juice", "bread", "eggs", "milk"}, titles(known.TodoQuery{SortBy: known.SortByTitle}))
+	assert.Equal(t, []string{"eggs", "100% juice"}, titles(known.TodoQuery{SortBy: known.SortByCreated, Desc: true, Limit: 2}))
+	assert.Equal(t, []string{"bread"}, titles(known.TodoQuery{Limit: 1, After: &known.PageCursor{SortBy: known.SortByPosition, Key: 1.0, ID: 1}}))
+	assert.Equal(t, []string{"eggs", "milk"}, titles(known.TodoQuery{SortBy: known.SortByTitle, After: &known.PageCursor{SortBy: known.SortByTitle, Key: "bread", ID: 2}}))
+	assert.Equal(t, []string{"bread", "milk"}, titles(known.TodoQuery{SortBy: known.SortByCreated, Desc: true, After: &known.PageCursor{
+		SortBy: known.SortByCreated, Desc: true, Key: start.Add(2 * time.Hour), ID: 3,
+	}}))
+	assert.Equal(t, []string{"100% juice", "eggs"}, titles(known.TodoQuery{SortBy: known.SortByDue, After: &known.PageCursor{SortBy: known.SortByDue, ID: 2}}))
+	assert.Equal(t, []string{"milk", "100% juice"}, titles(known.TodoQuery{Done: &done}))
+	assert.Equal(t, []string{"100% juice"}, titles(known.TodoQuery{Text: "0%"}))
+	assert.Empty(t, titles(known.TodoQuery{Text: "_"}))
+}
+
+func TestSQLite_DueDates(t *testing.T) {
+	store := newSQLiteForTest(t)
+	ctx := context.Background()
+
+	owner := known.User{Name: "Jane", Username: "jane", PasswordHash: "x", TimeZone: "UTC"}
+	require.NoError(t, store.CreateUser(&owner))
+	owner.TimeZone = "Europe/Berlin"
+	require.NoError(t, store.UpdateUser(&owner))
+	stored, err := store.GetUser(owner.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Europe/Berlin", stored.TimeZone)
+
+	noon := time.Date(2024, 5, 6, 12, 0, 0, 0, time.UTC)
+	for i, title := range []string{"someday", "yesterday", "today", "tomorrow"} {
+		item := known.TodoItem{OwnerID: owner.ID, Title: title, Created: noon}
+		if i > 0 {
+			due := noon.AddDate(0, 0, i-2)
+			item.Due = &due
+			item.Start = &noon
+		}
+		require.NoError(t, store.Create(&item))
+	}
+
+	titles := func(query known.TodoQuery) []string {
+		items, err := store.GetAll(ctx, owner.ID, query)
+		require.NoError(t, err)
+		result := []string{}
+		for _, item := range items {
+			result = append(result, item.Title)
+		}
+		return result
+	}
+
+	from, before := noon.Add(-time.Hour), noon.Add(time.Hour)
+	assert.Equal(t, []string{"today"}, titles(known.TodoQuery{DueFrom: &from, DueBefore: &before}))
+	assert.Equal(t, []string{"yesterday", "today"}, titles(known.TodoQuery{DueBefore: &before}))
+	assert.Equal(t, []string{"yesterday", "today", "tomorrow", "someday"}, titles(known.TodoQuery{SortBy: known.SortByDue}))
+	assert.Equal(t, []string{"tomorrow", "today", "yesterday", "someday"}, titles(known.TodoQuery{SortBy: known.SortByDue, Desc: true}))
+
+	items, err := store.GetAll(ctx, owner.ID, known.TodoQuery{DueFrom: &from, DueBefore: &before})
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	assert.True(t, noon.Equal(*items[0].Start))
+	assert.True(t, noon.Equal(*items[0].Due))
+
+	items[0].Due = nil
+	require.NoError(t, store.Update(items[0]))
+	got, err := store.GetOne(owner.ID, items[0].ID)
+	require.NoError(t, err)
+	assert.Nil(t, got.Due)
+}
+
+func TestSQLite_Move(t *testing.T) {
+	store := newSQLiteForTest(t)
+	ctx := context.Background()
+
+	owner := known.User{Name: "Jane", Username: "jane", PasswordHash: "x"}
+	require.NoError(t, store.CreateUser(&owner))
+	for i, title := range []string{"a", "b", "c", "d"} {
+		require.NoError(t, store.Create(&known.TodoItem{OwnerID: owner.ID, Title: title, Priority: known.Priority(i % 3)}))
+	}
+
+	items := func(query known.TodoQuery) ([]string, []float64) {
+		listed, err := store.GetAll(ctx, owner.ID, query)
+		require.NoError(t, err)
+		titles, positions := []string{}, []float64{}
+		for _, item := range listed {
+			titles = append(titles, item.Title)
+			positions = append(positions, item.Position)
+		}
+		return titles, positions
+	}
+
+	titles, positions := items(known.TodoQuery{})
+	assert.Equal(t, []string{"a", "b", "c", "d"}, titles)
+	assert.Equal(t, []float64{1, 2, 3, 4}, positions)
+
+	require.NoError(t, store.Move(owner.ID, 4, 1, false))
+	require.NoError(t, store.Move(owner.ID, 1, 3, false))
+	titles, positions = items(known.TodoQuery{})
+	assert.Equal(t, []string{"d", "b", "c", "a"}, titles)
+	assert.Equal(t, []float64{1.5, 2, 3, 4}, positions, "only the moved items change")
+
+	require.NoError(t, store.Move(owner.ID, 1, 4, true))
+	titles, _ = items(known.TodoQuery{})
+	assert.Equal(t, []string{"a", "d", "b", "c"}, titles)
+
+	titles, _ = items(known.TodoQuery{SortBy: known.SortByPriority, Desc: true})
+	assert.Equal(t, []string{"c", "b", "d", "a"}, titles)
+
+	// squeeze b and c together, the next move between them renumbers the list
+	_, err := store.DB.Exec("update todos set position = $1 where id = 3", math.Nextafter(2, 3))
+	require.NoError(t, err)
+	require.NoError(t, store.Move(owner.ID, 1, 2, false))
+	titles, positions = items(known.TodoQuery{})
+	assert.Equal(t, []string{"d", "b", "a", "c"}, titles)
+	assert.Equal(t, []float64{2, 3, 3.5, 4}, positions)
+
+	assert.ErrorIs(t, store.Move(owner.ID+1, 1, 2, false), ErrNoItem)
+	assert.ErrorIs(t, store.Move(owner.ID, 1, 42, false), ErrNoItem)
+
+	// trashed items leave no gap to move into
+	require.NoError(t, store.Delete(owner.ID, 2, false, 0))
+	require.NoError(t, store.Move(owner.ID, 3, 4, false))
+	titles, positions = items(known.TodoQuery{})
+	assert.Equal(t, []string{"d", "c", "a"}, titles)
+	assert.Equal(t, []float64{2, 2.75, 3.5}, positions)
+}
+
+func TestSQLite_Tags(t *testing.T) {
+	store := newSQLiteForTest(t)
+	ctx := context.Background()
+
+	owner := known.User{Name: "Jane", Username: "jane", PasswordHash: "x"}
+	require.NoError(t, store.CreateUser(&owner))
+	for title, tags := range map[string][]string{"a": {"home", "work"}, "b": {"work"}, "c": {"home"}, "d": nil} {
+		require.NoError(t, store.Create(&known.TodoItem{OwnerID: owner.ID, Title: title, Tags: tags}))
+	}
+
+	titles := func(query known.TodoQuery) []string {
+		query.SortBy = known.SortByTitle
+		listed, err := store.GetAll(ctx, owner.ID, query)
+		require.NoError(t, err)
+		titles := []string{}
+		for _, item := range listed {
+			titles = append(titles, item.Title)
+		}
+		return titles
+	}
+	counts := func() map[string]int {
+		tags, err := store.GetTags(owner.ID)
+		require.NoError(t, err)
+		counts := map[string]int{}
+		for _, tag := range tags {
+			counts[tag.Name] = tag.Items
+		}
+		return counts
+	}
+
+	assert.Equal(t, []string{"a"}, titles(known.TodoQuery{Tags: []string{"home", "work"}}))
+	assert.Equal(t, []string{"a", "b", "c"}, titles(known.TodoQuery{Tags: []string{"home", "work"}, AnyTag: true}))
+	assert.Equal(t, map[string]int{"home": 2, "work": 2}, counts())
+
+	listed, err := store.GetAll(ctx, owner.ID, known.TodoQuery{Tags: []string{"home"}, Desc: true, SortBy: known.SortByTitle})
+	require.NoError(t, err)
+	require.Len(t, listed, 2)
+	assert.Equal(t, []string{"home"}, listed[0].Tags)
+	assert.Equal(t, []string{"home", "work"}, listed[1].Tags)
+
+	errands := known.Tag{OwnerID: owner.ID, Name: "errands"}
+	require.NoError(t, store.CreateTag(&errands))
+	assert.ErrorIs(t, store.CreateTag(&known.Tag{OwnerID: owner.ID, Name: "home"}), ErrTagTaken)
+	assert.ErrorIs(t, store.RenameTag(owner.ID, errands.ID, "work"), ErrTagTaken)
+	require.NoError(t, store.RenameTag(owner.ID, errands.ID, "chores"))
+	assert.Equal(t, map[string]int{"chores": 0, "home": 2, "work": 2}, counts())
+
+	tags, err := store.GetTags(owner.ID)
+	require.NoError(t, err)
+	ids := map[string]int{}
+	for _, tag := range tags {
+		ids[tag.Name] = tag.ID
+	}
+	require.NoError(t, store.MergeTags(owner.ID, ids["home"], ids["work"]))
+	assert.Equal(t, map[string]int{"chores": 0, "work": 3}, counts())
+	assert.ErrorIs(t, store.MergeTags(owner.ID, ids["home"], ids["work"]), ErrNoTag)
+	assert.ErrorIs(t, store.MergeTags(owner.ID+1, ids["chores"], ids["work"]), ErrNoTag)
+
+	require.NoError(t, store.DeleteTag(owner.ID, ids["work"]))
+	assert.ErrorIs(t, store.DeleteTag(owner.ID, ids["work"]), ErrNoTag)
+	assert.Equal(t, map[string]int{"chores": 0}, counts())
+	assert.Equal(t, []string{"a", "b", "c", "d"}, titles(known.TodoQuery{}), "items outlive their tags")
+
+	item, err := store.GetOne(owner.ID, 1)
+	require.NoError(t, err)
+	item.Tags = []string{"chores", "later"}
+	require.NoError(t, store.Update(item))
+	item, err = store.GetOne(owner.ID, item.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"chores", "later"}, item.Tags)
+}
+
+func TestSQLite_Lists(t *testing.T) {
+	store := newSQLiteForTest(t)
+	ctx := context.Background()
+
+	owner := known.User{Name: "Jane", Username: "jane", PasswordHash: "x"}
+	require.NoError(t, store.CreateUser(&owner))
+	other := known.User{Name: "John", Username: "john", PasswordHash: "x"}
+	require.NoError(t, store.CreateUser(&other))
+
+	garden := known.List{OwnerID: owner.ID, Name: "Garden"}
+	require.NoError(t, store.CreateList(&garden))
+	house := known.List{OwnerID: owner.ID, Name: "House"}
+	require.NoError(t, store.CreateList(&house))
+	foreign := known.List{OwnerID: other.ID, Name: "Garden"}
+	require.NoError(t, store.CreateList(&foreign), "names are unique per owner")
+	assert.ErrorIs(t, store.CreateList(&known.List{OwnerID: owner.ID, Name: "Garden"}), ErrListTaken)
+
+	for _, item := range []known.TodoItem{
+		{Title: "a", ListID: garden.ID},
+		{Title: "b", ListID: garden.ID, Done: true},
+		{Title: "c", ListID: house.ID},
+		{Title: "d"},
+		{Title: "e", ListID: foreign.ID},
+	} {
+		item.OwnerID = owner.ID
+		require.NoError(t, store.Create(&item))
+	}
+
+	titles := func(query known.TodoQuery) []string {
+		listed, err := store.GetAll(ctx, owner.ID, query)
+		require.NoError(t, err)
+		titles := []string{}
+		for _, item := range listed {
+			titles = append(titles, item.Title)
+		}
+		return titles
+	}
+
+	assert.Equal(t, []string{"a", "b"}, titles(known.TodoQuery{ListID: garden.ID}))
+	assert.Equal(t, []string{"a", "b", "c", "d", "e"}, titles(known.TodoQuery{}))
+	item, err := store.GetOne(owner.ID, 5)
+	require.NoError(t, err)
+	assert.Zero(t, item.ListID, "items only go into lists of their owner")
+
+	lists, err := store.GetLists(owner.ID, false)
+	require.NoError(t, err)
+	assert.Equal(t, []*known.List{
+		{ID: garden.ID, OwnerID: owner.ID, Name: "Garden", Open: 1, Done: 1},
+		{ID: house.ID, OwnerID: owner.ID, Name: "House", Open: 1},
+	}, lists)
+
+	require.NoError(t, store.MoveToList(owner.ID, 3, garden.ID))
+	require.NoError(t, store.MoveToList(owner.ID, 1, 0))
+	assert.ErrorIs(t, store.MoveToList(owner.ID, 1, foreign.ID), ErrNoList)
+	assert.ErrorIs(t, store.MoveToList(other.ID, 1, foreign.ID), ErrNoItem)
+	assert.Equal(t, []string{"b", "c"}, titles(known.TodoQuery{ListID: garden.ID}))
+
+	require.NoError(t, store.ArchiveList(owner.ID, garden.ID, true))
+	assert.Equal(t, []string{"a", "d", "e"}, titles(known.TodoQuery{}), "archived lists are hidden")
+	assert.Equal(t, []string{"b", "c"}, titles(known.TodoQuery{ListID: garden.ID}))
+	lists, err = store.GetLists(owner.ID, false)
+	require.NoError(t, err)
+	assert.Len(t, lists, 1)
+	lists, err = store.GetLists(owner.ID, true)
+	require.NoError(t, err)
+	assert.Len(t, lists, 2)
+
+	assert.ErrorIs(t, store.RenameList(owner.ID, house.ID, "Garden"), ErrListTaken)
+	require.NoError(t, store.RenameList(owner.ID, house.ID, "Flat"))
+	assert.ErrorIs(t, store.RenameList(other.ID, house.ID, "Mine"), ErrNoList)
+	list, err := store.GetList(owner.ID, house.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Flat", list.Name)
+
+	require.NoError(t, store.DeleteList(owner.ID, garden.ID))
+	assert.ErrorIs(t, store.DeleteList(owner.ID, garden.ID), ErrNoList)
+	_, err = store.GetList(owner.ID, garden.ID)
+	assert.ErrorIs(t, err, ErrNoList)
+	assert.Equal(t, []string{"a", "b", "c", "d", "e"}, titles(known.TodoQuery{}), "items of a deleted list go back to the inbox")
+	item, err = store.GetOne(owner.ID, 2)
+	require.NoError(t, err)
+	assert.Zero(t, item.ListID)
+}
+
+func TestSQLite_Subtasks(t *testing.T) {
+	store := newSQLiteForTest(t)
+	ctx := context.Background()
+
+	owner := known.User{Name: "Jane", Username: "jane", PasswordHash: "x"}
+	require.NoError(t, store.CreateUser(&owner))
+	garden := known.List{OwnerID: owner.ID, Name: "Garden"}
+	require.NoError(t, store.CreateList(&garden))
+
+	root := known.TodoItem{OwnerID: owner.ID, Title: "root", ListID: garden.ID, Checklist: []known.ChecklistEntry{{Text: "gloves", Done: true}, {Text: "seeds"}}}
+	require.NoError(t, store.Create(&root))
+	parent := root.ID
+	for depth := 2; depth <= MaxDepth; depth++ {
+		item := known.TodoItem{OwnerID: owner.ID, Title: strconv.Itoa(depth), ParentID: parent, Done: depth == 2}
+		require.NoError(t, store.Create(&item))
+		assert.Equal(t, garden.ID, item.ListID, "subtasks go into the list of their parent")
+		parent = item.ID
+	}
+	assert.ErrorIs(t, store.Create(&known.TodoItem{OwnerID: owner.ID, Title: "too deep", ParentID: parent}), ErrTooDeep)
+	assert.ErrorIs(t, store.Create(&known.TodoItem{OwnerID: owner.ID, Title: "orphan", ParentID: 42}), ErrNoItem)
+
+	sibling := known.TodoItem{OwnerID: owner.ID, Title: "sibling", ParentID: root.ID}
+	require.NoError(t, store.Create(&sibling))
+
+	got, err := store.GetOne(owner.ID, root.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []known.ChecklistEntry{{Text: "gloves", Done: true}, {Text: "seeds"}}, got.Checklist)
+	assert.Equal(t, &known.Progress{Done: 2, Total: 4}, got.Progress)
+
+	children, err := store.GetAll(ctx, owner.ID, known.TodoQuery{ParentID: root.ID})
+	require.NoError(t, err)
+	require.Len(t, children, 2)
+	assert.Equal(t, "2", children[0].Title)
+	assert.Equal(t, &known.Progress{Done: 0, Total: 1}, children[0].Progress)
+	assert.Nil(t, children[1].Progress)
+
+	assert.ErrorIs(t, store.SetParent(owner.ID, root.ID, parent), ErrSubtaskCycle)
+	assert.ErrorIs(t, store.SetParent(owner.ID, children[0].ID, sibling.ID), ErrTooDeep)
+	assert.ErrorIs(t, store.MoveToList(owner.ID, sibling.ID, 0), ErrSubtaskList)
+
+	// the deepest subtask becomes a top level item in the inbox and back
+	require.NoError(t, store.SetParent(owner.ID, parent, 0))
+	require.NoError(t, store.MoveToList(owner.ID, parent, 0))
+	require.NoError(t, store.SetParent(owner.ID, parent, sibling.ID))
+	got, err = store.GetOne(owner.ID, parent)
+	require.NoError(t, err)
+	assert.Equal(t, sibling.ID, got.ParentID)
+	assert.Equal(t, garden.ID, got.ListID, "a subtask follows its parent into the list")
+
+	require.NoError(t, store.MoveToList(owner.ID, root.ID, 0))
+	inList, err := store.GetAll(ctx, owner.ID, known.TodoQuery{ListID: garden.ID})
+	require.NoError(t, err)
+	assert.Empty(t, inList, "the whole subtree leaves the list")
+
+	require.NoError(t, store.CompleteSubtasks(owner.ID, root.ID))
+	open, err := store.GetAll(ctx, owner.ID, known.TodoQuery{Done: new(bool)})
+	require.NoError(t, err)
+	require.Len(t, open, 1)
+	assert.Equal(t, "root", open[0].Title)
+
+	assert.ErrorIs(t, store.Delete(owner.ID, root.ID, false, 0), ErrHasSubtasks)
+	require.NoError(t, store.Delete(owner.ID, root.ID, true, 0))
+	all, err := store.GetAll(ctx, owner.ID, known.TodoQuery{})
+	require.NoError(t, err)
+	assert.Empty(t, all)
+}
+
+func TestSQLite_Blockers(t *testing.T) {
+	store := newSQLiteForTest(t)
+
+	owner := known.User{Name: "Jane", Username: "jane", PasswordHash: "x"}
+	require.NoError(t, store.CreateUser(&owner))
+	other := known.User{Name: "John", Username: "john", PasswordHash: "x"}
+	require.NoError(t, store.CreateUser(&other))
+	for _, title := range []string{"a", "b", "c", "d"} {
+		require.NoError(t, store.Create(&known.TodoItem{OwnerID: owner.ID, Title: title}))
+	}
+	foreign := known.TodoItem{OwnerID: other.ID, Title: "e"}
+	require.NoError(t, store.Create(&foreign))
+
+	// a blocks b blocks c
+	require.NoError(t, store.AddBlocker(owner.ID, 2, 1))
+	require.NoError(t, store.AddBlocker(owner.ID, 3, 2))
+	require.NoError(t, store.AddBlocker(owner.ID, 3, 2), "an existing edge is a no-op")
+	assert.ErrorIs(t, store.AddBlocker(owner.ID, 1, 3), ErrBlockerCycle)
+	assert.ErrorIs(t, store.AddBlocker(owner.ID, 1, 1), ErrBlockerCycle)
+	assert.ErrorIs(t, store.AddBlocker(owner.ID, 1, foreign.ID), ErrNoItem)
+	require.NoError(t, store.AddBlocker(owner.ID, 3, 4))
+
+	blockers, err := store.GetBlockers(owner.ID, 3)
+	require.NoError(t, err)
+	require.Len(t, blockers, 2)
+	assert.Equal(t, "b", blockers[0].Title)
+	assert.Equal(t, "d", blockers[1].Title)
+	_, err = store.GetBlockers(other.ID, 3)
+	assert.ErrorIs(t, err, ErrNoItem)
+
+	a, err := store.GetOne(owner.ID, 1)
+	require.NoError(t, err)
+	a.Done = true
+	require.NoError(t, store.Update(a))
+	edges, err := store.GetDependencies(owner.ID)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []known.Dependency{{ItemID: 3, BlockerID: 2}, {ItemID: 3, BlockerID: 4}}, edges, "done items leave the graph")
+
+	assert.ErrorIs(t, store.RemoveBlocker(other.ID, 3, 4), ErrNoBlocker)
+	require.NoError(t, store.RemoveBlocker(owner.ID, 3, 4))
+	assert.ErrorIs(t, store.RemoveBlocker(owner.ID, 3, 4), ErrNoBlocker)
+
+	require.NoError(t, store.Delete(owner.ID, 2, false, 0))
+	blockers, err = store.GetBlockers(owner.ID, 3)
+	require.NoError(t, err)
+	assert.Empty(t, blockers, "deleting an item drops its edges")
+}
+
+func TestSQLite_Recurrence(t *testing.T) {
+	store := newSQLiteForTest(t)
+	ctx := context.Background()
+
+	owner := known.User{Name: "Jane", Username: "jane", PasswordHash: "x"}
+	require.NoError(t, store.CreateUser(&owner))
+	first := known.TodoItem{OwnerID: owner.ID, Title: "water", Recurrence: "FREQ=DAILY"}
+	require.NoError(t, store.Create(&first))
+	first.Done = true
+	first.Recurrence = ""
+	require.NoError(t, store.Update(&first))
+	second := known.TodoItem{OwnerID: owner.ID, Title: "water", Recurrence: "FREQ=DAILY", SeriesID: first.ID}
+	require.NoError(t, store.Create(&second))
+	require.NoError(t, store.Create(&known.TodoItem{OwnerID: owner.ID, Title: "feed"}))
+
+	got, err := store.GetOne(owner.ID, second.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "FREQ=DAILY", got.Recurrence)
+	assert.Equal(t, first.ID, got.SeriesID)
+	got.Title = "water the plants"
+	require.NoError(t, store.Update(got))
+	got, err = store.GetOne(owner.ID, second.ID)
+	require.NoError(t, err)
+	assert.Equal(t, first.ID, got.SeriesID, "updates keep the series")
+
+	items, err := store.GetAll(ctx, owner.ID, known.TodoQuery{SeriesID: first.ID})
+	require.NoError(t, err)
+	require.Len(t, items, 2)
+	assert.Empty(t, items[0].Recurrence)
+	assert.Equal(t, second.ID, items[1].ID)
+}
+
+func TestSQLite_Reminders(t *testing.T) {
+	store := newSQLiteForTest(t)
+	now := time.Date(2024, 5, 6, 12, 0, 0, 0, time.UTC)
+
+	owner := known.User{Name: "Jane", Username: "jane", PasswordHash: "x"}
+	require.NoError(t, store.CreateUser(&owner))
+	owner.Email = "jane@example.com"
+	require.NoError(t, store.UpdateUser(&owner))
+	got, err := store.GetUser(owner.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "jane@example.com", got.Email)
+
+	due := now.Add(time.Hour)
+	item := known.TodoItem{OwnerID: owner.ID, Title: "rent", Due: &due}
+	require.NoError(t, store.Create(&item))
+	at := now.Add(-time.Minute)
+	fixed := known.Reminder{TodoID: item.ID, OwnerID: owner.ID, At: &at}
+	require.NoError(t, store.CreateReminder(&fixed))
+	minutes := 90
+	offset := known.Reminder{TodoID: item.ID, OwnerID: owner.ID, MinutesBefore: &minutes}
+	require.NoError(t, store.CreateReminder(&offset))
+	assert.True(t, offset.FireAt.Equal(now.Add(-30*time.Minute)))
+	assert.ErrorIs(t, store.CreateReminder(&known.Reminder{TodoID: item.ID, OwnerID: owner.ID + 1, At: &at}), ErrNoItem)
+
+	reminders, err := store.GetReminders(owner.ID, item.ID)
+	require.NoError(t, err)
+	require.Len(t, reminders, 2)
+	assert.Equal(t, offset.ID, reminders[0].ID, "the earliest first")
+	assert.Equal(t, 90, *reminders[0].MinutesBefore)
+
+	later := now.Add(3 * time.Hour)
+	item.Due = &later
+	require.NoError(t, store.Update(&item))
+	lapsed := now.Add(-5 * time.Minute)
+	pending, err := store.DueReminders(now, lapsed, 5, 10)
+	require.NoError(t, err)
+	require.Len(t, pending, 1, "the offset follows the due date")
+	assert.Equal(t, fixed.ID, pending[0].ID)
+	assert.Equal(t, owner.ID, pending[0].OwnerID)
+
+	claimed, err := store.ClaimReminder(fixed.ID, now, lapsed)
+	require.NoError(t, err)
+	assert.True(t, claimed)
+	claimed, err = store.ClaimReminder(fixed.ID, now, lapsed)
+	require.NoError(t, err)
+	assert.False(t, claimed, "a reminder is claimed once")
+	pending, err = store.DueReminders(now, lapsed, 5, 10)
+	require.NoError(t, err)
+	assert.Empty(t, pending, "a claimed reminder is not due")
+
+	// the server holding the claim never came back
+	later, laterLapsed := now.Add(10*time.Minute), now.Add(5*time.Minute)
+	pending, err = store.DueReminders(later, laterLapsed, 5, 10)
+	require.NoError(t, err)
+	assert.Len(t, pending, 1, "a lapsed claim is due again")
+	claimed, err = store.ClaimReminder(fixed.ID, later, laterLapsed)
+	require.NoError(t, err)
+	assert.True(t, claimed, "a lapsed claim is taken over")
+
+	require.NoError(t, store.ReleaseReminder(fixed.ID))
+	pending, err = store.DueReminders(now, lapsed, 1, 10)
+	require.NoError(t, err)
+	assert.Empty(t, pending, "reminders failing too often are given up")
+
+	require.NoError(t, store.SentReminder(fixed.ID, now))
+	reminders, err = store.GetReminders(owner.ID, item.ID)
+	require.NoError(t, err)
+	require.Equal(t, fixed.ID, reminders[0].ID)
+	assert.True(t, reminders[0].SentAt.Equal(now))
+	assert.ErrorIs(t, store.SentReminder(fixed.ID+100, now), ErrNoReminder)
+
+	release, leading, err := store.Lead(context.Background())
+	require.NoError(t, err)
+	assert.True(t, leading)
+	release()
+
+	assert.ErrorIs(t, store.DeleteReminder(owner.ID+1, item.ID, fixed.ID), ErrNoReminder)
+	require.NoError(t, store.DeleteReminder(owner.ID, item.ID, fixed.ID))
+	require.NoError(t, store.Delete(owner.ID, item.ID, false, 0))
+	pending, err = store.DueReminders(now.Add(24*time.Hour), now, 5, 10)
+	require.NoError(t, err)
+	assert.Empty(t, pending, "trashed items are not reminded of")
+	require.NoError(t, store.Purge(owner.ID, item.ID))
+	var left int
+	require.NoError(t, store.DB.QueryRow("select count(*) from reminders").Scan(&left))
+	assert.Zero(t, left, "purging an item drops its reminders")
+}
+
+func TestSQLite_Trash(t *testing.T) {
+	store := newSQLiteForTest(t)
+	require.NoError(t, store.CreateUser(&known.User{Name: "Jane", Username: "jane", PasswordHash: "x"}))
+
+	parent := known.TodoItem{OwnerID: 1, Title: "garden"}
+	require.NoError(t, store.Create(&parent))
+	child := known.TodoItem{OwnerID: 1, Title: "seeds", ParentID: parent.ID}
+	require.NoError(t, store.Create(&child))
+	loose := known.TodoItem{OwnerID: 1, Title: "gloves", ParentID: parent.ID}
+	require.NoError(t, store.Create(&loose))
+	tag := known.Tag{OwnerID: 1, Name: "home"}
+	require.NoError(t, store.CreateTag(&tag))
+	require.NoError(t, store.Update(&known.TodoItem{ID: child.ID, OwnerID: 1, Title: "seeds", Tags: []string{"home"}}))
+
+	require.NoError(t, store.Delete(1, loose.ID, false, 0))
+	assert.ErrorIs(t, store.Delete(1, loose.ID, false, 0), ErrNoItem)
+	require.NoError(t, store.Delete(1, parent.ID, true, 0))
+	_, err := store.GetOne(1, child.ID)
+	assert.ErrorIs(t, err, ErrNoItem)
+	tags, err := store.GetTags(1)
+	require.NoError(t, err)
+	assert.Zero(t, tags[0].Items, "trashed items are not counted")
+
+	trashed, err := store.GetAll(context.Background(), 1, known.TodoQuery{Trashed: true, SortBy: known.SortByDeleted, Desc: true})
+	require.NoError(t, err)
+	require.Len(t, trashed, 3)
+	assert.Equal(t, loose.ID, trashed[2].ID, "the earliest deleted item comes last")
+	require.NotNil(t, trashed[0].Deleted)
+
+	assert.ErrorIs(t, store.Restore(1, loose.ID), ErrParentTrashed)
+	assert.ErrorIs(t, store.Restore(2, parent.ID), ErrNoItem)
+	require.NoError(t, store.Restore(1, parent.ID))
+	live, err := store.GetAll(context.Background(), 1, known.TodoQuery{})
+	require.NoError(t, err)
+	assert.Len(t, live, 2, "the subtask deleted on its own stays in the trash")
+	require.NotNil(t, live[0].Progress)
+	assert.Equal(t, 1, live[0].Progress.Total)
+
+	assert.ErrorIs(t, store.Purge(1, parent.ID), ErrNoItem, "only trashed items are purged")
+	require.NoError(t, store.Delete(1, parent.ID, true, 0))
+	purged, err := store.PurgeTrash(time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, 1, purged, "subtasks go along with their parent uncounted")
+	var left int
+	require.NoError(t, store.DB.QueryRow("select count(*) from todos").Scan(&left))
+	assert.Zero(t, left)
+}
+
+func TestSQLite_Archive(t *testing.T) {
+	store := newSQLiteForTest(t)
+	require.NoError(t, store.CreateUser(&known.User{Name: "Jane", Username: "jane", PasswordHash: "x"}))
+	completed := time.Date(2024, 5, 6, 12, 0, 0, 0, time.UTC)
+
+	done := known.TodoItem{OwnerID: 1, Title: "rent", Done: true, Completed: &completed}
+	require.NoError(t, store.Create(&done))
+	open := known.TodoItem{OwnerID: 1, Title: "taxes"}
+	require.NoError(t, store.Create(&open))
+
+	archived, err := store.ArchiveDone(completed)
+	require.NoError(t, err)
+	assert.Zero(t, archived, "only items done before the given time are archived")
+	archived, err = store.ArchiveDone(completed.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 1, archived)
+
+	active, err := store.GetAll(context.Background(), 1, known.TodoQuery{})
+	require.NoError(t, err)
+	require.Len(t, active, 1)
+	assert.Equal(t, open.ID, active[0].ID)
+	archive, err := store.GetAll(context.Background(), 1, known.TodoQuery{Archived: true, SortBy: known.SortByCompleted})
+	require.NoError(t, err)
+	require.Len(t, archive, 1)
+	assert.True(t, archive[0].Completed.Equal(completed))
+	assert.NotNil(t, archive[0].Archived)
+	all, err := store.GetAll(context.Background(), 1, known.TodoQuery{AnyArchived: true})
+	require.NoError(t, err)
+	assert.Len(t, all, 2, "archived items are listed along with the active ones on request")
+
+	later := completed.Add(2 * time.Hour)
+	done.Completed = &later
+	require.NoError(t, store.Update(&done))
+	got, err := store.GetOne(1, done.ID)
+	require.NoError(t, err)
+	assert.True(t, got.Completed.Equal(completed), "the first completion is kept")
+	assert.NotNil(t, got.Archived)
+
+	assert.ErrorIs(t, store.Unarchive(1, open.ID), ErrNoItem)
+	assert.ErrorIs(t, store.Unarchive(2, done.ID), ErrNoItem)
+	require.NoError(t, store.Unarchive(1, done.ID))
+	got, err = store.GetOne(1, done.ID)
+	require.NoError(t, err)
+	assert.Nil(t, got.Archived)
+
+	_, err = store.ArchiveDone(completed.Add(time.Hour))
+	require.NoError(t, err)
+	done.Done = false
+	require.NoError(t, store.Update(&done))
+	got, err = store.GetOne(1, done.ID)
+	require.NoError(t, err)
+	assert.Nil(t, got.Completed, "undoing an item clears its completion")
+	assert.Nil(t, got.Archived, "and takes it out of the archive")
+}
+
+func TestSQLite_Revisions(t *testing.T) {
+	store := newSQLiteForTest(t)
+	require.NoError(t, store.CreateUser(&known.User{Name: "Jane", Username: "jane", PasswordHash: "x"}))
+
+	item := known.TodoItem{OwnerID: 1, Title: "garden", Tags: []string{"home"}}
+	require.NoError(t, store.Create(&item))
+	subtask := known.TodoItem{OwnerID: 1, Title: "dig", ParentID: item.ID}
+	require.NoError(t, store.Create(&subtask))
+
+	item.Title, item.Tags = "Garden", nil
+	require.NoError(t, store.Update(&item))
+	require.NoError(t, store.Update(&item))
+	item.Done = true
+	require.NoError(t, store.Update(&item))
+	require.NoError(t, store.CompleteSubtasks(1, item.ID))
+	require.NoError(t, store.Delete(1, item.ID, true, 0))
+	require.NoError(t, store.Restore(1, item.ID))
+
+	revisions, err := store.GetRevisions(1, item.ID)
+	require.NoError(t, err)
+	require.Len(t, revisions, 5, "an update changing nothing is left out")
+	actions := make([]string, len(revisions))
+	for i, revision := range revisions {
+		actions[i] = revision.Action
+		assert.Equal(t, 1, revision.ActorID)
+	}
+	assert.Equal(t, []string{known.RevisionCreate, known.RevisionUpdate, known.RevisionUpdate, known.RevisionDelete, known.RevisionRestore}, actions)
+	assert.Len(t, revisions[0].Changes, 9)
+	assert.Equal(t, []known.Change{
+		{Field: "title", From: json.RawMessage(`"garden"`), To: json.RawMessage(`"Garden"`)},
+		{Field: "tags", From: json.RawMessage(`["home"]`), To: json.RawMessage(`null`)},
+	}, revisions[1].Changes)
+	assert.Empty(t, revisions[3].Changes)
+
+	revisions, err = store.GetRevisions(1, subtask.ID)
+	require.NoError(t, err)
+	require.Len(t, revisions, 4)
+	assert.Equal(t, []known.Change{{Field: "done", From: json.RawMessage(`false`), To: json.RawMessage(`true`)}}, revisions[1].Changes)
+
+	_, err = store.GetRevisions(2, item.ID)
+	assert.ErrorIs(t, err, ErrNoItem)
+
+	require.NoError(t, store.Delete(1, item.ID, true, 0))
+	require.NoError(t, store.Purge(1, item.ID))
+	_, err = store.GetRevisions(1, subtask.ID)
+	assert.ErrorIs(t, err, ErrNoItem, "the revisions go away with the item")
+}
+
+func TestSQLite_Versions(t *testing.T) {
+	store := newSQLiteForTest(t)
+	require.NoError(t, store.CreateUser(&known.User{Name: "Jane", Username: "jane", PasswordHash: "x"}))
+
+	item := known.TodoItem{OwnerID: 1, Title: "garden"}
+	require.NoError(t, store.Create(&item))
+	assert.Equal(t, 1, item.Version)
+	subtask := known.TodoItem{OwnerID: 1, Title: "dig", ParentID: item.ID}
+	require.NoError(t, store.Create(&subtask))
+
+	mine, theirs := item, item
+	mine.Title = "Garden"
+	require.NoError(t, store.Update(&mine))
+	assert.Equal(t, 2, mine.Version)
+	theirs.Title = "Yard"
+	assert.ErrorIs(t, store.Update(&theirs), ErrStaleVersion)
+
+	theirs.Version = 0
+	require.NoError(t, store.Update(&theirs), "no version overwrites whatever is stored")
+	assert.Equal(t, 3, theirs.Version)
+	got, err := store.GetOne(1, item.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Yard", got.Title)
+	assert.Equal(t, 3, got.Version)
+	unchanged := *got
+	require.NoError(t, store.Update(&unchanged))
+	assert.Equal(t, 3, unchanged.Version, "an update changing nothing keeps the version")
+	revisions, err := store.GetRevisions(1, item.ID)
+	require.NoError(t, err)
+	assert.Len(t, revisions, 3)
+
+	theirs.Done = true
+	require.NoError(t, store.Update(&theirs))
+	require.NoError(t, store.CompleteSubtasks(1, item.ID))
+	got, err = store.GetOne(1, subtask.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, got.Version, "completing a subtask changes it")
+
+	assert.ErrorIs(t, store.Delete(1, item.ID, true, 3), ErrStaleVersion)
+	assert.ErrorIs(t, store.Delete(2, item.ID, true, 4), ErrNoItem)
+	require.NoError(t, store.Delete(1, item.ID, true, 4))
+	assert.ErrorIs(t, store.Delete(1, item.ID, true, 4), ErrNoItem)
+}
+
+func TestSQLite_Tokens(t *testing.T) {
+	store := newSQLiteForTest(t)
+
+	owner := known.User{Name: "Jane", Username: "jane", PasswordHash: "x"}
+	require.NoError(t, store.CreateUser(&owner))
+
+	token := known.RefreshToken{Hash: "h1", UserID: owner.ID, Family: "f", ExpiresAt: time.Now().Add(time.Hour)}
+	require.NoError(t, store.SaveRefreshToken(&token))
+
+	rotated, err := store.RevokeRefreshToken("h1")
+	require.NoError(t, err)
+	assert.True(t, rotated)
+
+	rotated, err = store.RevokeRefreshToken("h1")
+	require.NoError(t, err)
+	assert.False(t, rotated)
+
+	stored, err := store.GetRefreshToken("h1")
+	require.NoError(t, err)
+	assert.True(t, stored.Revoked)
+	assert.WithinDuration(t, token.ExpiresAt, stored.ExpiresAt, time.Millisecond)
+
+	require.NoError(t, store.RevokeAccessToken("jti", time.Now().Add(time.Minute)))
+	revoked, err := store.IsAccessTokenRevoked("jti")
+	require.NoError(t, err)
+	assert.True(t, revoked)
+}
+
+func TestSQLite_IdempotencyKeys(t *testing.T) {
+	store := newSQLiteForTest(t)
+	now := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+
+	owner := known.User{Name: "Jane", Username: "jane", PasswordHash: "x"}
+	require.NoError(t, store.CreateUser(&owner))
+	other := known.User{Name: "John", Username: "john", PasswordHash: "x"}
+	require.NoError(t, store.CreateUser(&other))
+
+	request := known.IdempotentRequest{OwnerID: owner.ID, Key: "k1", Fingerprint: "f1", ExpiresAt: now.Add(time.Hour)}
+	kept, err := store.ClaimIdempotencyKey(&request, now)
+	require.NoError(t, err)
+	assert.Nil(t, kept, "the key is claimed")
+
+	kept, err = store.ClaimIdempotencyKey(&known.IdempotentRequest{OwnerID: owner.ID, Key: "k1", Fingerprint: "f2", ExpiresAt: now.Add(time.Hour)}, now)
+	require.NoError(t, err)
+	require.NotNil(t, kept)
+	assert.Equal(t, "f1", kept.Fingerprint)
+	assert.Zero(t, kept.Status, "the request is still being processed")
+
+	kept, err = store.ClaimIdempotencyKey(&known.IdempotentRequest{OwnerID: other.ID, Key: "k1", Fingerprint: "f3", ExpiresAt: now.Add(time.Hour)}, now)
+	require.NoError(t, err)
+	assert.Nil(t, kept, "keys of other owners are unrelated")
+
+	request.Status = 201
+	request.Header = map[string]string{"Location": "/todo/1"}
+	request.Body = []byte(`{"success":true}`)
+	require.NoError(t, store.SaveIdempotentResponse(&request))
+	kept, err = store.ClaimIdempotencyKey(&known.IdempotentRequest{OwnerID: owner.ID, Key: "k1", Fingerprint: "f1", ExpiresAt: now.Add(time.Hour)}, now)
+	require.NoError(t, err)
+	require.NotNil(t, kept)
+	assert.Equal(t, 201, kept.Status)
+	assert.Equal(t, request.Header, kept.Header)
+	assert.Equal(t, request.Body, kept.Body)
+
+	later := now.Add(time.Hour)
+	kept, err = store.ClaimIdempotencyKey(&known.IdempotentRequest{OwnerID: owner.ID, Key: "k1", Fingerprint: "f4", ExpiresAt: later.Add(time.Hour)}, later)
+	require.NoError(t, err)
+	assert.Nil(t, kept, "an expired key is claimed anew")
+
+	require.NoError(t, store.ReleaseIdempotencyKey(owner.ID, "k1"))
+	kept, err = store.ClaimIdempotencyKey(&known.IdempotentRequest{OwnerID: owner.ID, Key: "k1", Fingerprint: "f5", ExpiresAt: later.Add(time.Hour)}, later)
+	require.NoError(t, err)
+	assert.Nil(t, kept, "a released key is claimed anew")
+}
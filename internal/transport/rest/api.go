@@ -11,23 +11,61 @@ import (
 
 type RESTful struct {
 	serviceLayer *services.Composition
-	staticAPIKey string
 	Router       http.Handler
 }
 
 func Init(layer *services.Composition) *RESTful {
 	api := RESTful{
 		serviceLayer: layer,
-		staticAPIKey: "kc74RbhOwtvVRcJhhJKpuDxSLwJY6oSC0iCfTJ2FsG0=",
 	}
 
 	r := mux.NewRouter().StrictSlash(true)
-	r.HandleFunc("/todo", api.AllItems).Methods(http.MethodGet)
-	r.HandleFunc("/todo", api.AddItem).Methods(http.MethodPost)
-	r.HandleFunc("/todo/{id}", api.GetItem).Methods(http.MethodGet)
-	r.HandleFunc("/todo/{id}", api.UpdateItem).Methods(http.MethodPatch)
-	r.HandleFunc("/todo/{id}", api.DeleteItem).Methods(http.MethodDelete)
-	r.HandleFunc("/todo/status/{selector}", api.FilterByStatus).Methods(http.MethodGet)
+	r.HandleFunc("/users", api.Register).Methods(http.MethodPost)
+	r.HandleFunc("/auth/login", api.Login).Methods(http.MethodPost)
+	r.HandleFunc("/auth/refresh", api.Refresh).Methods(http.MethodPost)
+
+	private := r.NewRoute().Subrouter()
+	private.Use(api.authenticated)
+	private.HandleFunc("/users/me", api.Me).Methods(http.MethodGet)
+	private.HandleFunc("/users/me", api.UpdateMe).Methods(http.MethodPatch)
+	private.HandleFunc("/auth/logout", api.Logout).Methods(http.MethodPost)
+	private.HandleFunc("/todo", api.AllItems).Methods(http.MethodGet)
+	private.HandleFunc("/todo", api.idempotent(api.AddItem)).Methods(http.MethodPost)
+	private.HandleFunc("/todo/next", api.NextItems).Methods(http.MethodGet)
+	private.HandleFunc("/todo/{id}", api.GetItem).Methods(http.MethodGet)
+	private.HandleFunc("/todo/{id}", api.idempotent(api.UpdateItem)).Methods(http.MethodPatch)
+	private.HandleFunc("/todo/{id}", api.ReplaceItem).Methods(http.MethodPut)
+	private.HandleFunc("/todo/{id}", api.DeleteItem).Methods(http.MethodDelete)
+	private.HandleFunc("/todo/{id}/move", api.MoveItem).Methods(http.MethodPost)
+	private.HandleFunc("/todo/{id}/restore", api.RestoreItem).Methods(http.MethodPost)
+	private.HandleFunc("/todo/{id}/unarchive", api.UnarchiveItem).Methods(http.MethodPost)
+	private.HandleFunc("/todo/{id}/history", api.History).Methods(http.MethodGet)
+	private.HandleFunc("/todo/{id}/history/{revision}/revert", api.RevertItem).Methods(http.MethodPost)
+	private.HandleFunc("/todo/{id}/subtasks", api.Subtasks).Methods(http.MethodGet)
+	private.HandleFunc("/todo/{id}/occurrences", api.Occurrences).Methods(http.MethodGet)
+	private.HandleFunc("/todo/{id}/blockers", api.Blockers).Methods(http.MethodGet)
+	private.HandleFunc("/todo/{id}/blockers", api.AddBlocker).Methods(http.MethodPost)
+	private.HandleFunc("/todo/{id}/blockers/{blocker}", api.RemoveBlocker).Methods(http.MethodDelete)
+	private.HandleFunc("/todo/{id}/reminders", api.Reminders).Methods(http.MethodGet)
+	private.HandleFunc("/todo/{id}/reminders", api.AddReminder).Methods(http.MethodPost)
+	private.HandleFunc("/todo/{id}/reminders/{reminder}", api.DeleteReminder).Methods(http.MethodDelete)
+	private.HandleFunc("/todo/status/{selector}", api.FilterByStatus).Methods(http.MethodGet)
+	private.HandleFunc("/todo/due/{view}", api.DueItems).Methods(http.MethodGet)
+	private.HandleFunc("/archive", api.Archive).Methods(http.MethodGet)
+	private.HandleFunc("/trash", api.Trash).Methods(http.MethodGet)
+	private.HandleFunc("/trash/{id}", api.PurgeItem).Methods(http.MethodDelete)
+	private.HandleFunc("/lists", api.AllLists).Methods(http.MethodGet)
+	private.HandleFunc("/lists", api.AddList).Methods(http.MethodPost)
+	private.HandleFunc("/lists/{id}", api.GetList).Methods(http.MethodGet)
+	private.HandleFunc("/lists/{id}", api.UpdateList).Methods(http.MethodPatch)
+	private.HandleFunc("/lists/{id}", api.DeleteList).Methods(http.MethodDelete)
+	private.HandleFunc("/lists/{id}/todo", api.ListItems).Methods(http.MethodGet)
+	private.HandleFunc("/lists/{id}/todo", api.AddListItem).Methods(http.MethodPost)
+	private.HandleFunc("/tags", api.AllTags).Methods(http.MethodGet)
+	private.HandleFunc("/tags", api.AddTag).Methods(http.MethodPost)
+	private.HandleFunc("/tags/{id}", api.RenameTag).Methods(http.MethodPatch)
+	private.HandleFunc("/tags/{id}", api.DeleteTag).Methods(http.MethodDelete)
+	private.HandleFunc("/tags/{id}/merge", api.MergeTag).Methods(http.MethodPost)
 
 	api.Router = r
 
@@ -0,0 +1,35 @@
+package rest
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// Archive lists the archived items, the most recently completed first, taking the parameters
+// of /todo, q included to search them.
+func (rest *RESTful) Archive(w http.ResponseWriter, r *http.Request) {
+	query, err := parseTodoQuery(r.URL.Query())
+	if err != nil {
+		rest.respondWith(w, nil, err)
+		return
+	}
+
+	page, err := rest.serviceLayer.ToDos.GetArchive(r.Context(), principal(r).UserID, query)
+	if err != nil {
+		rest.respondWith(w, nil, err)
+		return
+	}
+	rest.serviceLayer.Log.Info("serving archive", slog.Int("count", len(page.Items)))
+	rest.respondWithPage(w, page)
+}
+
+// UnarchiveItem brings an archived item back into the active listings.
+func (rest *RESTful) UnarchiveItem(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	rest.serviceLayer.Log.Info("unarchiving item", slog.Int("id", id))
+	err := rest.serviceLayer.ToDos.Unarchive(principal(r).UserID, id)
+	rest.respondWith(w, nil, err)
+}
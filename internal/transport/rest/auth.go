@@ -0,0 +1,44 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/scriptdealer/to-do-go/known"
+)
+
+type contextKey int
+
+const principalKey contextKey = iota
+
+// authenticated verifies the bearer access token and puts its principal into the request context.
+func (rest *RESTful) authenticated(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		caller, err := rest.serviceLayer.Users.Authenticate(bearerToken(r))
+		if err != nil {
+			rest.respondWith(w, nil, err)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), principalKey, caller)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// principal returns the caller put into the context by authenticated.
+func principal(r *http.Request) *known.Principal {
+	caller, _ := r.Context().Value(principalKey).(*known.Principal)
+	return caller
+}
+
+func bearerToken(r *http.Request) string {
+	authHeader := r.Header.Get("Authorization")
+	authPrefix := "Bearer "
+
+	if !strings.HasPrefix(authHeader, authPrefix) {
+		return ""
+	}
+
+	return authHeader[len(authPrefix):]
+}
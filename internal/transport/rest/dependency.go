@@ -0,0 +1,47 @@
+package rest
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// Blockers serves the items directly blocking an item, done or not.
+func (rest *RESTful) Blockers(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	items, err := rest.serviceLayer.ToDos.GetBlockers(principal(r).UserID, id)
+	rest.respondWith(w, items, err)
+}
+
+// AddBlocker makes an item blocked by the item {"blocker": id}.
+func (rest *RESTful) AddBlocker(w http.ResponseWriter, r *http.Request) {
+	var data BlockerRequest
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	err := decodeBody(r, &data)
+	if err == nil {
+		rest.serviceLayer.Log.Info("adding blocker", slog.Int("id", id), slog.Int("blocker", data.Blocker))
+		err = rest.serviceLayer.ToDos.AddBlocker(principal(r).UserID, id, data.Blocker)
+	}
+	rest.respondWith(w, nil, err)
+}
+
+func (rest *RESTful) RemoveBlocker(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
+	blocker, _ := strconv.Atoi(vars["blocker"])
+	rest.serviceLayer.Log.Info("removing blocker", slog.Int("id", id), slog.Int("blocker", blocker))
+	err := rest.serviceLayer.ToDos.RemoveBlocker(principal(r).UserID, id, blocker)
+	rest.respondWith(w, nil, err)
+}
+
+// NextItems serves the undone items in an order that respects their dependencies,
+// the most urgent of the items ready to be done first.
+func (rest *RESTful) NextItems(w http.ResponseWriter, r *http.Request) {
+	items, err := rest.serviceLayer.ToDos.Next(r.Context(), principal(r).UserID)
+	if err == nil {
+		rest.serviceLayer.Log.Info("serving next items", slog.Int("count", len(items)))
+	}
+	rest.respondWith(w, items, err)
+}
@@ -1,24 +1,319 @@
 package rest
 
-import "errors"
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
-var errInvalidPatchRequest = errors.New("update data has empty values")
+	"github.com/scriptdealer/to-do-go/internal/services"
+	"github.com/scriptdealer/to-do-go/known"
+)
+
+var (
+	errNoTitle       = known.NewError(known.ErrValidation, "an item needs a title")
+	errInvalidQuery  = known.NewError(known.ErrValidation, "invalid listing parameters")
+	errUnknownStatus = known.NewError(known.ErrValidation, "unknown status, expected done or active")
+	errMalformedBody = known.NewError(known.ErrValidation, "request body is not valid JSON")
+	errUnknownList   = known.NewError(known.ErrNotFound, "no such list")
+	errUnknownItem   = known.NewError(known.ErrNotFound, "no such item")
+	errInvalidMove   = known.NewError(known.ErrValidation, "exactly one of before, after, list and parent is required")
+)
 
 type apiResponse struct {
 	Success bool        `json:"success"`
-	Error   string      `json:"error,omitempty"`
 	Data    interface{} `json:"data,omitempty"`
+	Page    *pageInfo   `json:"page,omitempty"`
+}
+
+type pageInfo struct {
+	Limit      int    `json:"limit"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+func newPageInfo(page *known.TodoPage) *pageInfo {
+	info := pageInfo{Limit: page.Limit}
+	if page.Next != nil {
+		info.NextCursor = encodeCursor(page.Next)
+	}
+
+	return &info
+}
+
+// parseTodoQuery reads ?limit=&cursor=&sort=&order=asc|desc&done=true|false&q=text
+// and any number of &tag=name, matched all at once or with &tag_mode=any any of them.
+func parseTodoQuery(values url.Values) (known.TodoQuery, error) {
+	query := known.TodoQuery{
+		SortBy: values.Get("sort"),
+		Text:   values.Get("q"),
+		Tags:   values["tag"],
+	}
+
+	switch values.Get("tag_mode") {
+	case "", "all":
+	case "any":
+		query.AnyTag = true
+	default:
+		return query, errInvalidQuery
+	}
+
+	if limit := values.Get("limit"); limit != "" {
+		parsed, err := strconv.Atoi(limit)
+		if err != nil || parsed < 1 {
+			return query, errInvalidQuery
+		}
+		query.Limit = parsed
+	}
+
+	if cursor := values.Get("cursor"); cursor != "" {
+		after, err := decodeCursor(cursor)
+		if err != nil {
+			return query, errInvalidQuery
+		}
+		query.After = after
+	}
+
+	switch values.Get("order") {
+	case "", "asc":
+	case "desc":
+		query.Desc = true
+	default:
+		return query, errInvalidQuery
+	}
+
+	if done := values.Get("done"); done != "" {
+		parsed, err := strconv.ParseBool(done)
+		if err != nil {
+			return query, errInvalidQuery
+		}
+		query.Done = &parsed
+	}
+
+	return query, nil
+}
+
+// parseFlag reads an optional boolean parameter like ?cascade=true.
+func parseFlag(values url.Values, name string) (bool, error) {
+	flag := values.Get(name)
+	if flag == "" {
+		return false, nil
+	}
+
+	parsed, err := strconv.ParseBool(flag)
+	if err != nil {
+		return false, errInvalidQuery
+	}
+
+	return parsed, nil
+}
+
+// parseUpdateOptions reads ?cascade=true, completing the subtasks of an item marked as done,
+// and ?force=true, marking it as done despite its undone blockers.
+func parseUpdateOptions(values url.Values) (services.UpdateOptions, error) {
+	var opts services.UpdateOptions
+	var err error
+	if opts.Cascade, err = parseFlag(values, "cascade"); err != nil {
+		return opts, err
+	}
+	opts.Force, err = parseFlag(values, "force")
+
+	return opts, err
+}
+
+func parseStatus(selector string) (*bool, error) {
+	var done bool
+	switch selector {
+	case "done":
+		done = true
+	case "active":
+	default:
+		return nil, errUnknownStatus
+	}
+
+	return &done, nil
+}
+
+// cursors are opaque to clients, so the paging scheme can change without breaking them;
+// they carry the sort key and ID of the last item of a page for the next one to start past it
+type cursorData struct {
+	SortBy string          `json:"s"`
+	Desc   bool            `json:"d,omitempty"`
+	Key    json.RawMessage `json:"k,omitempty"`
+	ID     int             `json:"i"`
+}
+
+func encodeCursor(cursor *known.PageCursor) string {
+	data := cursorData{SortBy: cursor.SortBy, Desc: cursor.Desc, ID: cursor.ID}
+	if cursor.Key != nil {
+		data.Key, _ = json.Marshal(cursor.Key)
+	}
+	raw, _ := json.Marshal(data)
+
+	return base64.RawURLEncoding.EncodeToString(raw)
 }
 
+func decodeCursor(cursor string) (*known.PageCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	var data cursorData
+	if err := json.Unmarshal(raw, &data); err != nil || data.ID < 1 {
+		return nil, errInvalidQuery
+	}
+
+	after := known.PageCursor{SortBy: data.SortBy, Desc: data.Desc, ID: data.ID}
+	if data.Key == nil {
+		return &after, nil
+	}
+	switch data.SortBy {
+	case known.SortByPriority:
+		var key known.Priority
+		err = json.Unmarshal(data.Key, &key)
+		after.Key = key
+	case known.SortByTitle:
+		var key string
+		err = json.Unmarshal(data.Key, &key)
+		after.Key = key
+	case known.SortByCreated, known.SortByDue, known.SortByCompleted, known.SortByDeleted:
+		var key time.Time
+		err = json.Unmarshal(data.Key, &key)
+		after.Key = key
+	case known.SortByPosition:
+		var key float64
+		err = json.Unmarshal(data.Key, &key)
+		after.Key = key
+	default:
+		err = errInvalidQuery
+	}
+	if err != nil {
+		return nil, errInvalidQuery
+	}
+
+	return &after, nil
+}
+
+// TodoPatchRequest is a whole item as created with POST or replaced with PUT, omitted fields being empty.
 type TodoPatchRequest struct {
-	Title       string `json:"title"`
-	Description string `json:"description"`
-	Done        bool   `json:"done"`
+	Title       string                 `json:"title"`
+	Description string                 `json:"description"`
+	Done        bool                   `json:"done"`
+	Priority    known.Priority         `json:"priority,omitempty"`
+	StartAt     *time.Time             `json:"start_at,omitempty"`
+	DueAt       *time.Time             `json:"due_at,omitempty"`
+	Tags        []string               `json:"tags,omitempty"`
+	Checklist   []known.ChecklistEntry `json:"checklist,omitempty"`
+	Recurrence  string                 `json:"recurrence,omitempty"`
+	// ParentID creates the item as a subtask; updates keep the parent, see MoveRequest.
+	ParentID int `json:"parent_id,omitempty"`
+	// Version, unless zero, is the version of the item the update was made against, like If-Match.
+	Version int `json:"version,omitempty"`
 }
 
+func (update *TodoPatchRequest) item() known.TodoItem {
+	return known.TodoItem{
+		Title:       update.Title,
+		Description: update.Description,
+		Done:        update.Done,
+		Priority:    update.Priority,
+		Start:       update.StartAt,
+		Due:         update.DueAt,
+		Tags:        update.Tags,
+		Checklist:   update.Checklist,
+		Recurrence:  update.Recurrence,
+		ParentID:    update.ParentID,
+		Version:     update.Version,
+	}
+}
+
+// Validate checks a whole item, as created or replaced; the description may be left empty.
 func (update *TodoPatchRequest) Validate() error {
-	if update.Title == "" || update.Description == "" {
-		return errInvalidPatchRequest
+	if strings.TrimSpace(update.Title) == "" {
+		return errNoTitle
 	}
 	return nil
 }
+
+type SignupRequest struct {
+	Name     string `json:"name"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type LoginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type TagRequest struct {
+	Name string `json:"name"`
+}
+
+// ListRequest changes the fields it carries, the omitted ones are kept.
+type ListRequest struct {
+	Name     string `json:"name,omitempty"`
+	Archived *bool  `json:"archived,omitempty"`
+}
+
+type BlockerRequest struct {
+	Blocker int `json:"blocker"`
+}
+
+// ReminderRequest sets either an absolute time or an offset before the due date of the item.
+type ReminderRequest struct {
+	At            *time.Time `json:"at,omitempty"`
+	MinutesBefore *int       `json:"minutes_before,omitempty"`
+}
+
+type MergeRequest struct {
+	Into int `json:"into"`
+}
+
+// MoveRequest names the item to put the moved one before or after, the list
+// to move it into, zero being the inbox, or the item to make it a subtask of,
+// zero making it a top level item.
+type MoveRequest struct {
+	Before int  `json:"before,omitempty"`
+	After  int  `json:"after,omitempty"`
+	List   *int `json:"list,omitempty"`
+	Parent *int `json:"parent,omitempty"`
+}
+
+// anchor returns the item to move next to and whether the move goes before it.
+func (move *MoveRequest) anchor() (int, bool, error) {
+	switch {
+	case move.Before > 0 && move.After == 0:
+		return move.Before, true, nil
+	case move.After > 0 && move.Before == 0:
+		return move.After, false, nil
+	default:
+		return 0, false, errInvalidMove
+	}
+}
+
+// decodeBody reads the JSON body into v, reporting invalid field values
+// like an unknown priority as they are and anything else as malformed.
+func decodeBody(r *http.Request, v any) error {
+	err := json.NewDecoder(r.Body).Decode(v)
+	if err == nil || errors.Is(err, known.ErrValidation) {
+		return err
+	}
+
+	return errMalformedBody
+}
+
+// ProfileRequest changes the fields it carries, the omitted ones are kept.
+type ProfileRequest struct {
+	Name     string `json:"name,omitempty"`
+	TimeZone string `json:"time_zone,omitempty"`
+	Email    string `json:"email,omitempty"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
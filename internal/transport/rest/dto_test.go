@@ -7,13 +7,12 @@ import (
 )
 
 func TestValidation(t *testing.T) {
-	data := TodoPatchRequest{Title: "test"}
-	assert.EqualError(t, data.Validate(), errInvalidPatchRequest.Error())
+	data := TodoPatchRequest{Description: "test"}
+	assert.EqualError(t, data.Validate(), errNoTitle.Error())
 
-	data.Description = data.Title
-	data.Title = ""
-	assert.EqualError(t, data.Validate(), errInvalidPatchRequest.Error())
+	data.Title = " "
+	assert.EqualError(t, data.Validate(), errNoTitle.Error())
 
-	data.Title = data.Description
-	assert.Nil(t, data.Validate())
+	data.Title, data.Description = "test", ""
+	assert.Nil(t, data.Validate(), "the description is optional")
 }
@@ -0,0 +1,29 @@
+package rest
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// History serves the revisions of an item, the oldest first, each with the fields it changed.
+func (rest *RESTful) History(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	revisions, err := rest.serviceLayer.ToDos.History(principal(r).UserID, id)
+	if err == nil {
+		rest.serviceLayer.Log.Info("serving history", slog.Int("id", id), slog.Int("count", len(revisions)))
+	}
+	rest.respondWith(w, revisions, err)
+}
+
+// RevertItem sets the fields of an item back to what they were right after one of its revisions.
+func (rest *RESTful) RevertItem(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
+	revision, _ := strconv.Atoi(vars["revision"])
+	rest.serviceLayer.Log.Info("reverting item", slog.Int("id", id), slog.Int("revision", revision))
+	err := rest.serviceLayer.ToDos.Revert(principal(r).UserID, id, revision)
+	rest.respondWith(w, nil, err)
+}
@@ -0,0 +1,118 @@
+package rest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/scriptdealer/to-do-go/known"
+)
+
+const (
+	idempotencyKeyHeader = "Idempotency-Key"
+	// replayedHeader marks the responses replayed to retries.
+	replayedHeader = "Idempotent-Replayed"
+)
+
+// keptHeaders are the headers of a response replayed along with its status and body.
+var keptHeaders = []string{"Content-Type", "Location", "Content-Location", "ETag", "Accept-Patch"}
+
+// idempotent answers the retries of a request made with an Idempotency-Key with the response
+// to the request, and refuses the key for a request with another method, path or body.
+// Failures of the server are not kept, so such a request can be retried with the same key.
+func (rest *RESTful) idempotent(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotencyKeyHeader)
+		if key == "" {
+			next(w, r)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			rest.respondWithProblem(w, errMalformedBody)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		owner := principal(r).UserID
+		request, err := rest.serviceLayer.Idempotency.Begin(owner, key, fingerprint(r, body))
+		if err != nil {
+			rest.respondWithProblem(w, err)
+			return
+		}
+		if request.Status != 0 {
+			replay(w, request)
+			return
+		}
+		defer func() {
+			// a panicking handler leaves the key claimed otherwise
+			if request.Status == 0 {
+				rest.keepResponse(rest.serviceLayer.Idempotency.Abandon(owner, key))
+			}
+		}()
+
+		recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next(recorder, r)
+		if recorder.status >= http.StatusInternalServerError {
+			return
+		}
+
+		request.Status = recorder.status
+		request.Header = map[string]string{}
+		for _, name := range keptHeaders {
+			if value := recorder.Header().Get(name); value != "" {
+				request.Header[name] = value
+			}
+		}
+		request.Body = recorder.body.Bytes()
+		rest.keepResponse(rest.serviceLayer.Idempotency.Finish(request))
+	}
+}
+
+func (rest *RESTful) keepResponse(err error) {
+	if err != nil {
+		rest.serviceLayer.Log.Warn("failed to keep an idempotent response", slog.String("reason", err.Error()))
+	}
+}
+
+// fingerprint tells requests apart by everything that makes them do something else.
+func fingerprint(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	for _, part := range []string{r.Method, r.URL.Path, r.Header.Get("Content-Type"), r.Header.Get("If-Match")} {
+		hash.Write([]byte(part))
+		hash.Write([]byte{0})
+	}
+	hash.Write(body)
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func replay(w http.ResponseWriter, request *known.IdempotentRequest) {
+	for name, value := range request.Header {
+		w.Header().Set(name, value)
+	}
+	w.Header().Set(replayedHeader, "true")
+	w.WriteHeader(request.Status)
+	_, _ = w.Write(request.Body)
+}
+
+// responseRecorder passes a response on while keeping its status and body.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rr *responseRecorder) WriteHeader(status int) {
+	rr.status = status
+	rr.ResponseWriter.WriteHeader(status)
+}
+
+func (rr *responseRecorder) Write(data []byte) (int, error) {
+	rr.body.Write(data)
+	return rr.ResponseWriter.Write(data)
+}
@@ -0,0 +1,116 @@
+package rest
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/scriptdealer/to-do-go/known"
+)
+
+// AllLists serves the lists with their item counts, the archived ones with ?archived=true.
+func (rest *RESTful) AllLists(w http.ResponseWriter, r *http.Request) {
+	var archived bool
+	if value := r.URL.Query().Get("archived"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			rest.respondWith(w, nil, errInvalidQuery)
+			return
+		}
+		archived = parsed
+	}
+
+	lists, err := rest.serviceLayer.ToDos.GetLists(principal(r).UserID, archived)
+	rest.respondWith(w, lists, err)
+}
+
+func (rest *RESTful) AddList(w http.ResponseWriter, r *http.Request) {
+	var data ListRequest
+	if err := decodeBody(r, &data); err != nil {
+		rest.respondWith(w, nil, err)
+		return
+	}
+
+	list, err := rest.serviceLayer.ToDos.CreateList(principal(r).UserID, data.Name)
+	rest.respondWith(w, list, err)
+}
+
+func (rest *RESTful) GetList(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	list, err := rest.serviceLayer.ToDos.GetList(principal(r).UserID, id)
+	rest.respondWith(w, list, err)
+}
+
+// UpdateList renames a list with {"name": ...} and archives or restores it with {"archived": ...}.
+func (rest *RESTful) UpdateList(w http.ResponseWriter, r *http.Request) {
+	var data ListRequest
+	if err := decodeBody(r, &data); err != nil {
+		rest.respondWith(w, nil, err)
+		return
+	}
+
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	rest.serviceLayer.Log.Info("updating list", slog.Int("id", id), slog.String("name", data.Name), slog.Any("archived", data.Archived))
+	err := rest.serviceLayer.ToDos.UpdateList(principal(r).UserID, id, data.Name, data.Archived)
+	rest.respondWith(w, nil, err)
+}
+
+// DeleteList deletes a list, its items go back to the inbox.
+func (rest *RESTful) DeleteList(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	rest.serviceLayer.Log.Info("deleting list", slog.Int("id", id))
+	err := rest.serviceLayer.ToDos.DeleteList(principal(r).UserID, id)
+	rest.respondWith(w, nil, err)
+}
+
+// ListItems is the listing of /todo narrowed down to one list, archived or not.
+func (rest *RESTful) ListItems(w http.ResponseWriter, r *http.Request) {
+	query, err := parseTodoQuery(r.URL.Query())
+	if err == nil {
+		query.ListID, err = listID(r)
+	}
+	if err != nil {
+		rest.respondWith(w, nil, err)
+		return
+	}
+
+	page, err := rest.serviceLayer.ToDos.GetAll(r.Context(), principal(r).UserID, query)
+	if err != nil {
+		rest.respondWith(w, nil, err)
+		return
+	}
+	rest.serviceLayer.Log.Info("serving list items", slog.Int("list", query.ListID), slog.Int("count", len(page.Items)))
+	rest.respondWithPage(w, page)
+}
+
+func (rest *RESTful) AddListItem(w http.ResponseWriter, r *http.Request) {
+	var data TodoPatchRequest
+	list, err := listID(r)
+	if err == nil {
+		err = decodeBody(r, &data)
+	}
+	rest.serviceLayer.Log.Info("adding item to list", slog.Int("list", list), slog.String("body", fmt.Sprintf("%+v", data)))
+
+	if err == nil {
+		err = data.Validate()
+	}
+	var item *known.TodoItem
+	if err == nil {
+		created := data.item()
+		created.ListID = list
+		item, err = rest.serviceLayer.ToDos.Create(principal(r).UserID, created)
+	}
+	rest.respondWithCreatedItem(w, item, err)
+}
+
+// listID reads the list of the path, which cannot be zero: that would stand for the inbox.
+func listID(r *http.Request) (int, error) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil || id < 1 {
+		return 0, errUnknownList
+	}
+
+	return id, nil
+}
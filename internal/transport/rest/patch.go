@@ -0,0 +1,426 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/scriptdealer/to-do-go/known"
+)
+
+// Media types of the patches PATCH /todo/{id} takes; plain JSON is read as a merge patch.
+const (
+	mediaMergePatch = "application/merge-patch+json"
+	mediaJSONPatch  = "application/json-patch+json"
+	acceptPatch     = mediaMergePatch + ", " + mediaJSONPatch
+)
+
+var (
+	errPatchMediaType = known.NewError(known.ErrValidation, "patches are "+mediaMergePatch+" or "+mediaJSONPatch)
+	errMergePatch     = known.NewError(known.ErrValidation, "a merge patch of an item is a JSON object")
+	errPatchOperation = known.NewError(known.ErrValidation, "a JSON patch is an array of add, remove, replace, move, copy and test operations on JSON pointers")
+	errPatchedValue   = known.NewError(known.ErrValidation, "the patch gives a field of the item a value of the wrong type")
+	errPatchMember    = known.NewError(known.ErrValidation, "the patch sets a field the item does not have")
+	errPatchPath      = known.NewError(known.ErrConflict, "the patch names a location the item does not have")
+	errPatchTest      = known.NewError(known.ErrConflict, "a test operation of the patch failed")
+)
+
+// todoDocument is what patches of an item apply to: the fields an update replaces, all of them
+// present, and the version of the item.
+type todoDocument struct {
+	Title       string                 `json:"title"`
+	Description string                 `json:"description"`
+	Done        bool                   `json:"done"`
+	Priority    known.Priority         `json:"priority"`
+	StartAt     *time.Time             `json:"start_at"`
+	DueAt       *time.Time             `json:"due_at"`
+	Tags        []string               `json:"tags"`
+	Checklist   []known.ChecklistEntry `json:"checklist"`
+	Recurrence  string                 `json:"recurrence"`
+	Version     int                    `json:"version"`
+}
+
+func newTodoDocument(item *known.TodoItem) todoDocument {
+	// empty arrays rather than nulls, so entries are added with /tags/- and the like
+	tags := item.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	checklist := item.Checklist
+	if checklist == nil {
+		checklist = []known.ChecklistEntry{}
+	}
+
+	return todoDocument{
+		Title:       item.Title,
+		Description: item.Description,
+		Done:        item.Done,
+		Priority:    item.Priority,
+		StartAt:     item.Start,
+		DueAt:       item.Due,
+		Tags:        tags,
+		Checklist:   checklist,
+		Recurrence:  item.Recurrence,
+		Version:     item.Version,
+	}
+}
+
+// patchItem applies the patch in the body of r to the current item, reporting whether
+// the patch names the version of the item it was made against.
+func patchItem(r *http.Request, current *known.TodoItem) (TodoPatchRequest, bool, error) {
+	var data TodoPatchRequest
+	media := "application/json"
+	if value := r.Header.Get("Content-Type"); value != "" {
+		parsed, _, err := mime.ParseMediaType(value)
+		if err != nil {
+			return data, false, errPatchMediaType
+		}
+		media = parsed
+	}
+
+	var doc, template any
+	if err := remarshal(newTodoDocument(current), &doc); err != nil {
+		return data, false, err
+	}
+	// every member an item document can have, down to those of checklist entries
+	if err := remarshal(newTodoDocument(&known.TodoItem{Checklist: []known.ChecklistEntry{{}}}), &template); err != nil {
+		return data, false, err
+	}
+
+	var versioned bool
+	switch media {
+	case "application/json", mediaMergePatch:
+		var patch any
+		if err := decodeBody(r, &patch); err != nil {
+			return data, false, err
+		}
+		fields, ok := patch.(map[string]any)
+		if !ok {
+			return data, false, errMergePatch
+		}
+		_, versioned = fields["version"]
+		doc = mergePatch(doc, fields)
+		if !conforms(doc, template) {
+			return data, false, errPatchMember
+		}
+	case mediaJSONPatch:
+		var operations []patchOperation
+		if err := decodeBody(r, &operations); err != nil {
+			return data, false, errPatchOperation
+		}
+		for _, operation := range operations {
+			var err error
+			if doc, err = operation.apply(doc); err != nil {
+				return data, false, err
+			}
+			versioned = versioned || operation.Path == "/version"
+		}
+		if !conforms(doc, template) {
+			return data, false, errPatchPath
+		}
+	default:
+		return data, false, errPatchMediaType
+	}
+
+	if err := remarshal(doc, &data); err != nil {
+		if errors.Is(err, known.ErrValidation) {
+			return data, false, err
+		}
+		return data, false, errPatchedValue
+	}
+
+	return data, versioned, nil
+}
+
+// conforms reports whether doc has no members other than those of template, which has one element
+// in the arrays of objects; the values are checked when doc is read back into an item.
+func conforms(doc, template any) bool {
+	switch doc := doc.(type) {
+	case map[string]any:
+		members, ok := template.(map[string]any)
+		if !ok {
+			return true
+		}
+		for name, value := range doc {
+			member, found := members[name]
+			if !found || !conforms(value, member) {
+				return false
+			}
+		}
+	case []any:
+		elements, ok := template.([]any)
+		if !ok || len(elements) == 0 {
+			return true
+		}
+		for _, value := range doc {
+			if !conforms(value, elements[0]) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// mergePatch applies an RFC 7396 merge patch to target: members of objects are merged,
+// nulls remove them and anything else replaces what was there.
+func mergePatch(target, patch any) any {
+	fields, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	doc, ok := target.(map[string]any)
+	if !ok {
+		doc = map[string]any{}
+	}
+
+	for name, value := range fields {
+		if value == nil {
+			delete(doc, name)
+			continue
+		}
+		doc[name] = mergePatch(doc[name], value)
+	}
+
+	return doc
+}
+
+// patchOperation is one operation of an RFC 6902 JSON patch.
+type patchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+func (operation *patchOperation) apply(doc any) (any, error) {
+	path, err := parsePointer(operation.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch operation.Op {
+	case "add", "replace", "test":
+		// an explicit null is a value, a missing one is not
+		if operation.Value == nil {
+			return nil, errPatchOperation
+		}
+		var value any
+		if err := json.Unmarshal(operation.Value, &value); err != nil {
+			return nil, errPatchOperation
+		}
+
+		switch operation.Op {
+		case "add":
+			return add(doc, path, value)
+		case "replace":
+			return replace(doc, path, value)
+		}
+		found, err := get(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		if !reflect.DeepEqual(found, value) {
+			return nil, errPatchTest
+		}
+		return doc, nil
+	case "remove":
+		doc, _, err = remove(doc, path)
+		return doc, err
+	case "move", "copy":
+		from, err := parsePointer(operation.From)
+		if err != nil {
+			return nil, err
+		}
+
+		var value any
+		if operation.Op == "copy" {
+			found, err := get(doc, from)
+			if err != nil {
+				return nil, err
+			}
+			if err := remarshal(found, &value); err != nil {
+				return nil, err
+			}
+		} else {
+			// a location cannot be moved into one of its own children
+			if len(from) < len(path) && slices.Equal(from, path[:len(from)]) {
+				return nil, errPatchOperation
+			}
+			if doc, value, err = remove(doc, from); err != nil {
+				return nil, err
+			}
+		}
+		return add(doc, path, value)
+	default:
+		return nil, errPatchOperation
+	}
+}
+
+// parsePointer splits an RFC 6901 JSON pointer into its unescaped reference tokens.
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, errPatchOperation
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+
+	return tokens, nil
+}
+
+// arrayIndex reads a reference token as an index of an array of at most last.
+func arrayIndex(token string, last int) (int, error) {
+	if token == "" || strings.Trim(token, "0123456789") != "" || (len(token) > 1 && token[0] == '0') {
+		return 0, errPatchPath
+	}
+	i, err := strconv.Atoi(token)
+	if err != nil || i > last {
+		return 0, errPatchPath
+	}
+
+	return i, nil
+}
+
+func get(doc any, path []string) (any, error) {
+	for _, token := range path {
+		switch parent := doc.(type) {
+		case map[string]any:
+			child, found := parent[token]
+			if !found {
+				return nil, errPatchPath
+			}
+			doc = child
+		case []any:
+			i, err := arrayIndex(token, len(parent)-1)
+			if err != nil {
+				return nil, err
+			}
+			doc = parent[i]
+		default:
+			return nil, errPatchPath
+		}
+	}
+
+	return doc, nil
+}
+
+// edit changes the parent of the location path points to in doc, a non-empty path, and
+// returns doc along with whatever edit reports.
+func edit(doc any, path []string, change func(parent any, token string) (any, any, error)) (any, any, error) {
+	if len(path) == 1 {
+		return change(doc, path[0])
+	}
+
+	child, err := get(doc, path[:1])
+	if err != nil {
+		return nil, nil, err
+	}
+	changed, reported, err := edit(child, path[1:], change)
+	if err != nil {
+		return nil, nil, err
+	}
+	// arrays are values, so the changed child goes back into its parent
+	switch parent := doc.(type) {
+	case map[string]any:
+		parent[path[0]] = changed
+	case []any:
+		i, _ := arrayIndex(path[0], len(parent)-1)
+		parent[i] = changed
+	}
+
+	return doc, reported, nil
+}
+
+func add(doc any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	doc, _, err := edit(doc, path, func(parent any, token string) (any, any, error) {
+		switch parent := parent.(type) {
+		case map[string]any:
+			parent[token] = value
+			return parent, nil, nil
+		case []any:
+			if token == "-" {
+				return append(parent, value), nil, nil
+			}
+			i, err := arrayIndex(token, len(parent))
+			if err != nil {
+				return nil, nil, err
+			}
+			return slices.Insert(parent, i, value), nil, nil
+		default:
+			return nil, nil, errPatchPath
+		}
+	})
+
+	return doc, err
+}
+
+func replace(doc any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	doc, _, err := edit(doc, path, func(parent any, token string) (any, any, error) {
+		if _, err := get(parent, []string{token}); err != nil {
+			return nil, nil, err
+		}
+		switch parent := parent.(type) {
+		case map[string]any:
+			parent[token] = value
+		case []any:
+			i, _ := arrayIndex(token, len(parent)-1)
+			parent[i] = value
+		}
+		return parent, nil, nil
+	})
+
+	return doc, err
+}
+
+// remove takes the value path points to out of doc, returning both.
+func remove(doc any, path []string) (any, any, error) {
+	if len(path) == 0 {
+		return nil, nil, errPatchPath
+	}
+
+	return edit(doc, path, func(parent any, token string) (any, any, error) {
+		removed, err := get(parent, []string{token})
+		if err != nil {
+			return nil, nil, err
+		}
+		switch parent := parent.(type) {
+		case map[string]any:
+			delete(parent, token)
+			return parent, removed, nil
+		case []any:
+			i, _ := arrayIndex(token, len(parent)-1)
+			return slices.Delete(parent, i, i+1), removed, nil
+		}
+		return nil, nil, errPatchPath
+	})
+}
+
+// remarshal converts from into to through their JSON form.
+func remarshal(from, to any) error {
+	encoded, err := json.Marshal(from)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(encoded, to)
+}
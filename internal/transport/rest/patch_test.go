@@ -0,0 +1,84 @@
+package rest
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONPatch(t *testing.T) {
+	cases := []struct {
+		doc, patch, want string
+		err              error
+	}{
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux"}]`, `{"baz":"qux","foo":"bar"}`, nil},
+		{`{"foo":["bar","baz"]}`, `[{"op":"add","path":"/foo/1","value":"qux"}]`, `{"foo":["bar","qux","baz"]}`, nil},
+		{`{"foo":["bar"]}`, `[{"op":"add","path":"/foo/-","value":["abc"]}]`, `{"foo":["bar",["abc"]]}`, nil},
+		{`{"foo":["bar","qux","baz"]}`, `[{"op":"remove","path":"/foo/1"}]`, `{"foo":["bar","baz"]}`, nil},
+		{`{"baz":"qux","foo":"bar"}`, `[{"op":"replace","path":"/baz","value":null}]`, `{"baz":null,"foo":"bar"}`, nil},
+		{`{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`, `[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`,
+			`{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`, nil},
+		{`{"foo":["all","grass","cows","eat"]}`, `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`, `{"foo":["all","cows","eat","grass"]}`, nil},
+		{`{"foo":{"bar":[1]}}`, `[{"op":"copy","from":"/foo/bar","path":"/baz"},{"op":"add","path":"/baz/-","value":2}]`, `{"baz":[1,2],"foo":{"bar":[1]}}`, nil},
+		{`{"/":9,"~1":10}`, `[{"op":"test","path":"/~01","value":10},{"op":"remove","path":"/~1"}]`, `{"~1":10}`, nil},
+		{`{"baz":"qux"}`, `[{"op":"test","path":"/baz","value":"bar"}]`, "", errPatchTest},
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/baz/bat","value":"qux"}]`, "", errPatchPath},
+		{`{"foo":"bar"}`, `[{"op":"replace","path":"/baz","value":"qux"}]`, "", errPatchPath},
+		{`{"foo":["bar"]}`, `[{"op":"remove","path":"/foo/01"}]`, "", errPatchPath},
+		{`{"foo":["bar"]}`, `[{"op":"add","path":"/foo/2","value":"qux"}]`, "", errPatchPath},
+		{`{"foo":{"bar":1}}`, `[{"op":"move","from":"/foo","path":"/foo/bar"}]`, "", errPatchOperation},
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/baz"}]`, "", errPatchOperation},
+		{`{"foo":"bar"}`, `[{"op":"rename","path":"/foo"}]`, "", errPatchOperation},
+		{`{"foo":"bar"}`, `[{"op":"remove","path":"foo"}]`, "", errPatchOperation},
+	}
+
+	for _, c := range cases {
+		var doc any
+		require.NoError(t, json.Unmarshal([]byte(c.doc), &doc))
+		var operations []patchOperation
+		require.NoError(t, json.Unmarshal([]byte(c.patch), &operations))
+
+		var err error
+		for _, operation := range operations {
+			if doc, err = operation.apply(doc); err != nil {
+				break
+			}
+		}
+		if c.err != nil {
+			assert.ErrorIs(t, err, c.err, c.patch)
+			continue
+		}
+		require.NoError(t, err, c.patch)
+		got, err := json.Marshal(doc)
+		require.NoError(t, err)
+		assert.JSONEq(t, c.want, string(got), c.patch)
+	}
+}
+
+func TestMergePatch(t *testing.T) {
+	var doc, patch any
+	require.NoError(t, json.Unmarshal([]byte(`{"a":"b","c":{"d":"e","f":"g"},"h":[1]}`), &doc))
+	require.NoError(t, json.Unmarshal([]byte(`{"a":"z","c":{"f":null},"h":{"i":1}}`), &patch))
+
+	got, err := json.Marshal(mergePatch(doc, patch))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"a":"z","c":{"d":"e"},"h":{"i":1}}`, string(got))
+}
+
+func TestConforms(t *testing.T) {
+	var template any
+	require.NoError(t, json.Unmarshal([]byte(`{"title":"","tags":[],"checklist":[{"text":"","done":false}],"due_at":null}`), &template))
+
+	for doc, want := range map[string]bool{
+		`{"title":"x","tags":["a"],"checklist":[{"text":"y"}],"due_at":"2024-05-06T00:00:00Z"}`: true,
+		`{"title":{"nested":1}}`:             true,
+		`{"titel":"x"}`:                      false,
+		`{"checklist":[{"text":"y","x":1}]}`: false,
+	} {
+		var parsed any
+		require.NoError(t, json.Unmarshal([]byte(doc), &parsed))
+		assert.Equal(t, want, conforms(parsed, template), doc)
+	}
+}
@@ -0,0 +1,69 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/scriptdealer/to-do-go/internal/services"
+	"github.com/scriptdealer/to-do-go/known"
+)
+
+// problem is an RFC 7807 error body.
+type problem struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	// Current is the state of the resource a request naming an outdated one was refused against.
+	Current any `json:"current,omitempty"`
+}
+
+// statusOf maps the error kinds of the known package to HTTP status codes,
+// any other error is an internal one.
+func statusOf(err error) int {
+	switch {
+	case errors.Is(err, errPatchMediaType):
+		return http.StatusUnsupportedMediaType
+	case errors.Is(err, services.ErrIdempotencyKeyReused):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, known.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, known.ErrValidation):
+		return http.StatusBadRequest
+	case errors.Is(err, known.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, errIfMatchRequired):
+		return http.StatusPreconditionRequired
+	case errors.Is(err, known.ErrPrecondition):
+		return http.StatusPreconditionFailed
+	case errors.Is(err, known.ErrUnauthorized):
+		return http.StatusUnauthorized
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func (rest *RESTful) respondWithProblem(w http.ResponseWriter, err error) {
+	rest.writeProblem(w, statusOf(err), err, nil)
+}
+
+// writeProblem answers with the given status, along with the current state of the resource if any.
+func (rest *RESTful) writeProblem(w http.ResponseWriter, status int, err error, current any) {
+	reply := problem{Type: "about:blank", Title: http.StatusText(status), Status: status, Detail: err.Error(), Current: current}
+	if status == http.StatusInternalServerError {
+		// storage and driver messages are not meant for clients
+		rest.serviceLayer.Log.Error("request failed", slog.String("reason", err.Error()))
+		reply.Detail = known.ErrInternal.Error()
+	}
+	if status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+	}
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(reply); err != nil {
+		rest.serviceLayer.Log.Warn("failed to respond", slog.String("reason", err.Error()))
+	}
+}
@@ -0,0 +1,40 @@
+package rest
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/scriptdealer/to-do-go/known"
+)
+
+// Reminders serves the reminders of an item by their firing time, the sent ones included.
+func (rest *RESTful) Reminders(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	reminders, err := rest.serviceLayer.Reminders.GetAll(principal(r).UserID, id)
+	rest.respondWith(w, reminders, err)
+}
+
+// AddReminder reminds of an item at {"at": time} or {"minutes_before": n} its due date.
+func (rest *RESTful) AddReminder(w http.ResponseWriter, r *http.Request) {
+	var data ReminderRequest
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	if err := decodeBody(r, &data); err != nil {
+		rest.respondWith(w, nil, err)
+		return
+	}
+
+	rest.serviceLayer.Log.Info("adding reminder", slog.Int("id", id))
+	reminder, err := rest.serviceLayer.Reminders.Create(principal(r).UserID, id, known.Reminder{At: data.At, MinutesBefore: data.MinutesBefore})
+	rest.respondWith(w, reminder, err)
+}
+
+func (rest *RESTful) DeleteReminder(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
+	reminder, _ := strconv.Atoi(vars["reminder"])
+	rest.serviceLayer.Log.Info("deleting reminder", slog.Int("id", id), slog.Int("reminder", reminder))
+	err := rest.serviceLayer.Reminders.Delete(principal(r).UserID, id, reminder)
+	rest.respondWith(w, nil, err)
+}
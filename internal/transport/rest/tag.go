@@ -0,0 +1,55 @@
+package rest
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+func (rest *RESTful) AllTags(w http.ResponseWriter, r *http.Request) {
+	tags, err := rest.serviceLayer.Tags.GetAll(principal(r).UserID)
+	rest.respondWith(w, tags, err)
+}
+
+func (rest *RESTful) AddTag(w http.ResponseWriter, r *http.Request) {
+	var data TagRequest
+	if err := decodeBody(r, &data); err != nil {
+		rest.respondWith(w, nil, err)
+		return
+	}
+
+	tag, err := rest.serviceLayer.Tags.Create(principal(r).UserID, data.Name)
+	rest.respondWith(w, tag, err)
+}
+
+func (rest *RESTful) RenameTag(w http.ResponseWriter, r *http.Request) {
+	var data TagRequest
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	err := decodeBody(r, &data)
+	if err == nil {
+		rest.serviceLayer.Log.Info("renaming tag", slog.Int("id", id), slog.String("name", data.Name))
+		err = rest.serviceLayer.Tags.Rename(principal(r).UserID, id, data.Name)
+	}
+	rest.respondWith(w, nil, err)
+}
+
+// MergeTag relabels the items of a tag with the tag {"into": id} and deletes the former.
+func (rest *RESTful) MergeTag(w http.ResponseWriter, r *http.Request) {
+	var data MergeRequest
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	err := decodeBody(r, &data)
+	if err == nil {
+		rest.serviceLayer.Log.Info("merging tags", slog.Int("from", id), slog.Int("into", data.Into))
+		err = rest.serviceLayer.Tags.Merge(principal(r).UserID, id, data.Into)
+	}
+	rest.respondWith(w, nil, err)
+}
+
+func (rest *RESTful) DeleteTag(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	rest.serviceLayer.Log.Info("deleting tag", slog.Int("id", id))
+	err := rest.serviceLayer.Tags.Delete(principal(r).UserID, id)
+	rest.respondWith(w, nil, err)
+}
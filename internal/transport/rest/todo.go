@@ -2,109 +2,351 @@ package rest
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"log/slog"
 	"net/http"
 	"strconv"
-	"strings"
 
 	"github.com/gorilla/mux"
+	"github.com/scriptdealer/to-do-go/internal/services"
 	"github.com/scriptdealer/to-do-go/known"
 )
 
 func (rest *RESTful) AllItems(w http.ResponseWriter, r *http.Request) {
 	defer rest.LogRecover()
-	todos, err := rest.serviceLayer.ToDos.GetAll(r.Context())
-	rest.serviceLayer.Log.Info("serving AllItems", slog.Int("count", len(todos)))
-	rest.respondWith(w, todos, err)
+	query, err := parseTodoQuery(r.URL.Query())
+	if err != nil {
+		rest.respondWith(w, nil, err)
+		return
+	}
+
+	page, err := rest.serviceLayer.ToDos.GetAll(r.Context(), principal(r).UserID, query)
+	if err != nil {
+		rest.respondWith(w, nil, err)
+		return
+	}
+	rest.serviceLayer.Log.Info("serving AllItems", slog.Int("count", len(page.Items)))
+	rest.respondWithPage(w, page)
 }
 
 func (rest *RESTful) GetItem(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, _ := strconv.Atoi(vars["id"])
-	todo, err := rest.serviceLayer.ToDos.Get(id)
+	todo, err := rest.serviceLayer.ToDos.Get(principal(r).UserID, id)
+	if err == nil {
+		w.Header().Set("ETag", etag(todo.Version))
+	}
 	rest.respondWith(w, todo, err)
 }
 
+// Subtasks is the listing of /todo narrowed down to the direct subtasks of an item.
+func (rest *RESTful) Subtasks(w http.ResponseWriter, r *http.Request) {
+	query, err := parseTodoQuery(r.URL.Query())
+	if err != nil {
+		rest.respondWith(w, nil, err)
+		return
+	}
+	query.ParentID, _ = strconv.Atoi(mux.Vars(r)["id"])
+	if query.ParentID < 1 {
+		rest.respondWith(w, nil, errUnknownItem)
+		return
+	}
+
+	page, err := rest.serviceLayer.ToDos.GetAll(r.Context(), principal(r).UserID, query)
+	if err != nil {
+		rest.respondWith(w, nil, err)
+		return
+	}
+	rest.serviceLayer.Log.Info("serving subtasks", slog.Int("parent", query.ParentID), slog.Int("count", len(page.Items)))
+	rest.respondWithPage(w, page)
+}
+
+// Occurrences lists the items of the series a recurring item belongs to, taking the parameters of /todo.
+func (rest *RESTful) Occurrences(w http.ResponseWriter, r *http.Request) {
+	query, err := parseTodoQuery(r.URL.Query())
+	if err != nil {
+		rest.respondWith(w, nil, err)
+		return
+	}
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	page, err := rest.serviceLayer.ToDos.Occurrences(r.Context(), principal(r).UserID, id, query)
+	if err != nil {
+		rest.respondWith(w, nil, err)
+		return
+	}
+	rest.serviceLayer.Log.Info("serving occurrences", slog.Int("id", id), slog.Int("count", len(page.Items)))
+	rest.respondWithPage(w, page)
+}
+
+// FilterByStatus is a shortcut for the listing with ?done=, it takes the same paging parameters.
 func (rest *RESTful) FilterByStatus(w http.ResponseWriter, r *http.Request) {
-	todos, err := rest.serviceLayer.ToDos.GetAll(r.Context())
-	vars := mux.Vars(r)
-	status := vars["selector"]
+	query, err := parseTodoQuery(r.URL.Query())
+	if err == nil {
+		query.Done, err = parseStatus(mux.Vars(r)["selector"])
+	}
+	if err != nil {
+		rest.respondWith(w, nil, err)
+		return
+	}
 
-	result := []known.TodoItem{}
-	for i := range todos {
-		if todos[i].Done && status == "done" {
-			result = append(result, *todos[i])
-		}
+	page, err := rest.serviceLayer.ToDos.GetAll(r.Context(), principal(r).UserID, query)
+	if err != nil {
+		rest.respondWith(w, nil, err)
+		return
+	}
+	rest.serviceLayer.Log.Info("serving filtered items", slog.Int("count", len(page.Items)))
+	rest.respondWithPage(w, page)
+}
 
-		if !todos[i].Done && status == "active" {
-			result = append(result, *todos[i])
-		}
+// DueItems serves the overdue, today and week views in the time zone of the caller.
+func (rest *RESTful) DueItems(w http.ResponseWriter, r *http.Request) {
+	query, err := parseTodoQuery(r.URL.Query())
+	if err != nil {
+		rest.respondWith(w, nil, err)
+		return
+	}
+
+	caller := principal(r)
+	user, err := rest.serviceLayer.Users.Get(caller.UserID)
+	if err != nil {
+		rest.respondWith(w, nil, err)
+		return
 	}
-	rest.serviceLayer.Log.Info("serving filtered items", slog.Int("count", len(result)))
-	rest.respondWith(w, result, err)
+
+	page, err := rest.serviceLayer.ToDos.GetDue(r.Context(), caller.UserID, mux.Vars(r)["view"], user.Location(), query)
+	if err != nil {
+		rest.respondWith(w, nil, err)
+		return
+	}
+	rest.serviceLayer.Log.Info("serving due items", slog.Int("count", len(page.Items)))
+	rest.respondWithPage(w, page)
 }
 
 func (rest *RESTful) AddItem(w http.ResponseWriter, r *http.Request) {
 	var data TodoPatchRequest
-	reqBody, _ := io.ReadAll(r.Body)
-	_ = json.Unmarshal(reqBody, &data)
+	err := decodeBody(r, &data)
 	rest.serviceLayer.Log.Info("adding item", slog.String("body", fmt.Sprintf("%+v", data)))
 
-	err := data.Validate()
 	if err == nil {
-		err = rest.serviceLayer.ToDos.Create(data.Title, data.Description, data.Done)
+		err = data.Validate()
 	}
-	rest.respondWith(w, nil, err)
+	var item *known.TodoItem
+	if err == nil {
+		item, err = rest.serviceLayer.ToDos.Create(principal(r).UserID, data.item())
+	}
+	rest.respondWithCreatedItem(w, item, err)
 }
 
+// UpdateItem changes the fields a patch sets and keeps the others: an RFC 7396 merge patch,
+// which plain JSON is read as, or an RFC 6902 JSON patch, by the Content-Type of the body.
 func (rest *RESTful) UpdateItem(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	owner := principal(r).UserID
+	opts, err := parseUpdateOptions(r.URL.Query())
+	if err != nil {
+		rest.respondWith(w, nil, err)
+		return
+	}
+	version, header, err := ifMatch(r)
+	if err != nil {
+		rest.respondWith(w, nil, err)
+		return
+	}
+
+	current, err := rest.serviceLayer.ToDos.Get(owner, id)
+	if err != nil {
+		rest.respondWith(w, nil, err)
+		return
+	}
+	// the patch is not applied to a state other than the one it was made against
+	if header && version != 0 && version != current.Version {
+		rest.respondWithStale(w, r, id, header, errItemChanged)
+		return
+	}
+
+	data, versioned, err := patchItem(r, current)
+	if errors.Is(err, errPatchMediaType) {
+		w.Header().Set("Accept-Patch", acceptPatch)
+	}
+	if err == nil && !header {
+		var body int
+		if versioned {
+			body = data.Version
+		}
+		version, _, err = rest.precondition(r, body)
+	}
+	if err == nil {
+		err = data.Validate()
+	}
+	if err != nil {
+		rest.respondWith(w, nil, err)
+		return
+	}
+
+	item := data.item()
+	item.Version = version
+	if item.Version == 0 {
+		// nor is it when the item changes after it was read
+		item.Version = current.Version
+	}
+	rest.serviceLayer.Log.Info("patching item", slog.Int("id", id), slog.String("with", fmt.Sprintf("%+v", data)))
+	updated, err := rest.serviceLayer.ToDos.Update(owner, id, item, opts)
+	if errors.Is(err, known.ErrPrecondition) {
+		rest.respondWithStale(w, r, id, header, err)
+		return
+	}
+	rest.respondWithUpdated(w, updated, err)
+}
+
+// ReplaceItem replaces the item with the one in the body, the omitted fields being emptied.
+func (rest *RESTful) ReplaceItem(w http.ResponseWriter, r *http.Request) {
 	var data TodoPatchRequest
 	vars := mux.Vars(r)
 	id, _ := strconv.Atoi(vars["id"])
-	err := json.NewDecoder(r.Body).Decode(&data)
+	opts, err := parseUpdateOptions(r.URL.Query())
+	if err == nil {
+		err = decodeBody(r, &data)
+	}
+	if err == nil {
+		err = data.Validate()
+	}
+	item := data.item()
+	var header bool
+	if err == nil {
+		item.Version, header, err = rest.precondition(r, data.Version)
+	}
+	var updated *known.TodoItem
 	if err == nil {
 		rest.serviceLayer.Log.Info("updating item", slog.Int("id", id), slog.String("with", fmt.Sprintf("%+v", data)))
-		err = rest.serviceLayer.ToDos.Update(id, data.Title, data.Description, data.Done)
+		updated, err = rest.serviceLayer.ToDos.Update(principal(r).UserID, id, item, opts)
+		if errors.Is(err, known.ErrPrecondition) {
+			rest.respondWithStale(w, r, id, header, err)
+			return
+		}
 	}
-	rest.respondWith(w, nil, err)
+	rest.respondWithUpdated(w, updated, err)
 }
 
-func (rest *RESTful) DeleteItem(w http.ResponseWriter, r *http.Request) {
-	if ok := rest.authCheck(r); !ok {
-		rest.respondWith(w, nil, fmt.Errorf("not authorized"))
+// MoveItem reorders an item, taking {"before": id} or {"after": id} of another item,
+// moves it into another list with {"list": id} or under another item with {"parent": id}.
+func (rest *RESTful) MoveItem(w http.ResponseWriter, r *http.Request) {
+	var data MoveRequest
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	err := decodeBody(r, &data)
+	if err != nil {
+		rest.respondWith(w, nil, err)
 		return
 	}
+
+	if data.List != nil || data.Parent != nil {
+		err = errInvalidMove
+		switch {
+		case data.Before != 0 || data.After != 0 || data.List != nil && data.Parent != nil:
+			// mixed targets are invalid
+		case data.List != nil:
+			rest.serviceLayer.Log.Info("moving item to list", slog.Int("id", id), slog.Int("list", *data.List))
+			err = rest.serviceLayer.ToDos.MoveToList(principal(r).UserID, id, *data.List)
+		default:
+			rest.serviceLayer.Log.Info("moving item to parent", slog.Int("id", id), slog.Int("parent", *data.Parent))
+			err = rest.serviceLayer.ToDos.SetParent(principal(r).UserID, id, *data.Parent)
+		}
+		rest.respondWith(w, nil, err)
+		return
+	}
+
+	anchor, before, err := data.anchor()
+	if err == nil {
+		rest.serviceLayer.Log.Info("moving item", slog.Int("id", id), slog.Int("anchor", anchor), slog.Bool("before", before))
+		err = rest.serviceLayer.ToDos.Move(principal(r).UserID, id, anchor, before)
+	}
+	rest.respondWith(w, nil, err)
+}
+
+// DeleteItem moves an item to the trash, refusing one with subtasks unless asked with ?cascade=true.
+func (rest *RESTful) DeleteItem(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, _ := strconv.Atoi(vars["id"])
-	rest.serviceLayer.Log.Info("deleting item", slog.Int("id", id))
-	err := rest.serviceLayer.ToDos.Delete(id)
+	var opts services.DeleteOptions
+	var header bool
+	cascade, err := parseFlag(r.URL.Query(), "cascade")
+	if err == nil {
+		opts.Cascade = cascade
+		opts.Version, header, err = rest.precondition(r, 0)
+	}
+	if err == nil {
+		rest.serviceLayer.Log.Info("deleting item", slog.Int("id", id), slog.Bool("cascade", cascade))
+		err = rest.serviceLayer.ToDos.Delete(principal(r).UserID, id, opts)
+		if errors.Is(err, known.ErrPrecondition) {
+			rest.respondWithStale(w, r, id, header, err)
+			return
+		}
+	}
 	rest.respondWith(w, nil, err)
 }
 
-func (rest *RESTful) authCheck(r *http.Request) bool {
-	authHeader := r.Header.Get("Authorization")
-	authPrefix := "Bearer "
+func (rest *RESTful) respondWithPage(w http.ResponseWriter, page *known.TodoPage) {
+	w.Header().Set("Content-Type", "application/json")
+	reply := apiResponse{Success: true, Data: page.Items, Page: newPageInfo(page)}
+	err := json.NewEncoder(w).Encode(reply)
+	if err != nil {
+		rest.serviceLayer.Log.Warn("failed to respond", slog.String("reason", err.Error()))
+	}
+}
 
-	if !strings.HasPrefix(authHeader, authPrefix) {
-		return false
+// respondWithCreated answers 201 with what was just created and where it is.
+func (rest *RESTful) respondWithCreated(w http.ResponseWriter, location string, data any, err error) {
+	if err != nil {
+		rest.respondWithProblem(w, err)
+		return
 	}
 
-	authKey := authHeader[len(authPrefix):]
+	w.Header().Set("Location", location)
+	rest.respondWithStatus(w, http.StatusCreated, data, nil)
+}
 
-	return authKey == rest.staticAPIKey
+// respondWithCreatedItem answers 201 with the item just created, where it is and its ETag.
+func (rest *RESTful) respondWithCreatedItem(w http.ResponseWriter, item *known.TodoItem, err error) {
+	if err != nil {
+		rest.respondWithProblem(w, err)
+		return
+	}
+
+	w.Header().Set("ETag", etag(item.Version))
+	rest.respondWithCreated(w, itemLocation(item.ID), item, nil)
 }
 
-func (rest *RESTful) respondWith(w io.Writer, data any, err error) {
-	reply := apiResponse{}
-	if err == nil {
-		reply.Success = true
-		reply.Data = data
-	} else {
-		reply.Error = err.Error()
+// respondWithUpdated answers with the item as updated and its ETag.
+func (rest *RESTful) respondWithUpdated(w http.ResponseWriter, item *known.TodoItem, err error) {
+	if err != nil {
+		rest.respondWithProblem(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Location", itemLocation(item.ID))
+	w.Header().Set("ETag", etag(item.Version))
+	rest.respondWith(w, item, nil)
+}
+
+func itemLocation(id int) string {
+	return "/todo/" + strconv.Itoa(id)
+}
+
+// respondWith writes data in the apiResponse envelope, or the error as a problem.
+func (rest *RESTful) respondWith(w http.ResponseWriter, data any, err error) {
+	rest.respondWithStatus(w, http.StatusOK, data, err)
+}
+
+func (rest *RESTful) respondWithStatus(w http.ResponseWriter, status int, data any, err error) {
+	if err != nil {
+		rest.respondWithProblem(w, err)
+		return
 	}
-	err = json.NewEncoder(w).Encode(reply)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	err = json.NewEncoder(w).Encode(apiResponse{Success: true, Data: data})
 	if err != nil {
 		rest.serviceLayer.Log.Warn("failed to respond", slog.String("reason", err.Error()))
 	}
@@ -0,0 +1,42 @@
+package rest
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// Trash lists the deleted items, the most recently deleted first, taking the parameters of /todo.
+func (rest *RESTful) Trash(w http.ResponseWriter, r *http.Request) {
+	query, err := parseTodoQuery(r.URL.Query())
+	if err != nil {
+		rest.respondWith(w, nil, err)
+		return
+	}
+
+	page, err := rest.serviceLayer.ToDos.GetTrash(r.Context(), principal(r).UserID, query)
+	if err != nil {
+		rest.respondWith(w, nil, err)
+		return
+	}
+	rest.serviceLayer.Log.Info("serving trash", slog.Int("count", len(page.Items)))
+	rest.respondWithPage(w, page)
+}
+
+// RestoreItem takes an item out of the trash along with the subtasks deleted with it.
+func (rest *RESTful) RestoreItem(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	rest.serviceLayer.Log.Info("restoring item", slog.Int("id", id))
+	err := rest.serviceLayer.ToDos.Restore(principal(r).UserID, id)
+	rest.respondWith(w, nil, err)
+}
+
+// PurgeItem deletes a trashed item and its subtasks for good.
+func (rest *RESTful) PurgeItem(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	rest.serviceLayer.Log.Info("purging item", slog.Int("id", id))
+	err := rest.serviceLayer.ToDos.Purge(principal(r).UserID, id)
+	rest.respondWith(w, nil, err)
+}
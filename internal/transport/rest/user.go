@@ -0,0 +1,86 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+)
+
+func (rest *RESTful) Register(w http.ResponseWriter, r *http.Request) {
+	var data SignupRequest
+	err := json.NewDecoder(r.Body).Decode(&data)
+	if err != nil {
+		rest.respondWith(w, nil, errMalformedBody)
+		return
+	}
+
+	user, err := rest.serviceLayer.Users.Register(data.Name, data.Username, data.Password)
+	if err == nil {
+		rest.serviceLayer.Log.Info("registered user", slog.Int("id", user.ID), slog.String("username", user.Username))
+	}
+	rest.respondWithCreated(w, "/users/me", user, err)
+}
+
+func (rest *RESTful) Login(w http.ResponseWriter, r *http.Request) {
+	var data LoginRequest
+	err := json.NewDecoder(r.Body).Decode(&data)
+	if err != nil {
+		rest.respondWith(w, nil, errMalformedBody)
+		return
+	}
+
+	tokens, err := rest.serviceLayer.Users.Login(data.Username, data.Password)
+	if err != nil {
+		rest.serviceLayer.Log.Info("login failed", slog.String("username", data.Username))
+		rest.respondWith(w, nil, err)
+		return
+	}
+	rest.respondWith(w, tokens, nil)
+}
+
+func (rest *RESTful) Refresh(w http.ResponseWriter, r *http.Request) {
+	var data RefreshRequest
+	err := json.NewDecoder(r.Body).Decode(&data)
+	if err != nil {
+		rest.respondWith(w, nil, errMalformedBody)
+		return
+	}
+
+	tokens, err := rest.serviceLayer.Users.Refresh(data.RefreshToken)
+	rest.respondWith(w, tokens, err)
+}
+
+// Logout accepts an optional body with the refresh token to revoke along with the access token.
+func (rest *RESTful) Logout(w http.ResponseWriter, r *http.Request) {
+	var data RefreshRequest
+	err := json.NewDecoder(r.Body).Decode(&data)
+	if err != nil && !errors.Is(err, io.EOF) {
+		rest.respondWith(w, nil, errMalformedBody)
+		return
+	}
+
+	caller := principal(r)
+	err = rest.serviceLayer.Users.Logout(caller, data.RefreshToken)
+	if err == nil {
+		rest.serviceLayer.Log.Info("logged out", slog.Int("user", caller.UserID))
+	}
+	rest.respondWith(w, nil, err)
+}
+
+func (rest *RESTful) Me(w http.ResponseWriter, r *http.Request) {
+	user, err := rest.serviceLayer.Users.Get(principal(r).UserID)
+	rest.respondWith(w, user, err)
+}
+
+func (rest *RESTful) UpdateMe(w http.ResponseWriter, r *http.Request) {
+	var data ProfileRequest
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		rest.respondWith(w, nil, errMalformedBody)
+		return
+	}
+
+	user, err := rest.serviceLayer.Users.UpdateProfile(principal(r).UserID, data.Name, data.TimeZone, data.Email)
+	rest.respondWith(w, user, err)
+}
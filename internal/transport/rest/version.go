@@ -0,0 +1,72 @@
+package rest
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/scriptdealer/to-do-go/known"
+)
+
+var (
+	errIfMatch         = known.NewError(known.ErrValidation, `If-Match takes the ETag of the item, like "3", or *`)
+	errIfMatchRequired = known.NewError(known.ErrPrecondition, "changing an item takes its ETag in If-Match or its version")
+	errItemChanged     = known.NewError(known.ErrPrecondition, "the item has changed since that version, fetch it again")
+)
+
+// etag is the strong entity tag of a version of an item.
+func etag(version int) string {
+	return strconv.Quote(strconv.Itoa(version))
+}
+
+// ifMatch reads the version If-Match names, reporting whether there is one; * matches any version.
+func ifMatch(r *http.Request) (int, bool, error) {
+	value := strings.TrimSpace(r.Header.Get("If-Match"))
+	switch value {
+	case "":
+		return 0, false, nil
+	case "*":
+		return 0, true, nil
+	}
+
+	// weak tags never match If-Match, which compares strongly
+	unquoted, opened := strings.CutPrefix(value, `"`)
+	unquoted, closed := strings.CutSuffix(unquoted, `"`)
+	version, err := strconv.Atoi(unquoted)
+	if !opened || !closed || err != nil || version < 1 {
+		return 0, false, errIfMatch
+	}
+
+	return version, true, nil
+}
+
+// precondition reads the version an update or a deletion must find, from If-Match or else
+// from the body, reporting whether it came from the header.
+func (rest *RESTful) precondition(r *http.Request, body int) (int, bool, error) {
+	version, header, err := ifMatch(r)
+	switch {
+	case err != nil || header:
+		return version, header, err
+	case body == 0 && rest.serviceLayer.Config.Server.RequireIfMatch:
+		return 0, false, errIfMatchRequired
+	default:
+		return body, false, nil
+	}
+}
+
+// respondWithStale answers a change made against an outdated version with the current state of the
+// item and its ETag: 412 when the version came from If-Match, 409 when it came from the body.
+func (rest *RESTful) respondWithStale(w http.ResponseWriter, r *http.Request, id int, header bool, err error) {
+	current, failure := rest.serviceLayer.ToDos.Get(principal(r).UserID, id)
+	if failure != nil {
+		rest.respondWith(w, nil, failure)
+		return
+	}
+
+	status := http.StatusConflict
+	if header {
+		status = http.StatusPreconditionFailed
+	}
+	w.Header().Set("ETag", etag(current.Version))
+	rest.writeProblem(w, status, err, current)
+}
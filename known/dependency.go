@@ -0,0 +1,8 @@
+package known
+
+// Dependency is an edge of the dependency graph: the item ItemID is blocked by BlockerID
+// and should not be done before it.
+type Dependency struct {
+	ItemID    int `json:"item_id"`
+	BlockerID int `json:"blocker_id"`
+}
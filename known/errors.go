@@ -0,0 +1,33 @@
+package known
+
+import "errors"
+
+// Error kinds shared by every layer; the transport maps each of them to a status code.
+// Match them with errors.Is, concrete errors wrap one kind with a human readable message.
+var (
+	ErrNotFound     = errors.New("not found")
+	ErrValidation   = errors.New("validation failed")
+	ErrConflict     = errors.New("conflict")
+	ErrUnauthorized = errors.New("not authorized")
+	ErrInternal     = errors.New("internal error")
+	// ErrPrecondition refuses a change made against a state that is no longer the current one.
+	ErrPrecondition = errors.New("precondition failed")
+)
+
+// Error is an error of a known kind; its message is safe to show to clients.
+type Error struct {
+	Kind    error
+	Message string
+}
+
+func NewError(kind error, message string) *Error {
+	return &Error{Kind: kind, Message: message}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Kind
+}
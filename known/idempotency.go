@@ -0,0 +1,16 @@
+package known
+
+import "time"
+
+// IdempotentRequest is a request made with an idempotency key, kept along with the response to it
+// until ExpiresAt, so that retries of the request get the same response. Fingerprint tells the request
+// apart from others made with the same key; Status is zero while the request is being processed.
+type IdempotentRequest struct {
+	OwnerID     int
+	Key         string
+	Fingerprint string
+	Status      int
+	Header      map[string]string
+	Body        []byte
+	ExpiresAt   time.Time
+}
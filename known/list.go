@@ -0,0 +1,13 @@
+package known
+
+// List is a named project grouping todo items of its owner; items outside
+// of any list are in the inbox. An archived list and its items are hidden from listings.
+type List struct {
+	ID       int    `json:"id" db:"id"`
+	OwnerID  int    `json:"-" db:"owner_id"`
+	Name     string `json:"name" db:"name"`
+	Archived bool   `json:"archived" db:"archived"`
+	// Open and Done count the items of the list in either state.
+	Open int `json:"open_items"`
+	Done int `json:"done_items"`
+}
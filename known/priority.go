@@ -0,0 +1,52 @@
+package known
+
+import "strings"
+
+// Priority ranks items from none to urgent; it travels as its name in JSON.
+type Priority int
+
+const (
+	PriorityNone Priority = iota
+	PriorityLow
+	PriorityMedium
+	PriorityHigh
+	PriorityUrgent
+)
+
+var priorityNames = []string{"none", "low", "medium", "high", "urgent"}
+
+var errUnknownPriority = NewError(ErrValidation, "unknown priority, expected none, low, medium, high or urgent")
+
+func (p Priority) String() string {
+	if p < PriorityNone || p > PriorityUrgent {
+		return "unknown"
+	}
+
+	return priorityNames[p]
+}
+
+func (p Priority) MarshalText() ([]byte, error) {
+	if p < PriorityNone || p > PriorityUrgent {
+		return nil, errUnknownPriority
+	}
+
+	return []byte(p.String()), nil
+}
+
+// UnmarshalText takes a priority name, an empty one means none.
+func (p *Priority) UnmarshalText(text []byte) error {
+	name := strings.ToLower(string(text))
+	if name == "" {
+		*p = PriorityNone
+		return nil
+	}
+
+	for i, candidate := range priorityNames {
+		if name == candidate {
+			*p = Priority(i)
+			return nil
+		}
+	}
+
+	return errUnknownPriority
+}
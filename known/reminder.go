@@ -0,0 +1,35 @@
+package known
+
+import "time"
+
+// Reminder goes off once, at an absolute time or some minutes before the due date of its item.
+type Reminder struct {
+	ID      int `json:"id" db:"id"`
+	TodoID  int `json:"todo_id" db:"todo_id"`
+	OwnerID int `json:"-"`
+	// Exactly one of At and MinutesBefore is set.
+	At            *time.Time `json:"at,omitempty" db:"remind_at"`
+	MinutesBefore *int       `json:"minutes_before,omitempty" db:"minutes_before"`
+	// FireAt is when the reminder goes off; it follows the due date of the item
+	// and is nil for an offset from an item without one.
+	FireAt *time.Time `json:"fire_at,omitempty" db:"fire_at"`
+	// SentAt is when the reminder was delivered.
+	SentAt *time.Time `json:"sent_at,omitempty" db:"sent_at"`
+	// ClaimedAt is when a server took the reminder up for delivery, nil when none did.
+	ClaimedAt *time.Time `json:"-" db:"claimed_at"`
+	Attempts  int        `json:"-" db:"attempts"`
+}
+
+// Resolve sets FireAt out of At or MinutesBefore and the due date of the item.
+func (r *Reminder) Resolve(due *time.Time) {
+	switch {
+	case r.At != nil:
+		at := r.At.UTC()
+		r.FireAt = &at
+	case r.MinutesBefore != nil && due != nil:
+		at := due.UTC().Add(-time.Duration(*r.MinutesBefore) * time.Minute)
+		r.FireAt = &at
+	default:
+		r.FireAt = nil
+	}
+}
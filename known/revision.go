@@ -0,0 +1,126 @@
+package known
+
+import (
+	"bytes"
+	"encoding/json"
+	"slices"
+	"time"
+)
+
+// Revision actions; deleting and restoring an item change none of its fields.
+const (
+	RevisionCreate  = "create"
+	RevisionUpdate  = "update"
+	RevisionDelete  = "delete"
+	RevisionRestore = "restore"
+)
+
+// Revision is an immutable record of one change of an item: who made it, when, and the fields it changed.
+type Revision struct {
+	ID      int       `json:"id" db:"id"`
+	TodoID  int       `json:"todo_id" db:"todo_id"`
+	ActorID int       `json:"actor_id" db:"actor_id"`
+	Action  string    `json:"action" db:"action"`
+	Created time.Time `json:"created_at" db:"created_at"`
+	Changes []Change  `json:"changes,omitempty" db:"changes"`
+}
+
+// Change holds a field of an item before and after a revision, by its JSON name and in its JSON form;
+// From is absent for a created item.
+type Change struct {
+	Field string          `json:"field"`
+	From  json.RawMessage `json:"from,omitempty"`
+	To    json.RawMessage `json:"to,omitempty"`
+}
+
+type field struct {
+	name  string
+	value any
+}
+
+// revisable lists the fields an update replaces by their JSON names, in a form that compares
+// the same whichever backend the item was read from.
+func revisable(item *TodoItem) []field {
+	utc := func(t *time.Time) *time.Time {
+		if t == nil {
+			return nil
+		}
+		value := t.UTC()
+		return &value
+	}
+	var tags []string
+	if len(item.Tags) > 0 {
+		tags = slices.Clone(item.Tags)
+		slices.Sort(tags)
+	}
+	var checklist []ChecklistEntry
+	if len(item.Checklist) > 0 {
+		checklist = item.Checklist
+	}
+
+	return []field{
+		{"title", item.Title},
+		{"description", item.Description},
+		{"done", item.Done},
+		{"priority", item.Priority},
+		{"start_at", utc(item.Start)},
+		{"due_at", utc(item.Due)},
+		{"recurrence", item.Recurrence},
+		{"tags", tags},
+		{"checklist", checklist},
+	}
+}
+
+// Diff lists the fields an update replaces that differ between before and after,
+// all of them for a nil before.
+func Diff(before, after *TodoItem) ([]Change, error) {
+	var old []field
+	if before != nil {
+		old = revisable(before)
+	}
+
+	var changes []Change
+	for i, field := range revisable(after) {
+		to, err := json.Marshal(field.value)
+		if err != nil {
+			return nil, err
+		}
+		if old == nil {
+			changes = append(changes, Change{Field: field.name, To: to})
+			continue
+		}
+
+		from, err := json.Marshal(old[i].value)
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(from, to) {
+			changes = append(changes, Change{Field: field.name, From: from, To: to})
+		}
+	}
+
+	return changes, nil
+}
+
+// Rewind sets the fields of item back to what they were before the given revisions,
+// which are in the order they were made in.
+func Rewind(item *TodoItem, revisions []*Revision) error {
+	fields := map[string]json.RawMessage{}
+	for i := len(revisions) - 1; i >= 0; i-- {
+		for _, change := range revisions[i].Changes {
+			if change.From != nil {
+				fields[change.Field] = change.From
+			}
+		}
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+
+	encoded, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(encoded, item)
+}
@@ -0,0 +1,11 @@
+package known
+
+// Tag labels todo items of its owner; items refer to tags by ID,
+// so renaming a tag relabels all of its items at once.
+type Tag struct {
+	ID      int    `json:"id" db:"id"`
+	OwnerID int    `json:"-" db:"owner_id"`
+	Name    string `json:"name" db:"name"`
+	// Items is the number of items carrying the tag, filled in listings.
+	Items int `json:"items"`
+}
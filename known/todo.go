@@ -1,8 +1,146 @@
 package known
 
+import "time"
+
 type TodoItem struct {
-	ID          int    `json:"id" db:"id"`
-	Title       string `json:"title" db:"title" binding:"required"`
-	Description string `json:"description" db:"description"`
-	Done        bool   `json:"done" db:"done"`
+	ID          int      `json:"id" db:"id"`
+	OwnerID     int      `json:"-" db:"owner_id"`
+	Title       string   `json:"title" db:"title" binding:"required"`
+	Description string   `json:"description" db:"description"`
+	Done        bool     `json:"done" db:"done"`
+	Priority    Priority `json:"priority" db:"priority"`
+	// ListID is the list holding the item, zero for the inbox; subtasks share the list of their parent.
+	ListID int `json:"list_id,omitempty" db:"list_id"`
+	// ParentID is the item this one is a subtask of, zero for a top level item.
+	ParentID int `json:"parent_id,omitempty" db:"parent_id"`
+	// Position is the place of the item in the manual order of its owner; moving an item
+	// puts it halfway between its new neighbours, so the other items keep their positions.
+	Position float64   `json:"position" db:"position"`
+	Created  time.Time `json:"created_at" db:"created_at"`
+	// Start and Due are optional; an item with a Start is not meant to be worked on before it.
+	Start *time.Time `json:"start_at,omitempty" db:"start_at"`
+	Due   *time.Time `json:"due_at,omitempty" db:"due_at"`
+	// Recurrence is a repeat rule in a subset of the RFC 5545 RRULE syntax; completing
+	// the item creates its next occurrence, which takes the rule over.
+	Recurrence string `json:"recurrence,omitempty" db:"recurrence"`
+	// SeriesID is the first item of the series an occurrence belongs to, zero for the first one.
+	SeriesID int `json:"series_id,omitempty" db:"series_id"`
+	// Tags are the names of the item's tags in alphabetical order.
+	Tags []string `json:"tags,omitempty"`
+	// Checklist is a list of steps lighter than subtasks, kept along with the item.
+	Checklist []ChecklistEntry `json:"checklist,omitempty" db:"checklist"`
+	// Progress counts the done direct subtasks and checklist entries, it is nil without either.
+	Progress *Progress `json:"progress,omitempty"`
+	// Completed is when the item was last marked as done, nil while it is not.
+	Completed *time.Time `json:"completed_at,omitempty" db:"completed_at"`
+	// Archived is when the done item left the active listings, nil for an active item.
+	Archived *time.Time `json:"archived_at,omitempty" db:"archived_at"`
+	// Deleted is when the item was moved to the trash, nil for a live item.
+	Deleted *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
+	// Version counts the updates of the item from 1 on; an update or a deletion naming a version
+	// other than the current one is refused, so concurrent edits do not overwrite each other.
+	Version int `json:"version" db:"version"`
+}
+
+type ChecklistEntry struct {
+	Text string `json:"text"`
+	Done bool   `json:"done"`
+}
+
+type Progress struct {
+	Done  int `json:"done"`
+	Total int `json:"total"`
+}
+
+// Listings follow the manual order unless asked to sort by another field.
+const (
+	SortByPosition = "position"
+	SortByPriority = "priority"
+	SortByID       = "id"
+	SortByTitle    = "title"
+	SortByCreated  = "created"
+	// SortByDue puts items without a due date last in either direction.
+	SortByDue = "due"
+	// SortByCompleted puts items that are not done last in either direction.
+	SortByCompleted = "completed"
+	// SortByDeleted only applies to the trash.
+	SortByDeleted = "deleted"
+)
+
+// TodoQuery narrows down and orders a listing of todo items.
+// A zero Limit means no limit; a nil Done matches both states.
+type TodoQuery struct {
+	Limit  int
+	SortBy string
+	Desc   bool
+	Done   *bool
+	// After resumes the listing right past the item it marks, it has to follow the same order.
+	After *PageCursor
+	// Text is matched case-insensitively against title and description.
+	Text string
+	// DueFrom and DueBefore bound the due date, inclusively and exclusively;
+	// either of them excludes the items without a due date.
+	DueFrom   *time.Time
+	DueBefore *time.Time
+	// Tags keeps the items carrying all of the named tags, or any of them with AnyTag.
+	Tags   []string
+	AnyTag bool
+	// ListID narrows the listing down to one list; without it the items
+	// of archived lists are left out.
+	ListID int
+	// ParentID narrows the listing down to the direct subtasks of an item.
+	ParentID int
+	// SeriesID narrows the listing down to the occurrences of a recurring item, the first one included.
+	SeriesID int
+	// Archived lists the archived items instead of the active ones.
+	Archived bool
+	// AnyArchived lists the archived items along with the active ones, as the occurrences of a series are.
+	AnyArchived bool
+	// Trashed lists the items in the trash instead of the live ones, archived lists and items included.
+	Trashed bool
+}
+
+// TodoPage is one page of a listing; Next is nil on the last page.
+type TodoPage struct {
+	Items []*TodoItem
+	Limit int
+	Next  *PageCursor
+}
+
+// PageCursor marks an item of a listing by its sort key and ID, so the next page starts
+// right past it however many items were added or removed before it in the meantime.
+type PageCursor struct {
+	SortBy string
+	Desc   bool
+	// Key is the value of the sort field of the item, nil for a missing date or when sorting by ID.
+	Key any
+	ID  int
+}
+
+// NewPageCursor marks item in a listing sorted by sortBy.
+func NewPageCursor(item *TodoItem, sortBy string, desc bool) *PageCursor {
+	cursor := PageCursor{SortBy: sortBy, Desc: desc, ID: item.ID}
+	var date *time.Time
+	switch sortBy {
+	case SortByID:
+	case SortByPriority:
+		cursor.Key = item.Priority
+	case SortByTitle:
+		cursor.Key = item.Title
+	case SortByCreated:
+		cursor.Key = item.Created
+	case SortByDue:
+		date = item.Due
+	case SortByCompleted:
+		date = item.Completed
+	case SortByDeleted:
+		date = item.Deleted
+	default:
+		cursor.Key = item.Position
+	}
+	if date != nil {
+		cursor.Key = *date
+	}
+
+	return &cursor
 }
@@ -0,0 +1,28 @@
+package known
+
+import "time"
+
+// Principal is the caller identified by a verified access token.
+type Principal struct {
+	UserID    int
+	Username  string
+	TokenID   string
+	ExpiresAt time.Time
+}
+
+// RefreshToken is the persisted side of an issued refresh token; only the hash of
+// the token itself is kept. Tokens rotated from one login share the same Family.
+type RefreshToken struct {
+	Hash      string
+	UserID    int
+	Family    string
+	ExpiresAt time.Time
+	Revoked   bool
+}
+
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+}
@@ -1,8 +1,24 @@
 package known
 
+import "time"
+
 type User struct {
-	ID       int    `json:"-" db:"id"`
-	Name     string `json:"name" binding:"required"`
-	Username string `json:"username" binding:"required"`
-	Password string `json:"password" binding:"required"`
+	ID           int    `json:"id" db:"id"`
+	Name         string `json:"name" db:"name" binding:"required"`
+	Username     string `json:"username" db:"username" binding:"required"`
+	PasswordHash string `json:"-" db:"password_hash"`
+	// TimeZone is an IANA name like Europe/Berlin, days of the due views start in it.
+	TimeZone string `json:"time_zone" db:"time_zone"`
+	// Email is optional, reminders are mailed to it when they go out by email.
+	Email string `json:"email,omitempty" db:"email"`
+}
+
+// Location is the time zone of the user, UTC when it is not set or unknown.
+func (u *User) Location() *time.Location {
+	loc, err := time.LoadLocation(u.TimeZone)
+	if err != nil {
+		return time.UTC
+	}
+
+	return loc
 }